@@ -1,13 +1,20 @@
 package api
 
 import (
+	"context"
 	"fmt"
+	"math"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/eust-w/urlreader/config"
 	"github.com/eust-w/urlreader/internal/llm"
 	"github.com/eust-w/urlreader/internal/models"
+	"github.com/eust-w/urlreader/internal/prompt"
+	"github.com/eust-w/urlreader/internal/rag"
 	"github.com/eust-w/urlreader/internal/scraper"
 	"github.com/eust-w/urlreader/internal/storage"
 	"github.com/eust-w/urlreader/internal/logger"
@@ -20,17 +27,34 @@ type Handler struct {
 	config        *config.Config
 	scraper       *scraper.Scraper
 	llmFactory    *llm.LLMFactory
-	conversations *storage.ConversationStore
+	conversations storage.Store
+	// embedder 为空表示未启用检索增强
+	embedder rag.Embedder
 }
 
 // NewHandler 创建一个新的API处理程序
-func NewHandler(cfg *config.Config) *Handler {
+func NewHandler(cfg *config.Config) (*Handler, error) {
+	store, err := storage.NewStore(cfg.StorageBackend, cfg.StoragePath)
+	if err != nil {
+		return nil, fmt.Errorf("初始化会话存储失败: %w", err)
+	}
+
+	var embedder rag.Embedder
+	if cfg.RAGTopK > 0 {
+		embedder, err = rag.NewEmbedder(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("初始化向量化实现失败: %w", err)
+		}
+		logger.GetLogger().Infow("检索增强已启用", "embedder", embedder.Name(), "top_k", cfg.RAGTopK)
+	}
+
 	return &Handler{
 		config:        cfg,
-		scraper:       scraper.NewScraper(),
+		scraper:       scraper.NewScraper(cfg),
 		llmFactory:    llm.NewLLMFactory(cfg),
-		conversations: storage.NewConversationStore(),
-	}
+		conversations: store,
+		embedder:      embedder,
+	}, nil
 }
 
 // SetupRoutes 设置API路由
@@ -39,10 +63,18 @@ func (h *Handler) SetupRoutes(router *gin.Engine) {
 	{
 		api.POST("/parse", h.ParseURL)
 		api.POST("/chat", h.Chat)
+		api.POST("/chat/stream", h.ChatStream)
 		api.GET("/history/:conversation_id", h.GetHistory)
 		api.GET("/conversations", h.ListConversations)
 		api.DELETE("/history/:conversation_id", h.DeleteConversation)
+		api.GET("/conversations/:conversation_id/sources", h.ListSources)
+		api.POST("/conversations/:conversation_id/sources", h.AttachSource)
+		api.DELETE("/conversations/:conversation_id/sources/:source_id", h.DetachSource)
+		api.POST("/rules/test", h.TestRule)
 	}
+
+	// 类似 Jina Reader 的直读接口：GET /r/https://example.com/page
+	router.GET("/r/*url", h.Read)
 }
 
 // ParseURL 处理URL解析请求
@@ -55,26 +87,292 @@ func (h *Handler) ParseURL(c *gin.Context) {
 		})
 		return
 	}
+	if req.Crawl != nil {
+		h.crawlURL(c, req)
+		return
+	}
+	if req.Feed != nil {
+		h.readFeed(c, req)
+		return
+	}
 
-	content, err := h.scraper.ScrapeURL(req.URL)
+	content, err := h.scraper.ScrapeContext(c.Request.Context(), req.URL, scraper.ScrapeOptions{Format: req.Format, Cache: req.Cache})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+		c.JSON(scrapeErrorResponse(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ParseResponse{
+		Success:  true,
+		Title:    content.Title,
+		Content:  content.Content,
+		URL:      content.URL,
+		Format:   content.Format,
+		Metadata: content.Metadata,
+		Encoding: content.Encoding,
+		Cache:    content.Cache,
+	})
+}
+
+// crawlURL 处理多页面抓取的解析请求，顶层字段为起始页面的结果，pages 为每个页面的结果
+func (h *Handler) crawlURL(c *gin.Context, req models.ParseRequest) {
+	opts := h.crawlOptions(req.Crawl)
+	opts.Format = req.Format
+	pages, err := h.scraper.Crawl(c.Request.Context(), req.URL, opts)
+	if scraper.ErrorCode(err) != "" {
+		c.JSON(scrapeErrorResponse(err))
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
 			Success: false,
-			Error:   "抓取URL失败: " + err.Error(),
+			Error:   "无效的抓取参数: " + err.Error(),
 		})
 		return
 	}
 
+	results := pageResults(pages, true)
+	start := results[0]
+	if start.Error != "" && !anyPageSucceeded(pages) {
+		c.JSON(scrapeErrorResponse(pages[0].Err()))
+		return
+	}
+
+	format := req.Format
+	if format == "" {
+		format = scraper.FormatText
+	}
+	c.JSON(http.StatusOK, models.ParseResponse{
+		Success:  true,
+		Title:    start.Title,
+		Content:  start.Content,
+		URL:      start.URL,
+		Format:   format,
+		Pages:    results,
+		Metadata: start.Metadata,
+		Encoding: start.Encoding,
+	})
+}
+
+// readFeed 处理订阅源模式的解析请求，顶层字段为订阅源概览，entries 为条目，pages 为文章全文的抓取结果
+func (h *Handler) readFeed(c *gin.Context, req models.ParseRequest) {
+	opts := h.feedOptions(req.Feed)
+	opts.Format = req.Format
+	feed, err := h.scraper.ReadFeed(c.Request.Context(), req.URL, opts)
+	if err != nil {
+		c.JSON(scrapeErrorResponse(err))
+		return
+	}
+
+	format := req.Format
+	if format == "" {
+		format = scraper.FormatText
+	}
 	c.JSON(http.StatusOK, models.ParseResponse{
 		Success: true,
-		Title:   content.Title,
-		Content: content.Content,
-		URL:     content.URL,
+		Title:   feed.Title,
+		Content: feed.Content,
+		URL:     feed.URL,
+		Format:  format,
+		Pages:   pageResults(feed.Articles, true),
+		Entries: feedEntries(feed.Entries),
 	})
 }
 
-// Chat 处理聊天请求
-func (h *Handler) Chat(c *gin.Context) {
+// feedOptions 把请求的文章数限制在配置的上限内
+func (h *Handler) feedOptions(req *models.FeedRequest) scraper.FeedOptions {
+	return scraper.FeedOptions{Articles: min(max(req.Articles, 0), h.config.FeedMaxArticles)}
+}
+
+// feedEntries 把订阅源条目转换为响应结构，没有发布时间的条目不返回 published
+func feedEntries(entries []scraper.FeedEntry) []models.FeedEntry {
+	results := make([]models.FeedEntry, len(entries))
+	for i, e := range entries {
+		results[i] = models.FeedEntry{Title: e.Title, URL: e.URL, Summary: e.Summary}
+		if !e.Published.IsZero() {
+			published := e.Published
+			results[i].Published = &published
+		}
+	}
+	return results
+}
+
+// scrapeErrorStatus 抓取错误码对应的HTTP状态码
+var scrapeErrorStatus = map[string]int{
+	"blocked_address":          http.StatusForbidden,
+	"robots_disallowed":        http.StatusForbidden,
+	"not_feed":                 http.StatusBadRequest,
+	"response_too_large":       http.StatusBadGateway,
+	"too_many_redirects":       http.StatusBadGateway,
+	"unsupported_content_type": http.StatusUnsupportedMediaType,
+	"time_budget_exceeded":     http.StatusGatewayTimeout,
+	"not_cached":               http.StatusNotFound,
+	"invalid_rule":             http.StatusBadRequest,
+}
+
+// scrapeErrorResponse 把抓取错误转换为状态码与错误响应，可识别的错误带错误码
+func scrapeErrorResponse(err error) (int, models.ErrorResponse) {
+	code := scraper.ErrorCode(err)
+	status, ok := scrapeErrorStatus[code]
+	if !ok {
+		return http.StatusInternalServerError, models.ErrorResponse{Success: false, Error: "抓取URL失败: " + err.Error()}
+	}
+	return status, models.ErrorResponse{Success: false, Error: err.Error(), Code: code}
+}
+
+// storageErrorResponse 记录会话存储错误（如数据库被锁定或损坏）并转换为500响应
+func storageErrorResponse(err error) (int, models.ErrorResponse) {
+	logger.GetLogger().Errorw("读取会话存储失败", "error", err)
+	return http.StatusInternalServerError, models.ErrorResponse{Success: false, Error: "读取会话失败: " + err.Error()}
+}
+
+// TestRule 试运行站点提取规则：抓取URL并按给定规则（或规则文件中匹配的规则）提取，不使用缓存
+func (h *Handler) TestRule(c *gin.Context) {
+	var req models.RuleTestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success: false,
+			Error:   "无效的请求: " + err.Error(),
+		})
+		return
+	}
+
+	result, err := h.scraper.TestRule(c.Request.Context(), req.URL, req.Rule, req.Format)
+	if err != nil {
+		c.JSON(scrapeErrorResponse(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.RuleTestResponse{
+		Success:  true,
+		URL:      result.URL,
+		FinalURL: result.FinalURL,
+		Title:    result.Title,
+		Content:  result.Content,
+		Format:   result.Format,
+		Rule:     result.Rule,
+		Applied:  result.Applied,
+		Reason:   result.Reason,
+	})
+}
+
+// crawlOptions 把请求中的抓取范围限制在配置的上限内，未指定时使用上限
+func (h *Handler) crawlOptions(req *models.CrawlRequest) scraper.CrawlOptions {
+	opts := scraper.CrawlOptions{
+		MaxDepth: h.config.CrawlMaxDepth,
+		MaxPages: h.config.CrawlMaxPages,
+		Include:  req.Include,
+		Exclude:  req.Exclude,
+		Sitemap:  req.Sitemap,
+	}
+	if req.MaxDepth > 0 && req.MaxDepth < opts.MaxDepth {
+		opts.MaxDepth = req.MaxDepth
+	}
+	if req.MaxPages > 0 && req.MaxPages < opts.MaxPages {
+		opts.MaxPages = req.MaxPages
+	}
+	return opts
+}
+
+// pageResults 把抓取结果转换为响应结构，withContent 为 false 时不返回正文
+func pageResults(pages []scraper.CrawledPage, withContent bool) []models.PageResult {
+	results := make([]models.PageResult, len(pages))
+	for i, p := range pages {
+		results[i] = models.PageResult{
+			URL:      p.URL,
+			FinalURL: p.FinalURL,
+			Title:    p.Title,
+			Depth:    p.Depth,
+			Encoding: p.Encoding,
+			Error:    p.Error,
+			Code:     p.Code,
+		}
+		if withContent {
+			results[i].Content = p.Content
+			results[i].Metadata = p.Metadata
+		}
+	}
+	return results
+}
+
+// anyPageSucceeded 判断是否至少有一个页面抓取成功
+func anyPageSucceeded(pages []scraper.CrawledPage) bool {
+	for _, p := range pages {
+		if p.Error == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// scrapeSources 抓取会话来源。crawl 与 feed 都为空时只抓取 url 本身；
+// crawl 非空时抓取同站的多个页面，每个成功的页面作为一个来源；
+// feed 非空时订阅源概览作为第一个来源，每篇抓取成功的文章各作为一个来源。
+// 后两种情况同时返回每个页面的结果。
+func (h *Handler) scrapeSources(ctx context.Context, url string, crawl *models.CrawlRequest, feed *models.FeedRequest) ([]storage.Source, []models.PageResult, error) {
+	if feed != nil {
+		result, err := h.scraper.ReadFeed(ctx, url, h.feedOptions(feed))
+		if err != nil {
+			return nil, nil, err
+		}
+		sources := []storage.Source{{URL: result.URL, Title: result.Title, Content: result.Content}}
+		for _, p := range result.Articles {
+			if p.Error == "" {
+				sources = append(sources, storage.Source{URL: p.URL, Title: p.Title, Content: p.Content, Metadata: p.Metadata})
+			}
+		}
+		return sources, pageResults(result.Articles, false), nil
+	}
+	if crawl == nil {
+		content, err := h.scraper.ScrapeContext(ctx, url, scraper.ScrapeOptions{})
+		if err != nil {
+			return nil, nil, err
+		}
+		return []storage.Source{{URL: content.URL, Title: content.Title, Content: content.Content, Metadata: content.Metadata}}, nil, nil
+	}
+
+	pages, err := h.scraper.Crawl(ctx, url, h.crawlOptions(crawl))
+	if err != nil {
+		return nil, nil, err
+	}
+	var sources []storage.Source
+	for _, p := range pages {
+		if p.Error == "" {
+			sources = append(sources, storage.Source{URL: p.URL, Title: p.Title, Content: p.Content, Metadata: p.Metadata})
+		}
+	}
+	if len(sources) == 0 {
+		return nil, nil, fmt.Errorf("未抓取到任何页面: %w", pages[0].Err())
+	}
+	return sources, pageResults(pages, false), nil
+}
+
+// chatSession 保存一次聊天请求已准备好的上下文
+type chatSession struct {
+	conversationID string
+	model          string
+	prompt         prompt.Input
+	// chunks 会话的全部片段，用于校验回答中的引用
+	chunks []rag.Chunk
+}
+
+// buildPrompt 按提供商的上下文窗口组装本次调用的消息
+func (s *chatSession) buildPrompt(provider llm.LLMProvider) []llm.Message {
+	limits := llm.LimitsOf(provider)
+	messages := prompt.Build(s.prompt, limits)
+	logger.GetLogger().Infow("提示词组装完成", "provider", provider.Name(), "window", limits.Window,
+		"messages", len(messages), "estimated_tokens", llm.EstimateMessagesTokens(limits.Family, messages))
+	return messages
+}
+
+// extractCitations 移除回答中的引用块并返回校验后的引用
+func (s *chatSession) extractCitations(response string) (string, []models.Citation) {
+	return prompt.ExtractCitations(response, s.chunks, s.prompt.Sources)
+}
+
+// prepareChat 解析聊天请求、获取LLM提供商并组装消息历史。
+// 返回false时错误响应已写入，调用方直接返回即可。
+func (h *Handler) prepareChat(c *gin.Context) (*chatSession, bool) {
 	log := logger.GetLogger()
 	var req models.ChatRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -83,7 +381,7 @@ func (h *Handler) Chat(c *gin.Context) {
 			Success: false,
 			Error:   "无效的请求: " + err.Error(),
 		})
-		return
+		return nil, false
 	}
 	log.Infow("/api/chat 收到请求", "req", req)
 
@@ -94,7 +392,7 @@ func (h *Handler) Chat(c *gin.Context) {
 			Success: false,
 			Error:   "首次对话必须提供URL",
 		})
-		return
+		return nil, false
 	}
 
 	// 如果未指定模型，使用默认模型
@@ -102,142 +400,340 @@ func (h *Handler) Chat(c *gin.Context) {
 		req.Model = "azure_openai"
 	}
 
-	// 获取LLM提供商
-	provider, err := h.llmFactory.GetProvider(req.Model)
-	if err != nil {
+	// 检查LLM提供商：主模型未配置时，只要配置了后备链即可由后备提供商作答
+	if _, err := h.llmFactory.GetProvider(req.Model); err != nil && len(h.llmFactory.Chain(req.Model)) == 1 {
 		log.Errorw("获取LLM Provider失败", "model", req.Model, "error", err)
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
 			Success: false,
 			Error:   "LLM提供商错误: " + err.Error(),
 		})
-		return
+		return nil, false
 	}
 
 	var conversation *storage.Conversation
 	var exists bool
+	var err error
 
 	// 处理会话ID
 	if req.ConversationID != "" {
 		// 使用现有会话
-		conversation, exists = h.conversations.Get(req.ConversationID)
+		conversation, exists, err = h.conversations.Get(req.ConversationID)
+		if err != nil {
+			c.JSON(storageErrorResponse(err))
+			return nil, false
+		}
 		if !exists {
 			c.JSON(http.StatusNotFound, models.ErrorResponse{
 				Success: false,
 				Error:   "会话不存在",
 			})
-			return
+			return nil, false
 		}
 	} else {
 		// 创建新会话，首先抓取URL内容
-		content, err := h.scraper.ScrapeURL(req.URL)
+		ctx := c.Request.Context()
+		sources, _, err := h.scrapeSources(ctx, req.URL, req.Crawl, req.Feed)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-				Success: false,
-				Error:   "抓取URL失败: " + err.Error(),
-			})
-			return
+			c.JSON(scrapeErrorResponse(err))
+			return nil, false
 		}
 
-		// 创建新会话
+		// 创建新会话，多页面抓取或订阅源模式时其余页面依次作为来源添加
 		conversationID := uuid.New().String()
-		conversation = h.conversations.Create(conversationID, content.URL, content.Content)
+		conversation = h.conversations.Create(conversationID, sources[0], h.chunkContent(ctx, sources[0].Content))
+		if len(sources) > 1 {
+			for _, src := range sources[1:] {
+				h.conversations.AddSource(conversationID, src, h.chunkContent(ctx, src.Content))
+			}
+			if conversation, _, err = h.conversations.Get(conversationID); err != nil {
+				c.JSON(storageErrorResponse(err))
+				return nil, false
+			}
+		}
 		req.ConversationID = conversationID
 	}
 
-	// 准备消息
-	var messages []llm.Message
+	// 历史只包含之前的问答轮次，网页内容在每次调用时按提供商的上下文窗口重新组装
+	history, _, err := h.conversations.GetMessages(req.ConversationID)
+	if err != nil {
+		c.JSON(storageErrorResponse(err))
+		return nil, false
+	}
 
-	if len(conversation.Messages) == 0 {
-		// 新会话，添加系统消息
-		systemPrompt := fmt.Sprintf("你是一个网页内容助手。你将基于从URL %s 抓取的内容回答问题。请保持回答简洁、准确，并直接基于提供的网页内容。", conversation.URL)
-		messages = append(messages, llm.Message{
-			Role:    "system",
-			Content: systemPrompt,
-		})
+	// 保存用户的新消息
+	h.conversations.AddMessage(req.ConversationID, llm.Message{
+		Role:    "user",
+		Content: req.Message,
+	})
 
-		// 添加包含网页内容的第一条消息
-		contentPrompt := fmt.Sprintf("以下是从网页抓取的内容:\n\n%s", conversation.Content)
-		messages = append(messages, llm.Message{
-			Role:    "user",
-			Content: contentPrompt,
-		})
+	input := prompt.Input{
+		Sources:  promptSources(conversation.Sources),
+		History:  history,
+		Question: req.Message,
+		Today:    time.Now(),
+	}
+	input.Chunks, input.Retrieved = h.retrieveChunks(c.Request.Context(), conversation.Chunks, prompt.RelevanceQuery(input))
 
-		// 添加助手确认消息
-		messages = append(messages, llm.Message{
-			Role:    "assistant",
-			Content: "我已经阅读了网页内容，请问有什么我可以帮助你的？",
-		})
+	return &chatSession{
+		conversationID: req.ConversationID,
+		model:          req.Model,
+		prompt:         input,
+		chunks:         conversation.Chunks,
+	}, true
+}
 
-		// 保存这些初始消息到会话
-		for _, msg := range messages {
-			h.conversations.AddMessage(req.ConversationID, msg)
+// chunkContent 把网页内容切分为编号片段，片段用于引用原文；启用检索时同时生成向量。
+// 片段编号由存储在保存时按会话重新分配。
+func (h *Handler) chunkContent(ctx context.Context, content string) []rag.Chunk {
+	chunks := rag.ChunkContent(content, h.config.RAGChunkSize)
+	if h.embedder != nil {
+		if err := rag.EmbedChunks(ctx, h.embedder, chunks); err != nil {
+			logger.GetLogger().Warnw("生成片段向量失败，将发送全部片段", "error", err)
 		}
-	} else {
-		// 使用现有会话的消息历史
-		messages, _ = h.conversations.GetMessages(req.ConversationID)
 	}
+	return chunks
+}
 
-	// 添加用户的新消息
-	userMessage := llm.Message{
-		Role:    "user",
-		Content: req.Message,
+// promptSources 把会话来源转换为提示词构建所需的结构
+func promptSources(sources []storage.Source) []prompt.Source {
+	converted := make([]prompt.Source, len(sources))
+	for i, src := range sources {
+		converted[i] = prompt.Source{ID: src.ID, Title: src.Title, URL: src.URL, Content: src.Content}
+		if md := src.Metadata; md != nil {
+			converted[i].Description = md.Description
+			converted[i].Author = md.Author
+			converted[i].Published = md.Published
+			converted[i].Modified = md.Modified
+			converted[i].SiteName = md.SiteName
+			converted[i].Language = md.Language
+		}
+	}
+	return converted
+}
+
+// retrieveChunks 选出本次提问要发送的片段。第二个返回值表示是否经过向量检索；
+// 未启用检索、片段不超过 top-k 个、片段没有向量或检索失败时返回全部片段。
+func (h *Handler) retrieveChunks(ctx context.Context, chunks []rag.Chunk, query string) ([]rag.Chunk, bool) {
+	if h.embedder == nil || len(chunks) <= h.config.RAGTopK {
+		return chunks, false
+	}
+
+	retrieved, err := rag.Retrieve(ctx, h.embedder, chunks, query, h.config.RAGTopK)
+	if err != nil || len(retrieved) == 0 {
+		logger.GetLogger().Warnw("检索片段失败，将发送全部片段", "error", err)
+		return chunks, false
+	}
+	return retrieved, true
+}
+
+// Read 直接以纯文本或Markdown返回网页正文，格式通过Accept头协商，默认Markdown
+func (h *Handler) Read(c *gin.Context) {
+	target := readerTargetURL(c)
+	if target == "" {
+		c.String(http.StatusBadRequest, "缺少目标URL，用法: GET /r/https://example.com/page\n")
+		return
 	}
-	messages = append(messages, userMessage)
-	h.conversations.AddMessage(req.ConversationID, userMessage)
 
-	// 调用LLM获取响应
-	response, err := provider.Chat(messages)
+	format := scraper.FormatMarkdown
+	contentType := "text/markdown"
+	if c.NegotiateFormat("text/markdown", "text/plain") == "text/plain" {
+		format = scraper.FormatText
+		contentType = "text/plain"
+	}
+
+	content, err := h.scraper.ScrapeContext(c.Request.Context(), target, scraper.ScrapeOptions{Format: format})
+	if status, ok := scrapeErrorStatus[scraper.ErrorCode(err)]; ok {
+		c.String(status, "%s\n", err.Error())
+		return
+	}
 	if err != nil {
-		// 检查是否是 Azure OpenAI 的速率限制错误
-		if req.Model == "azure_openai" && (err.Error() == "API错误: {\"error\":{\"code\":\"429\"" || err.Error() == "状态码: 429") {
-			// 尝试切换到 DeepSeek 模型
-			fmt.Println("Azure OpenAI 速率限制，切换到 DeepSeek 模型")
-			deepseekProvider, deepseekErr := h.llmFactory.GetProvider("deepseek")
-			if deepseekErr != nil {
-				c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-					Success: false,
-					Error:   "LLM响应错误: " + err.Error() + "，切换到备用模型失败: " + deepseekErr.Error(),
-				})
-				return
-			}
-			
-			// 使用 DeepSeek 模型重试
-			response, err = deepseekProvider.Chat(messages)
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-					Success: false,
-					Error:   "备用模型响应错误: " + err.Error(),
-				})
-				return
-			}
-			// 更新当前使用的模型为 DeepSeek
-			provider = deepseekProvider
-		} else {
-			log.Errorw("LLM 响应错误", "model", req.Model, "error", err)
-			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-				Success: false,
-				Error:   "LLM响应错误: " + err.Error(),
-			})
-			return
+		c.String(http.StatusBadGateway, "抓取URL失败: %s\n", err.Error())
+		return
+	}
+
+	c.Header("X-Title", mime.QEncoding.Encode("utf-8", content.Title))
+	c.Header("X-URL", content.URL)
+	c.Header("X-Final-URL", content.FinalURL)
+	c.Data(http.StatusOK, contentType+"; charset=utf-8", []byte(content.Content+"\n"))
+}
+
+// readerTargetURL 从 /r/*url 路径中还原目标URL，包括查询参数
+func readerTargetURL(c *gin.Context) string {
+	target := strings.TrimPrefix(c.Param("url"), "/")
+	if target == "" {
+		return ""
+	}
+
+	// 部分代理会把路径中的 "//" 合并为 "/"
+	for _, scheme := range []string{"http:/", "https:/"} {
+		if strings.HasPrefix(target, scheme) && !strings.HasPrefix(target, scheme+"/") {
+			target = scheme + "/" + strings.TrimPrefix(target, scheme)
 		}
 	}
 
+	if c.Request.URL.RawQuery != "" {
+		target += "?" + c.Request.URL.RawQuery
+	}
+	return target
+}
+
+// Chat 处理聊天请求
+func (h *Handler) Chat(c *gin.Context) {
+	// 客户端声明接受SSE时，走流式响应
+	if strings.Contains(c.GetHeader("Accept"), "text/event-stream") {
+		h.ChatStream(c)
+		return
+	}
+
+	log := logger.GetLogger()
+	session, ok := h.prepareChat(c)
+	if !ok {
+		return
+	}
+
+	// 调用LLM获取响应，失败时按后备链切换提供商
+	response, provider, attempts, err := h.llmFactory.ChatWithFallback(c.Request.Context(), session.model, session.buildPrompt)
+	if err != nil {
+		log.Errorw("LLM 响应错误", "model", session.model, "error", err)
+		status, code := llmErrorStatus(c, err)
+		c.JSON(status, models.ErrorResponse{
+			Success: false,
+			Error:   "LLM响应错误: " + err.Error(),
+			Code:    code,
+		})
+		return
+	}
+
+	response, citations := session.extractCitations(response)
+
 	// 保存助手响应到会话
 	assistantMessage := llm.Message{
 		Role:    "assistant",
 		Content: response,
 	}
-	h.conversations.AddMessage(req.ConversationID, assistantMessage)
+	h.conversations.AddMessage(session.conversationID, assistantMessage)
 
 	// 返回响应
 	c.JSON(http.StatusOK, models.ChatResponse{
 		Success:        true,
 		Response:       response,
-		ConversationID: req.ConversationID,
+		ConversationID: session.conversationID,
 		Model:          provider.Name(),
+		Skipped:        skippedProviders(attempts),
+		Citations:      citations,
 	})
 }
 
+// ChatStream 以Server-Sent Events流式返回聊天响应。
+// 事件依次为 meta（会话信息）、若干 delta（增量内容）、最后 done 或 error。
+func (h *Handler) ChatStream(c *gin.Context) {
+	log := logger.GetLogger()
+	session, ok := h.prepareChat(c)
+	if !ok {
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	// meta 事件在确定由哪个提供商作答后发送，引用块不作为 delta 发送
+	ctx := c.Request.Context()
+	filter := &prompt.CitationFilter{}
+	sendDelta := func(text string) {
+		if text != "" {
+			c.SSEvent("delta", models.ChatStreamEvent{Content: text})
+			c.Writer.Flush()
+		}
+	}
+	response, provider, attempts, err := h.llmFactory.ChatStreamWithFallback(ctx, session.model, session.buildPrompt,
+		func(provider llm.LLMProvider, skipped []llm.Attempt) error {
+			c.SSEvent("meta", models.ChatStreamEvent{
+				ConversationID: session.conversationID,
+				Model:          provider.Name(),
+				Skipped:        skippedProviders(skipped),
+			})
+			c.Writer.Flush()
+			return nil
+		},
+		func(delta string) error {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			sendDelta(filter.Write(delta))
+			return nil
+		})
+	if ctx.Err() == nil {
+		sendDelta(filter.Flush())
+	}
+	response, citations := session.extractCitations(response)
+
+	// 无论正常结束还是中途中断，都保存已生成的助手回复
+	if response != "" {
+		h.conversations.AddMessage(session.conversationID, llm.Message{
+			Role:    "assistant",
+			Content: response,
+		})
+	}
+
+	if err != nil {
+		log.Errorw("LLM 流式响应错误", "model", session.model, "error", err, "received", len(response))
+		if ctx.Err() == nil {
+			_, code := llmErrorStatus(c, err)
+			c.SSEvent("error", models.ChatStreamEvent{
+				ConversationID: session.conversationID,
+				Error:          "LLM响应错误: " + err.Error(),
+				Code:           code,
+			})
+			c.Writer.Flush()
+		}
+		return
+	}
+
+	c.SSEvent("done", models.ChatStreamEvent{
+		ConversationID: session.conversationID,
+		Model:          provider.Name(),
+		Response:       response,
+		Skipped:        skippedProviders(attempts),
+		Citations:      citations,
+	})
+	c.Writer.Flush()
+}
+
+// llmErrorStatus 根据LLM错误类别返回HTTP状态码与错误码，速率限制时设置 Retry-After 头
+func llmErrorStatus(c *gin.Context, err error) (int, string) {
+	llmErr, ok := llm.AsError(err)
+	if !ok {
+		return http.StatusInternalServerError, ""
+	}
+
+	switch llmErr.Kind {
+	case llm.ErrRateLimited:
+		if llmErr.RetryAfter > 0 {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(llmErr.RetryAfter.Seconds()))))
+		}
+		return http.StatusTooManyRequests, string(llmErr.Kind)
+	case llm.ErrTimeout:
+		return http.StatusGatewayTimeout, string(llmErr.Kind)
+	default:
+		return http.StatusInternalServerError, string(llmErr.Kind)
+	}
+}
+
+// skippedProviders 把后备链中被跳过的尝试转换为响应结构
+func skippedProviders(attempts []llm.Attempt) []models.SkippedProvider {
+	if len(attempts) == 0 {
+		return nil
+	}
+	skipped := make([]models.SkippedProvider, 0, len(attempts))
+	for _, a := range attempts {
+		skipped = append(skipped, models.SkippedProvider{Model: a.Model, Reason: a.Reason()})
+	}
+	return skipped
+}
+
 // ListConversations 获取所有会话ID
 func (h *Handler) ListConversations(c *gin.Context) {
 	ids := h.conversations.ListIDs()
@@ -269,7 +765,11 @@ func (h *Handler) GetHistory(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "conversation_id不能为空"})
 		return
 	}
-	messages, ok := h.conversations.GetMessages(conversationID)
+	messages, ok, err := h.conversations.GetMessages(conversationID)
+	if err != nil {
+		c.JSON(storageErrorResponse(err))
+		return
+	}
 	if !ok {
 		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "会话不存在"})
 		return
@@ -281,6 +781,125 @@ func (h *Handler) GetHistory(c *gin.Context) {
 	})
 }
 
+// ListSources 查询会话引用的网页
+func (h *Handler) ListSources(c *gin.Context) {
+	conversationID := c.Param("conversation_id")
+	conversation, ok, err := h.conversations.Get(conversationID)
+	if err != nil {
+		c.JSON(storageErrorResponse(err))
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Success: false, Error: "会话不存在"})
+		return
+	}
+
+	sources := make([]models.SourceInfo, 0, len(conversation.Sources))
+	for _, src := range conversation.Sources {
+		sources = append(sources, sourceInfo(src, conversation.Chunks))
+	}
+	c.JSON(http.StatusOK, models.SourcesResponse{
+		Success:        true,
+		ConversationID: conversationID,
+		Sources:        sources,
+	})
+}
+
+// AttachSource 抓取新的URL并添加到已有会话，之后的提问会同时基于所有来源作答。
+// 指定 crawl 时抓取同站的多个页面，每个页面作为一个来源。
+func (h *Handler) AttachSource(c *gin.Context) {
+	conversationID := c.Param("conversation_id")
+	var req models.AttachSourceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success: false,
+			Error:   "无效的请求: " + err.Error(),
+		})
+		return
+	}
+	if _, ok, err := h.conversations.Get(conversationID); err != nil {
+		c.JSON(storageErrorResponse(err))
+		return
+	} else if !ok {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Success: false, Error: "会话不存在"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	sources, pages, err := h.scrapeSources(ctx, req.URL, req.Crawl, req.Feed)
+	if err != nil {
+		c.JSON(scrapeErrorResponse(err))
+		return
+	}
+
+	var added []models.SourceInfo
+	for _, src := range sources {
+		chunks := h.chunkContent(ctx, src.Content)
+		source, ok := h.conversations.AddSource(conversationID, src, chunks)
+		if !ok {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Success: false, Error: "会话不存在"})
+			return
+		}
+		logger.GetLogger().Infow("会话添加来源", "conversation_id", conversationID, "source_id", source.ID, "url", source.URL)
+		info := sourceInfo(*source, nil)
+		info.Chunks = len(chunks)
+		added = append(added, info)
+	}
+
+	resp := models.AttachSourceResponse{
+		Success:        true,
+		ConversationID: conversationID,
+		Source:         added[0],
+	}
+	if req.Crawl != nil || req.Feed != nil {
+		resp.Sources = added
+		resp.Pages = pages
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// DetachSource 从会话中移除来源，会话至少保留一个来源
+func (h *Handler) DetachSource(c *gin.Context) {
+	conversationID := c.Param("conversation_id")
+	sourceID, err := strconv.Atoi(c.Param("source_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Success: false, Error: "无效的来源编号"})
+		return
+	}
+
+	conversation, ok, err := h.conversations.Get(conversationID)
+	if err != nil {
+		c.JSON(storageErrorResponse(err))
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Success: false, Error: "会话不存在"})
+		return
+	}
+	if len(conversation.Sources) == 1 && conversation.Sources[0].ID == sourceID {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Success: false, Error: "会话至少需要保留一个来源"})
+		return
+	}
+	if !h.conversations.RemoveSource(conversationID, sourceID) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Success: false, Error: "来源不存在"})
+		return
+	}
+
+	logger.GetLogger().Infow("会话移除来源", "conversation_id", conversationID, "source_id", sourceID)
+	c.JSON(http.StatusOK, gin.H{"success": true, "conversation_id": conversationID, "source_id": sourceID})
+}
+
+// sourceInfo 把会话来源转换为响应结构，并统计该来源的片段数
+func sourceInfo(src storage.Source, chunks []rag.Chunk) models.SourceInfo {
+	info := models.SourceInfo{ID: src.ID, URL: src.URL, Title: src.Title, AddedAt: src.AddedAt}
+	for _, c := range chunks {
+		if c.Source == src.ID {
+			info.Chunks++
+		}
+	}
+	return info
+}
+
 // StartCleanupTask 启动定期清理旧会话的任务
 func (h *Handler) StartCleanupTask() {
 	go func() {
@@ -0,0 +1,344 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eust-w/urlreader/config"
+	"github.com/eust-w/urlreader/internal/models"
+	"github.com/eust-w/urlreader/internal/rag"
+	"github.com/eust-w/urlreader/internal/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// newTestRouter 创建使用内存存储的处理程序与路由
+func newTestRouter(t *testing.T) (*Handler, *gin.Engine) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	h, err := NewHandler(&config.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := gin.New()
+	h.SetupRoutes(router)
+	return h, router
+}
+
+// serve 发送请求并返回响应
+func serve(router *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestAttachDetachSource(t *testing.T) {
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, "<html><head><title>Page %s</title></head><body><article><h1>Heading</h1><p>%s</p></article></body></html>",
+			r.URL.Path, strings.Repeat("Some page content that is long enough to be the main content. ", 5))
+	}))
+	defer page.Close()
+
+	h, router := newTestRouter(t)
+	h.conversations.Create("conv", storage.Source{URL: page.URL + "/first", Title: "First"},
+		[]rag.Chunk{{Text: "first"}, {Text: "second"}})
+
+	w := serve(router, http.MethodPost, "/api/conversations/conv/sources", `{"url": "`+page.URL+`/second"}`)
+	var attached models.AttachSourceResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &attached); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("attach: status = %d, body = %s", w.Code, w.Body)
+	}
+	if attached.Source.ID != 2 || attached.Source.Title != "Page /second" || attached.Source.Chunks == 0 {
+		t.Errorf("attach: source = %+v", attached.Source)
+	}
+	conv, _, _ := h.conversations.Get("conv")
+	if len(conv.Chunks) != 2+attached.Source.Chunks || conv.Chunks[2].ID != 3 || conv.Chunks[2].Source != 2 {
+		t.Errorf("attach: chunks = %+v", conv.Chunks)
+	}
+
+	for _, tt := range []struct {
+		method, path, body string
+		status             int
+	}{
+		{http.MethodPost, "/api/conversations/missing/sources", `{"url": "` + page.URL + `"}`, http.StatusNotFound},
+		{http.MethodPost, "/api/conversations/conv/sources", `{}`, http.StatusBadRequest},
+		{http.MethodDelete, "/api/conversations/conv/sources/abc", "", http.StatusBadRequest},
+		{http.MethodDelete, "/api/conversations/conv/sources/9", "", http.StatusNotFound},
+		{http.MethodDelete, "/api/conversations/missing/sources/1", "", http.StatusNotFound},
+	} {
+		if w := serve(router, tt.method, tt.path, tt.body); w.Code != tt.status {
+			t.Errorf("%s %s: status = %d, want %d, body = %s", tt.method, tt.path, w.Code, tt.status, w.Body)
+		}
+	}
+
+	// 移除第一个来源后，剩余片段保留原编号
+	if w := serve(router, http.MethodDelete, "/api/conversations/conv/sources/1", ""); w.Code != http.StatusOK {
+		t.Fatalf("detach: status = %d, body = %s", w.Code, w.Body)
+	}
+	conv, _, _ = h.conversations.Get("conv")
+	if len(conv.Sources) != 1 || conv.Sources[0].ID != 2 || len(conv.Chunks) != attached.Source.Chunks || conv.Chunks[0].ID != 3 {
+		t.Errorf("detach: sources = %+v, chunks = %+v", conv.Sources, conv.Chunks)
+	}
+
+	// 不能移除最后一个来源
+	w = serve(router, http.MethodDelete, "/api/conversations/conv/sources/2", "")
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "至少需要保留一个来源") {
+		t.Errorf("detach last: status = %d, body = %s", w.Code, w.Body)
+	}
+	if conv, _, _ := h.conversations.Get("conv"); len(conv.Sources) != 1 {
+		t.Errorf("last source removed: %+v", conv.Sources)
+	}
+
+	w = serve(router, http.MethodGet, "/api/conversations/conv/sources", "")
+	var listed models.SourcesResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &listed); err != nil || len(listed.Sources) != 1 || listed.Sources[0].ID != 2 {
+		t.Errorf("list: status = %d, body = %s", w.Code, w.Body)
+	}
+}
+
+func TestReadCanceled(t *testing.T) {
+	release := make(chan struct{})
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer page.Close()
+	defer close(release)
+
+	_, router := newTestRouter(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest(http.MethodGet, "/r/"+page.URL+"/slow", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+	start := time.Now()
+	router.ServeHTTP(w, req)
+
+	// 客户端断开后立即停止抓取，而不是等到抓取超时
+	if elapsed := time.Since(start); elapsed > 2*time.Second || w.Code == http.StatusOK {
+		t.Errorf("status = %d after %s, body = %s", w.Code, elapsed, w.Body)
+	}
+}
+
+// sseEvent 流式响应中的一个事件
+type sseEvent struct {
+	name string
+	data models.ChatStreamEvent
+}
+
+// readEvents 逐个读取SSE事件，onEvent 返回 false 时停止
+func readEvents(t *testing.T, r io.Reader, onEvent func(sseEvent) bool) {
+	t.Helper()
+	scanner := bufio.NewScanner(r)
+	var name string
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event:"):
+			name = strings.TrimPrefix(line, "event:")
+		case strings.HasPrefix(line, "data:"):
+			var ev sseEvent
+			ev.name = name
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data:")), &ev.data); err != nil {
+				t.Fatalf("decode %s event: %v", name, err)
+			}
+			if !onEvent(ev) {
+				return
+			}
+		}
+	}
+}
+
+// newChatTestRouter 创建使用模拟OpenAI兼容服务 "stub" 的路由，stream 决定模拟服务的流式输出
+func newChatTestRouter(t *testing.T, stream http.HandlerFunc) (*Handler, *gin.Engine, string) {
+	t.Helper()
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, "<html><head><title>Guide</title></head><body><article><p>The service listens on port 8080 by default and can be changed with PORT.</p></article></body></html>")
+	}))
+	t.Cleanup(page.Close)
+	llmServer := httptest.NewServer(stream)
+	t.Cleanup(llmServer.Close)
+
+	gin.SetMode(gin.TestMode)
+	h, err := NewHandler(&config.Config{OpenAICompatibleProviders: []config.OpenAICompatibleConfig{
+		{Name: "stub", BaseURL: llmServer.URL + "/v1", Model: "stub-model"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := gin.New()
+	h.SetupRoutes(router)
+	return h, router, `{"url": "` + page.URL + `", "message": "Which port?", "model": "stub"}`
+}
+
+// writeChunks 以OpenAI兼容的SSE格式输出增量内容
+func writeChunks(w http.ResponseWriter, deltas ...string) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	for _, d := range deltas {
+		data, _ := json.Marshal(map[string]any{"choices": []any{map[string]any{"delta": map[string]string{"content": d}}}})
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		w.(http.Flusher).Flush()
+	}
+}
+
+// assistantReply 返回会话中最后一条助手回复
+func assistantReply(h *Handler, conversationID string) string {
+	messages, _, _ := h.conversations.GetMessages(conversationID)
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "assistant" {
+			return messages[i].Content
+		}
+	}
+	return ""
+}
+
+func TestChatStream(t *testing.T) {
+	h, router, body := newChatTestRouter(t, func(w http.ResponseWriter, r *http.Request) {
+		writeChunks(w, "Port 8080 [1].", "\n<cit", "ations>\n[1] \"listens on port 8080\"\n</citations>")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	})
+
+	w := serve(router, http.MethodPost, "/api/chat/stream", body)
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/event-stream") {
+		t.Fatalf("status = %d, headers = %v", w.Code, w.Header())
+	}
+	var names []string
+	var deltas strings.Builder
+	var meta, done models.ChatStreamEvent
+	readEvents(t, w.Body, func(ev sseEvent) bool {
+		names = append(names, ev.name)
+		switch ev.name {
+		case "meta":
+			meta = ev.data
+		case "delta":
+			deltas.WriteString(ev.data.Content)
+		case "done":
+			done = ev.data
+		}
+		return true
+	})
+
+	if len(names) < 3 || names[0] != "meta" || names[len(names)-1] != "done" {
+		t.Fatalf("events = %v", names)
+	}
+	for _, name := range names[1 : len(names)-1] {
+		if name != "delta" {
+			t.Errorf("events = %v", names)
+		}
+	}
+	if meta.ConversationID == "" || meta.Model != "stub" || done.ConversationID != meta.ConversationID {
+		t.Errorf("meta = %+v, done = %+v", meta, done)
+	}
+	// 引用块不作为 delta 发送，只出现在 done 事件的 citations 中
+	if deltas.String() != "Port 8080 [1].\n" || done.Response != "Port 8080 [1]." {
+		t.Errorf("deltas = %q, response = %q", deltas.String(), done.Response)
+	}
+	if len(done.Citations) != 1 || done.Citations[0].ChunkID != 1 || done.Citations[0].Quote != "listens on port 8080" {
+		t.Errorf("citations = %+v", done.Citations)
+	}
+	if reply := assistantReply(h, meta.ConversationID); reply != "Port 8080 [1]." {
+		t.Errorf("saved reply = %q", reply)
+	}
+}
+
+func TestChatStreamError(t *testing.T) {
+	h, router, body := newChatTestRouter(t, func(w http.ResponseWriter, r *http.Request) {
+		writeChunks(w, "Port ", "8080")
+		fmt.Fprint(w, "data: {\"error\":{\"message\":\"The server is overloaded\",\"type\":\"server_error\"}}\n\n")
+	})
+
+	var names []string
+	var conversationID, errMessage string
+	readEvents(t, serve(router, http.MethodPost, "/api/chat/stream", body).Body, func(ev sseEvent) bool {
+		names = append(names, ev.name)
+		if ev.name == "meta" {
+			conversationID = ev.data.ConversationID
+		}
+		if ev.name == "error" {
+			errMessage = ev.data.Error
+		}
+		return true
+	})
+	if strings.Join(names, ",") != "meta,delta,delta,error" || !strings.Contains(errMessage, "overloaded") {
+		t.Errorf("events = %v, error = %q", names, errMessage)
+	}
+	if reply := assistantReply(h, conversationID); reply != "Port 8080" {
+		t.Errorf("saved partial reply = %q", reply)
+	}
+}
+
+func TestChatStreamClientDisconnect(t *testing.T) {
+	h, router, body := newChatTestRouter(t, func(w http.ResponseWriter, r *http.Request) {
+		writeChunks(w, "Port ")
+		// 客户端断开后上游请求随之取消
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+			writeChunks(w, "8080")
+			fmt.Fprint(w, "data: [DONE]\n\n")
+		}
+	})
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/api/chat/stream", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var conversationID string
+	readEvents(t, resp.Body, func(ev sseEvent) bool {
+		if ev.name == "meta" {
+			conversationID = ev.data.ConversationID
+		}
+		return ev.name != "delta"
+	})
+	resp.Body.Close()
+
+	deadline := time.Now().Add(3 * time.Second)
+	for assistantReply(h, conversationID) == "" && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if reply := assistantReply(h, conversationID); reply != "Port " && reply != "Port" {
+		t.Errorf("saved partial reply = %q", reply)
+	}
+}
+
+func TestConversationStorageError(t *testing.T) {
+	h, router, _ := newChatTestRouter(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("LLM called despite a storage error")
+	})
+	store, err := storage.NewSQLiteStore(filepath.Join(t.TempDir(), "urlreader.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Create("conv", storage.Source{URL: "https://example.com", Title: "Example"}, nil)
+	store.Close()
+	h.conversations = store
+
+	// 存储出错时返回500，而不是会话不存在
+	for _, tt := range []struct{ method, path, body string }{
+		{http.MethodGet, "/api/history/conv", ""},
+		{http.MethodGet, "/api/conversations/conv/sources", ""},
+		{http.MethodPost, "/api/conversations/conv/sources", `{"url": "https://example.com/other"}`},
+		{http.MethodDelete, "/api/conversations/conv/sources/1", ""},
+		{http.MethodPost, "/api/chat", `{"conversation_id": "conv", "message": "hi", "model": "stub"}`},
+		{http.MethodPost, "/api/chat/stream", `{"conversation_id": "conv", "message": "hi", "model": "stub"}`},
+	} {
+		if w := serve(router, tt.method, tt.path, tt.body); w.Code != http.StatusInternalServerError {
+			t.Errorf("%s %s: status = %d, body = %s", tt.method, tt.path, w.Code, w.Body)
+		}
+	}
+}
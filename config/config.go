@@ -1,22 +1,108 @@
 package config
 
 import (
+	"encoding/json"
 	"os"
+	"strconv"
+	"strings"
 
-	"github.com/joho/godotenv"
 	"github.com/eust-w/urlreader/internal/logger"
+	"github.com/joho/godotenv"
 )
 
 // Config 存储应用程序配置
 type Config struct {
-	Port                 string
-	AzureOpenAIKey       string
-	AzureOpenAIEndpoint  string
+	Port                  string
+	AzureOpenAIKey        string
+	AzureOpenAIEndpoint   string
 	AzureOpenAIDeployment string
 	AzureOpenAIAPIVersion string
-	DeepseekAPIKey       string
-	DeepseekAPIEndpoint  string
-	DeepseekModel        string
+	// 各提供商的上下文窗口（token数），用于构建提示词时的预算
+	AzureOpenAIContextWindow int
+	DeepseekContextWindow    int
+	AnthropicContextWindow   int
+	DeepseekAPIKey           string
+	DeepseekAPIEndpoint      string
+	DeepseekModel            string
+	StorageBackend           string
+	StoragePath              string
+	ScraperExtractMode       string
+	OllamaEndpoint           string
+	OllamaModel              string
+	OllamaNumCtx             int
+	AnthropicAPIKey          string
+	AnthropicAPIEndpoint     string
+	AnthropicModel           string
+	AnthropicVersion         string
+	AnthropicMaxTokens       int
+	// LLMFallbacks 每个模型失败时依次尝试的后备模型，键为小写模型名
+	LLMFallbacks map[string][]string
+	// OpenAICompatibleProviders 从 LLM_PROVIDERS_FILE 加载的OpenAI兼容命名实例
+	OpenAICompatibleProviders []OpenAICompatibleConfig
+	// RAGTopK 每个问题注入的相关片段数，0 表示关闭检索
+	RAGTopK              int
+	RAGChunkSize         int
+	EmbeddingsProvider   string
+	EmbeddingsBaseURL    string
+	EmbeddingsModel      string
+	EmbeddingsAPIKey     string
+	EmbeddingsDimensions int
+	// CrawlMaxDepth、CrawlMaxPages 多页面抓取的默认范围，也是请求可指定的上限
+	CrawlMaxDepth int
+	CrawlMaxPages int
+	// FeedMaxArticles 订阅源模式最多抓取全文的文章数
+	FeedMaxArticles int
+	// ScraperUserAgent 抓取请求的 User-Agent 头
+	ScraperUserAgent string
+	// RobotsUserAgent 匹配 robots.txt 规则时使用的爬虫名称
+	RobotsUserAgent string
+	// RespectRobots 多页面抓取与 sitemap 发现时是否遵守 robots.txt
+	RespectRobots bool
+	// ScraperMaxBodyBytes 单个响应最多下载的字节数
+	ScraperMaxBodyBytes int
+	// ScraperMaxRedirects 单次抓取最多跟随的重定向次数
+	ScraperMaxRedirects int
+	// ScraperAllowedTypes 允许抓取的媒体类型，支持 "text/*" 形式的通配，为空时允许所有支持的类型
+	ScraperAllowedTypes []string
+	// ScraperTimeBudget 一次解析或添加来源请求中所有抓取的总时间上限（秒），包括多页面抓取与订阅源文章
+	ScraperTimeBudget int
+	// ScrapeCacheBackend 单页面抓取结果的缓存：memory（默认）、disk 或 none
+	ScrapeCacheBackend string
+	// ScrapeCachePath disk 缓存的目录
+	ScrapeCachePath string
+	// ScrapeCacheTTL 缓存有效期（秒），过期后向服务端重新验证
+	ScrapeCacheTTL int
+	// ScrapeCacheSize 缓存最多保存的条目数
+	ScrapeCacheSize int
+	// ScraperRulesFile 站点提取规则文件（JSON），修改后自动重新加载
+	ScraperRulesFile string
+	// SSRFProtection 是否禁止抓取回环、内网、链路本地与云元数据等地址
+	SSRFProtection bool
+	// SSRFAllowlist 防护开启时仍允许访问的主机名、IP 或 CIDR，如 "wiki.corp,10.1.0.0/16"
+	SSRFAllowlist []string
+}
+
+// OpenAICompatibleConfig 描述一个OpenAI兼容的LLM服务实例，
+// ChatRequest.Model 使用 Name 引用该实例
+type OpenAICompatibleConfig struct {
+	Name    string `json:"name"`
+	BaseURL string `json:"base_url"`
+	Model   string `json:"model"`
+	APIKey  string `json:"api_key"`
+	// APIKeyEnv 从指定环境变量读取密钥，避免在文件中保存明文
+	APIKeyEnv string `json:"api_key_env"`
+	// AuthHeader 认证头名称，默认 Authorization
+	AuthHeader string `json:"auth_header"`
+	// AuthScheme 认证头中密钥前的前缀，AuthHeader 为默认值时默认 Bearer
+	AuthScheme     string            `json:"auth_scheme"`
+	Headers        map[string]string `json:"headers"`
+	MaxTokens      int               `json:"max_tokens"`
+	Temperature    *float64          `json:"temperature"`
+	TimeoutSeconds int               `json:"timeout_seconds"`
+	// ContextWindow 上下文窗口token数，默认 8192
+	ContextWindow int `json:"context_window"`
+	// TokenFamily 分词器家族（gpt、claude、deepseek、llama），默认按模型名推断
+	TokenFamily string `json:"token_family"`
 }
 
 // LoadConfig 从环境变量加载配置
@@ -27,19 +113,170 @@ func LoadConfig() *Config {
 	}
 
 	config := &Config{
-		Port:                 getEnv("PORT", "8080"),
-		AzureOpenAIKey:       getEnv("AZURE_OPENAI_API_KEY", ""),
-		AzureOpenAIEndpoint:  getEnv("AZURE_OPENAI_ENDPOINT", ""),
-		AzureOpenAIDeployment: getEnv("AZURE_OPENAI_DEPLOYMENT", ""),
-		AzureOpenAIAPIVersion: getEnv("AZURE_OPENAI_API_VERSION", "2023-05-15"),
-		DeepseekAPIKey:       getEnv("DEEPSEEK_API_KEY", ""),
-		DeepseekAPIEndpoint:  getEnv("DEEPSEEK_API_ENDPOINT", "https://api.deepseek.com"),
-		DeepseekModel:        getEnv("DEEPSEEK_MODEL", "deepseek-chat"),
+		Port:                     getEnv("PORT", "8080"),
+		AzureOpenAIKey:           getEnv("AZURE_OPENAI_API_KEY", ""),
+		AzureOpenAIEndpoint:      getEnv("AZURE_OPENAI_ENDPOINT", ""),
+		AzureOpenAIDeployment:    getEnv("AZURE_OPENAI_DEPLOYMENT", ""),
+		AzureOpenAIAPIVersion:    getEnv("AZURE_OPENAI_API_VERSION", "2023-05-15"),
+		DeepseekAPIKey:           getEnv("DEEPSEEK_API_KEY", ""),
+		DeepseekAPIEndpoint:      getEnv("DEEPSEEK_API_ENDPOINT", "https://api.deepseek.com"),
+		DeepseekModel:            getEnv("DEEPSEEK_MODEL", "deepseek-chat"),
+		StorageBackend:           getEnv("STORAGE_BACKEND", "memory"),
+		StoragePath:              getEnv("STORAGE_PATH", "data/urlreader.db"),
+		ScraperExtractMode:       getEnv("SCRAPER_EXTRACT_MODE", "readability"),
+		OllamaEndpoint:           getEnv("OLLAMA_ENDPOINT", "http://localhost:11434"),
+		OllamaModel:              getEnv("OLLAMA_MODEL", "llama3.1"),
+		OllamaNumCtx:             getEnvInt("OLLAMA_NUM_CTX", 0),
+		AnthropicAPIKey:          getEnv("ANTHROPIC_API_KEY", ""),
+		AnthropicAPIEndpoint:     getEnv("ANTHROPIC_API_ENDPOINT", "https://api.anthropic.com"),
+		AnthropicModel:           getEnv("ANTHROPIC_MODEL", "claude-3-5-sonnet-latest"),
+		AnthropicVersion:         getEnv("ANTHROPIC_VERSION", "2023-06-01"),
+		AnthropicMaxTokens:       getEnvInt("ANTHROPIC_MAX_TOKENS", 2000),
+		AzureOpenAIContextWindow: getEnvInt("AZURE_OPENAI_CONTEXT_WINDOW", 128000),
+		DeepseekContextWindow:    getEnvInt("DEEPSEEK_CONTEXT_WINDOW", 64000),
+		AnthropicContextWindow:   getEnvInt("ANTHROPIC_CONTEXT_WINDOW", 200000),
+		RAGTopK:                  getEnvInt("RAG_TOP_K", 5),
+		RAGChunkSize:             getEnvInt("RAG_CHUNK_SIZE", 800),
+		EmbeddingsProvider:       getEnv("EMBEDDINGS_PROVIDER", "hash"),
+		EmbeddingsBaseURL:        getEnv("EMBEDDINGS_BASE_URL", ""),
+		EmbeddingsModel:          getEnv("EMBEDDINGS_MODEL", ""),
+		EmbeddingsAPIKey:         getEnv("EMBEDDINGS_API_KEY", ""),
+		EmbeddingsDimensions:     getEnvInt("EMBEDDINGS_DIMENSIONS", 512),
+		CrawlMaxDepth:            getEnvInt("CRAWL_MAX_DEPTH", 2),
+		CrawlMaxPages:            getEnvInt("CRAWL_MAX_PAGES", 20),
+		FeedMaxArticles:          getEnvInt("FEED_MAX_ARTICLES", 10),
+		ScraperUserAgent:         getEnv("SCRAPER_USER_AGENT", ""),
+		RobotsUserAgent:          getEnv("ROBOTS_USER_AGENT", "urlreader"),
+		RespectRobots:            getEnvBool("RESPECT_ROBOTS_TXT", true),
+		ScraperMaxBodyBytes:      getEnvInt("SCRAPER_MAX_BODY_BYTES", 50<<20),
+		ScraperMaxRedirects:      getEnvInt("SCRAPER_MAX_REDIRECTS", 10),
+		ScraperAllowedTypes:      getEnvList("SCRAPER_ALLOWED_TYPES"),
+		ScraperTimeBudget:        getEnvInt("SCRAPER_TIME_BUDGET", 120),
+		ScrapeCacheBackend:       getEnv("SCRAPE_CACHE", "memory"),
+		ScrapeCachePath:          getEnv("SCRAPE_CACHE_PATH", "data/cache"),
+		ScrapeCacheTTL:           getEnvInt("SCRAPE_CACHE_TTL", 600),
+		ScrapeCacheSize:          getEnvInt("SCRAPE_CACHE_SIZE", 500),
+		ScraperRulesFile:         getEnv("SCRAPER_RULES_FILE", ""),
+		SSRFProtection:           getEnvBool("SSRF_PROTECTION", true),
+		SSRFAllowlist:            getEnvList("SSRF_ALLOWLIST"),
 	}
 
+	config.LLMFallbacks = parseFallbacks(getEnv("LLM_FALLBACKS", "azure_openai=deepseek"))
+	config.OpenAICompatibleProviders = loadOpenAICompatibleProviders(getEnv("LLM_PROVIDERS_FILE", ""))
+
 	return config
 }
 
+// parseFallbacks 解析后备链配置，格式如 "azure_openai=deepseek,ollama;anthropic=deepseek"
+func parseFallbacks(value string) map[string][]string {
+	fallbacks := make(map[string][]string)
+	for _, rule := range strings.Split(value, ";") {
+		model, chain, ok := strings.Cut(rule, "=")
+		model = strings.ToLower(strings.TrimSpace(model))
+		if !ok || model == "" {
+			continue
+		}
+		for _, next := range strings.Split(chain, ",") {
+			if next = strings.TrimSpace(next); next != "" {
+				fallbacks[model] = append(fallbacks[model], next)
+			}
+		}
+	}
+	return fallbacks
+}
+
+// builtinProviderNames 内置提供商使用的模型名，需与 llm.LLMFactory.GetProvider 保持一致。
+// 同名的OpenAI兼容实例永远不会被选中，加载时直接忽略。
+var builtinProviderNames = map[string]bool{
+	"azure_openai": true, "azure": true, "openai": true, "deepseek": true,
+	"ollama": true, "anthropic": true, "claude": true,
+}
+
+// loadOpenAICompatibleProviders 从JSON文件加载OpenAI兼容实例，文件格式为实例数组
+func loadOpenAICompatibleProviders(path string) []OpenAICompatibleConfig {
+	if path == "" {
+		return nil
+	}
+	log := logger.GetLogger()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Errorw("读取LLM提供商配置文件失败", "path", path, "error", err)
+		return nil
+	}
+
+	var entries []OpenAICompatibleConfig
+	if err := json.Unmarshal(data, &entries); err != nil {
+		log.Errorw("解析LLM提供商配置文件失败", "path", path, "error", err)
+		return nil
+	}
+
+	var providers []OpenAICompatibleConfig
+	seen := make(map[string]bool)
+	for _, entry := range entries {
+		name := strings.ToLower(entry.Name)
+		if entry.Name == "" || entry.BaseURL == "" {
+			log.Warnw("忽略缺少 name 或 base_url 的LLM提供商配置", "name", entry.Name)
+			continue
+		}
+		if builtinProviderNames[name] {
+			log.Warnw("忽略与内置提供商重名的LLM提供商配置，请换用其他名称", "name", entry.Name)
+			continue
+		}
+		if seen[name] {
+			log.Warnw("忽略重名的LLM提供商配置", "name", entry.Name)
+			continue
+		}
+		seen[name] = true
+		if entry.APIKey == "" && entry.APIKeyEnv != "" {
+			entry.APIKey = os.Getenv(entry.APIKeyEnv)
+		}
+		providers = append(providers, entry)
+	}
+
+	log.Infow("已加载OpenAI兼容LLM提供商", "count", len(providers))
+	return providers
+}
+
+// getEnvInt 获取整数类型的环境变量，不存在或格式错误时返回默认值
+func getEnvInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		logger.GetLogger().Warnw("环境变量不是有效的整数，使用默认值", "key", key, "value", value, "default", defaultValue)
+		return defaultValue
+	}
+	return n
+}
+
+// getEnvBool 获取布尔类型的环境变量，不存在或格式错误时返回默认值
+func getEnvBool(key string, defaultValue bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		logger.GetLogger().Warnw("环境变量不是有效的布尔值，使用默认值", "key", key, "value", value, "default", defaultValue)
+		return defaultValue
+	}
+	return b
+}
+
+// getEnvList 获取逗号分隔的环境变量，去除空项
+func getEnvList(key string) []string {
+	var values []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
 // getEnv 获取环境变量，如果不存在则返回默认值
 func getEnv(key, defaultValue string) string {
 	value := os.Getenv(key)
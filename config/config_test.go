@@ -0,0 +1,36 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadOpenAICompatibleProviders(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "llm_providers.json")
+	t.Setenv("TEST_GATEWAY_KEY", "secret")
+	err := os.WriteFile(path, []byte(`[
+		{"name": "vllm", "base_url": "http://vllm:8000/v1", "model": "qwen"},
+		{"name": "gateway", "base_url": "https://gw.example.com/v1", "model": "gpt-4o-mini", "api_key_env": "TEST_GATEWAY_KEY"},
+		{"name": "VLLM", "base_url": "http://other:8000/v1", "model": "dup"},
+		{"name": "DeepSeek", "base_url": "http://proxy/v1", "model": "deepseek-chat"},
+		{"name": "claude", "base_url": "http://proxy/v1", "model": "claude"},
+		{"name": "openai", "base_url": "http://proxy/v1", "model": "gpt-4o"},
+		{"name": "nobase", "model": "x"}
+	]`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	providers := loadOpenAICompatibleProviders(path)
+	if len(providers) != 2 || providers[0].Name != "vllm" || providers[0].Model != "qwen" || providers[1].Name != "gateway" {
+		t.Fatalf("providers = %+v", providers)
+	}
+	if providers[1].APIKey != "secret" {
+		t.Errorf("api_key_env: api key = %q", providers[1].APIKey)
+	}
+
+	if providers := loadOpenAICompatibleProviders(filepath.Join(t.TempDir(), "missing.json")); providers != nil {
+		t.Errorf("missing file: providers = %+v", providers)
+	}
+}
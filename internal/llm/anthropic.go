@@ -0,0 +1,320 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/eust-w/urlreader/config"
+	"github.com/eust-w/urlreader/internal/logger"
+)
+
+// AnthropicProvider 实现了Anthropic Messages API
+type AnthropicProvider struct {
+	apiKey     string
+	endpoint   string
+	model      string
+	version    string
+	maxTokens  int
+	window     int
+	httpClient *http.Client
+}
+
+// NewAnthropicProvider 创建一个新的Anthropic提供商
+func NewAnthropicProvider(cfg *config.Config) *AnthropicProvider {
+	maxTokens := cfg.AnthropicMaxTokens
+	if maxTokens <= 0 {
+		// Messages API 要求必须提供 max_tokens
+		maxTokens = 2000
+	}
+	return &AnthropicProvider{
+		apiKey:     cfg.AnthropicAPIKey,
+		endpoint:   strings.TrimRight(cfg.AnthropicAPIEndpoint, "/"),
+		model:      cfg.AnthropicModel,
+		version:    cfg.AnthropicVersion,
+		maxTokens:  maxTokens,
+		window:     cfg.AnthropicContextWindow,
+		httpClient: &http.Client{Timeout: 120 * time.Second},
+	}
+}
+
+// Name 返回提供商名称
+func (p *AnthropicProvider) Name() string {
+	return "Anthropic"
+}
+
+// Limits 返回上下文窗口限制
+func (p *AnthropicProvider) Limits() ContextLimits {
+	return ContextLimits{Window: p.window, MaxOutput: p.maxTokens, Family: FamilyClaude}
+}
+
+// AnthropicMessage Messages API 中的单条消息，只允许 user 和 assistant 角色
+type AnthropicMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// AnthropicRequest Messages API 请求结构
+type AnthropicRequest struct {
+	Model       string             `json:"model"`
+	System      string             `json:"system,omitempty"`
+	Messages    []AnthropicMessage `json:"messages"`
+	MaxTokens   int                `json:"max_tokens"`
+	Temperature float64            `json:"temperature,omitempty"`
+	Stream      bool               `json:"stream,omitempty"`
+}
+
+// AnthropicError Messages API 的错误信封
+type AnthropicError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// AnthropicResponse Messages API 响应结构
+type AnthropicResponse struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	Role    string `json:"role"`
+	Model   string `json:"model"`
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	StopReason string          `json:"stop_reason"`
+	Error      *AnthropicError `json:"error,omitempty"`
+}
+
+// anthropicStreamEvent 流式响应中的单个事件
+type anthropicStreamEvent struct {
+	Type  string `json:"type"`
+	Delta struct {
+		Type       string `json:"type"`
+		Text       string `json:"text"`
+		StopReason string `json:"stop_reason"`
+	} `json:"delta"`
+	Error *AnthropicError `json:"error,omitempty"`
+}
+
+// toAnthropicMessages 把 system 消息提取到顶层 system 字段，
+// 并合并相邻的同角色消息以满足 user/assistant 交替的要求
+func toAnthropicMessages(messages []Message) (string, []AnthropicMessage) {
+	var system []string
+	var result []AnthropicMessage
+
+	for _, msg := range messages {
+		if msg.Role == "system" {
+			system = append(system, msg.Content)
+			continue
+		}
+		role := msg.Role
+		if role != "assistant" {
+			role = "user"
+		}
+		if n := len(result); n > 0 && result[n-1].Role == role {
+			result[n-1].Content += "\n\n" + msg.Content
+			continue
+		}
+		result = append(result, AnthropicMessage{Role: role, Content: msg.Content})
+	}
+
+	// 第一条消息必须来自 user
+	if len(result) > 0 && result[0].Role != "user" {
+		result = append([]AnthropicMessage{{Role: "user", Content: "(对话开始)"}}, result...)
+	}
+
+	return strings.Join(system, "\n\n"), result
+}
+
+// newRequest 构建发往Anthropic的请求
+func (p *AnthropicProvider) newRequest(ctx context.Context, messages []Message, stream bool) (*http.Request, error) {
+	url := fmt.Sprintf("%s/v1/messages", p.endpoint)
+
+	system, anthropicMessages := toAnthropicMessages(messages)
+	requestBody := AnthropicRequest{
+		Model:       p.model,
+		System:      system,
+		Messages:    anthropicMessages,
+		MaxTokens:   p.maxTokens,
+		Temperature: 0.7,
+		Stream:      stream,
+	}
+
+	jsonData, err := json.Marshal(requestBody)
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求失败: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", p.apiKey)
+	req.Header.Set("anthropic-version", p.version)
+	return req, nil
+}
+
+// anthropicErrorStatus 各错误类型对应的HTTP状态码，用于归类流式响应中没有状态码的 error 事件
+var anthropicErrorStatus = map[string]int{
+	"invalid_request_error": http.StatusBadRequest,
+	"authentication_error":  http.StatusUnauthorized,
+	"permission_error":      http.StatusForbidden,
+	"not_found_error":       http.StatusNotFound,
+	"request_too_large":     http.StatusRequestEntityTooLarge,
+	"rate_limit_error":      http.StatusTooManyRequests,
+	"api_error":             http.StatusInternalServerError,
+	"overloaded_error":      529,
+}
+
+// anthropicAPIError 把错误信封转换为类型化错误
+func (p *AnthropicProvider) anthropicAPIError(resp *http.Response, body []byte) error {
+	var envelope struct {
+		Error *AnthropicError `json:"error"`
+	}
+	if json.Unmarshal(body, &envelope) == nil && envelope.Error != nil {
+		return newAPIError(p.Name(), resp, envelope.Error.Type, envelope.Error.Message)
+	}
+	return newAPIError(p.Name(), resp, "", string(body))
+}
+
+// checkStopReason 根据 stop_reason 判断回复是否可用
+func checkStopReason(stopReason string) error {
+	switch stopReason {
+	case "refusal":
+		return fmt.Errorf("模型拒绝回答 (stop_reason: %s)", stopReason)
+	case "max_tokens":
+		logger.GetLogger().Warnw("Anthropic 回复因达到 max_tokens 被截断")
+	}
+	return nil
+}
+
+// Chat 使用Anthropic进行聊天
+func (p *AnthropicProvider) Chat(messages []Message) (string, error) {
+	req, err := p.newRequest(context.Background(), messages, false)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return "", newRequestError(p.Name(), err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("读取响应失败: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", p.anthropicAPIError(resp, body)
+	}
+
+	var response AnthropicResponse
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		return "", fmt.Errorf("解析响应失败: %w", err)
+	}
+
+	if response.Error != nil {
+		return "", newAPIError(p.Name(), resp, response.Error.Type, response.Error.Message)
+	}
+
+	var text strings.Builder
+	for _, block := range response.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+
+	if err := checkStopReason(response.StopReason); err != nil {
+		return "", err
+	}
+	if text.Len() == 0 {
+		return "", fmt.Errorf("API没有返回任何内容")
+	}
+
+	return text.String(), nil
+}
+
+// ChatStream 使用Anthropic进行流式聊天
+func (p *AnthropicProvider) ChatStream(ctx context.Context, messages []Message, onDelta func(delta string) error) (string, error) {
+	req, err := p.newRequest(ctx, messages, true)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := streamHTTPClient.Do(req)
+	if err != nil {
+		return "", newRequestError(p.Name(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", p.anthropicAPIError(resp, body)
+	}
+
+	return readAnthropicStream(p.Name(), resp.Body, onDelta)
+}
+
+// readAnthropicStream 解析Messages API的SSE流，出错时返回已收到的部分内容。
+// 流中的 error 事件（如 overloaded_error）按错误类型转换为类型化错误，未输出内容时可以切换后备提供商。
+func readAnthropicStream(provider string, r io.Reader, onDelta func(delta string) error) (string, error) {
+	var full strings.Builder
+	reader := bufio.NewReader(r)
+
+	for {
+		line, err := reader.ReadString('\n')
+		line = strings.TrimSpace(line)
+
+		if strings.HasPrefix(line, "data:") {
+			data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+
+			var event anthropicStreamEvent
+			if jsonErr := json.Unmarshal([]byte(data), &event); jsonErr != nil {
+				return full.String(), fmt.Errorf("解析流式响应失败: %w", jsonErr)
+			}
+
+			switch event.Type {
+			case "content_block_delta":
+				if event.Delta.Type == "text_delta" && event.Delta.Text != "" {
+					full.WriteString(event.Delta.Text)
+					if cbErr := onDelta(event.Delta.Text); cbErr != nil {
+						return full.String(), cbErr
+					}
+				}
+			case "message_delta":
+				if stopErr := checkStopReason(event.Delta.StopReason); stopErr != nil {
+					return full.String(), stopErr
+				}
+			case "message_stop":
+				return full.String(), nil
+			case "error":
+				if event.Error != nil {
+					return full.String(), &Error{
+						Kind:     classify(anthropicErrorStatus[event.Error.Type], event.Error.Type, event.Error.Message),
+						Provider: provider,
+						Message:  fmt.Sprintf("API错误: %s (%s)", event.Error.Message, event.Error.Type),
+					}
+				}
+				return full.String(), fmt.Errorf("API错误: %s", data)
+			}
+		}
+
+		if err != nil {
+			if err == io.EOF {
+				return full.String(), errStreamTruncated
+			}
+			return full.String(), fmt.Errorf("读取流式响应失败: %w", err)
+		}
+	}
+}
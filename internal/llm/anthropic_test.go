@@ -0,0 +1,202 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/eust-w/urlreader/config"
+)
+
+func TestToAnthropicMessages(t *testing.T) {
+	tests := []struct {
+		name     string
+		messages []Message
+		system   string
+		want     []AnthropicMessage
+	}{
+		{
+			name: "system lifted",
+			messages: []Message{
+				{Role: "system", Content: "sys"},
+				{Role: "user", Content: "content"},
+				{Role: "assistant", Content: "ack"},
+				{Role: "system", Content: "summary"},
+				{Role: "user", Content: "question"},
+			},
+			system: "sys\n\nsummary",
+			want:   []AnthropicMessage{{Role: "user", Content: "content"}, {Role: "assistant", Content: "ack"}, {Role: "user", Content: "question"}},
+		},
+		{
+			name: "same role merged",
+			messages: []Message{
+				{Role: "user", Content: "a"},
+				{Role: "user", Content: "b"},
+				{Role: "assistant", Content: "c"},
+				{Role: "assistant", Content: "d"},
+				{Role: "tool", Content: "e"},
+			},
+			want: []AnthropicMessage{{Role: "user", Content: "a\n\nb"}, {Role: "assistant", Content: "c\n\nd"}, {Role: "user", Content: "e"}},
+		},
+		{
+			name:     "leading user inserted",
+			messages: []Message{{Role: "system", Content: "sys"}, {Role: "assistant", Content: "hi"}, {Role: "user", Content: "q"}},
+			system:   "sys",
+			want:     []AnthropicMessage{{Role: "user", Content: "(对话开始)"}, {Role: "assistant", Content: "hi"}, {Role: "user", Content: "q"}},
+		},
+		{
+			name:     "system only",
+			messages: []Message{{Role: "system", Content: "sys"}},
+			system:   "sys",
+		},
+	}
+	for _, tt := range tests {
+		system, got := toAnthropicMessages(tt.messages)
+		if system != tt.system || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: system = %q, messages = %+v", tt.name, system, got)
+		}
+	}
+}
+
+// anthropicEvent 构造一个SSE事件
+func anthropicEvent(event, data string) string {
+	return "event: " + event + "\ndata: " + data + "\n\n"
+}
+
+func TestReadAnthropicStream(t *testing.T) {
+	start := anthropicEvent("message_start", `{"type":"message_start","message":{"id":"msg_1","role":"assistant","content":[]}}`) +
+		anthropicEvent("content_block_start", `{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`) +
+		anthropicEvent("ping", `{"type":"ping"}`) +
+		anthropicEvent("content_block_delta", `{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}`) +
+		anthropicEvent("content_block_delta", `{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":" world"}}`) +
+		anthropicEvent("content_block_stop", `{"type":"content_block_stop","index":0}`)
+	stop := func(reason string) string {
+		return anthropicEvent("message_delta", `{"type":"message_delta","delta":{"stop_reason":"`+reason+`"},"usage":{"output_tokens":2}}`) +
+			anthropicEvent("message_stop", `{"type":"message_stop"}`)
+	}
+
+	for _, reason := range []string{"end_turn", "max_tokens", "stop_sequence"} {
+		full, err := readAnthropicStream("Anthropic", strings.NewReader(start+stop(reason)), func(string) error { return nil })
+		if err != nil || full != "Hello world" {
+			t.Errorf("%s: full = %q, err = %v", reason, full, err)
+		}
+	}
+	if full, err := readAnthropicStream("Anthropic", strings.NewReader(start+stop("refusal")), func(string) error { return nil }); err == nil ||
+		!strings.Contains(err.Error(), "refusal") || full != "Hello world" {
+		t.Errorf("refusal: full = %q, err = %v", full, err)
+	}
+
+	// 流中的 error 事件转换为对应类别的类型化错误
+	for errType, kind := range map[string]ErrorKind{
+		"overloaded_error":      ErrServer,
+		"api_error":             ErrServer,
+		"rate_limit_error":      ErrRateLimited,
+		"authentication_error":  ErrAuth,
+		"invalid_request_error": ErrBadRequest,
+	} {
+		stream := start + anthropicEvent("error", `{"type":"error","error":{"type":"`+errType+`","message":"Something happened"}}`)
+		full, err := readAnthropicStream("Anthropic", strings.NewReader(stream), func(string) error { return nil })
+		llmErr, ok := AsError(err)
+		if full != "Hello world" || !ok || llmErr.Kind != kind || llmErr.Provider != "Anthropic" ||
+			err.Error() != "API错误: Something happened ("+errType+")" {
+			t.Errorf("%s: full = %q, err = %v", errType, full, err)
+		}
+	}
+}
+
+// newAnthropicTestServer 启动一个模拟 /v1/messages 的服务
+func newAnthropicTestServer(t *testing.T, handle func(w http.ResponseWriter, req AnthropicRequest)) *AnthropicProvider {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages" || r.Header.Get("x-api-key") != "key" || r.Header.Get("anthropic-version") != "2023-06-01" {
+			t.Errorf("unexpected request: %s %v", r.URL.Path, r.Header)
+		}
+		var req AnthropicRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		handle(w, req)
+	}))
+	t.Cleanup(srv.Close)
+	return NewAnthropicProvider(&config.Config{
+		AnthropicAPIKey:      "key",
+		AnthropicAPIEndpoint: srv.URL + "/",
+		AnthropicModel:       "claude-3-5-sonnet-latest",
+		AnthropicVersion:     "2023-06-01",
+	})
+}
+
+func TestAnthropicChat(t *testing.T) {
+	provider := newAnthropicTestServer(t, func(w http.ResponseWriter, req AnthropicRequest) {
+		if req.System != "sys" || len(req.Messages) != 1 || req.MaxTokens != 2000 || req.Stream {
+			t.Errorf("unexpected request: %+v", req)
+		}
+		fmt.Fprint(w, `{"id":"msg_1","type":"message","role":"assistant","content":[{"type":"text","text":"你好"}],"stop_reason":"end_turn"}`)
+	})
+	if got, err := provider.Chat([]Message{{Role: "system", Content: "sys"}, {Role: "user", Content: "hi"}}); err != nil || got != "你好" {
+		t.Errorf("Chat() = %q, %v", got, err)
+	}
+
+	provider = newAnthropicTestServer(t, func(w http.ResponseWriter, req AnthropicRequest) {
+		fmt.Fprint(w, `{"id":"msg_1","type":"message","role":"assistant","content":[],"stop_reason":"refusal"}`)
+	})
+	if _, err := provider.Chat([]Message{{Role: "user", Content: "hi"}}); err == nil || !strings.Contains(err.Error(), "refusal") {
+		t.Errorf("refusal: err = %v", err)
+	}
+
+	// 错误信封
+	for status, kind := range map[int]ErrorKind{529: ErrServer, 429: ErrRateLimited, 400: ErrContextTooLong} {
+		body := map[int]string{
+			529: `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`,
+			429: `{"type":"error","error":{"type":"rate_limit_error","message":"Number of request tokens has exceeded your rate limit"}}`,
+			400: `{"type":"error","error":{"type":"invalid_request_error","message":"prompt is too long: 210000 tokens > 200000 maximum"}}`,
+		}[status]
+		provider = newAnthropicTestServer(t, func(w http.ResponseWriter, req AnthropicRequest) {
+			w.WriteHeader(status)
+			fmt.Fprint(w, body)
+		})
+		_, err := provider.Chat([]Message{{Role: "user", Content: "hi"}})
+		if llmErr, ok := AsError(err); !ok || llmErr.Kind != kind || llmErr.StatusCode != status {
+			t.Errorf("%d: err = %v", status, err)
+		}
+	}
+}
+
+func TestAnthropicStreamErrorFallback(t *testing.T) {
+	primary := newAnthropicTestServer(t, func(w http.ResponseWriter, req AnthropicRequest) {
+		if !req.Stream {
+			t.Errorf("stream = false")
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, anthropicEvent("message_start", `{"type":"message_start","message":{"id":"msg_1"}}`)+
+			anthropicEvent("error", `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`))
+	})
+	backup := newCompatibleTestServer(t, "backup", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"from backup\"}}]}\n\ndata: [DONE]\n\n")
+	})
+	factory := NewLLMFactory(&config.Config{
+		AnthropicAPIKey:           "key",
+		AnthropicAPIEndpoint:      primary.endpoint,
+		AnthropicModel:            "claude-3-5-sonnet-latest",
+		AnthropicVersion:          "2023-06-01",
+		OpenAICompatibleProviders: []config.OpenAICompatibleConfig{backup},
+		LLMFallbacks:              map[string][]string{"anthropic": {"backup"}},
+	})
+
+	var skipped []Attempt
+	response, provider, _, err := factory.ChatStreamWithFallback(context.Background(), "anthropic",
+		func(LLMProvider) []Message { return []Message{{Role: "user", Content: "hi"}} },
+		func(_ LLMProvider, s []Attempt) error { skipped = s; return nil },
+		func(string) error { return nil })
+	if err != nil || response != "from backup" || provider.Name() != "backup" || len(skipped) != 1 {
+		t.Fatalf("response = %q, err = %v, skipped = %+v", response, err, skipped)
+	}
+	if llmErr, ok := AsError(skipped[0].Err); !ok || llmErr.Kind != ErrServer {
+		t.Errorf("skipped error = %v", skipped[0].Err)
+	}
+}
@@ -2,6 +2,7 @@ package llm
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -17,6 +18,7 @@ type AzureOpenAIProvider struct {
 	endpoint   string
 	deployment string
 	apiVersion string
+	window     int
 	httpClient *http.Client
 }
 
@@ -27,6 +29,7 @@ func NewAzureOpenAIProvider(cfg *config.Config) *AzureOpenAIProvider {
 		endpoint:   cfg.AzureOpenAIEndpoint,
 		deployment: cfg.AzureOpenAIDeployment,
 		apiVersion: cfg.AzureOpenAIAPIVersion,
+		window:     cfg.AzureOpenAIContextWindow,
 		httpClient: &http.Client{Timeout: 60 * time.Second},
 	}
 }
@@ -36,11 +39,17 @@ func (p *AzureOpenAIProvider) Name() string {
 	return "Azure OpenAI"
 }
 
+// Limits 返回上下文窗口限制
+func (p *AzureOpenAIProvider) Limits() ContextLimits {
+	return ContextLimits{Window: p.window, MaxOutput: 2000, Family: FamilyGPT}
+}
+
 // AzureOpenAIRequest Azure OpenAI API请求结构
 type AzureOpenAIRequest struct {
 	Messages    []Message `json:"messages"`
 	MaxTokens   int       `json:"max_tokens,omitempty"`
 	Temperature float64   `json:"temperature,omitempty"`
+	Stream      bool      `json:"stream,omitempty"`
 }
 
 // AzureOpenAIResponse Azure OpenAI API响应结构
@@ -62,8 +71,8 @@ type AzureOpenAIResponse struct {
 	} `json:"error,omitempty"`
 }
 
-// Chat 使用Azure OpenAI进行聊天
-func (p *AzureOpenAIProvider) Chat(messages []Message) (string, error) {
+// newRequest 构建发往Azure OpenAI的聊天请求
+func (p *AzureOpenAIProvider) newRequest(ctx context.Context, messages []Message, stream bool) (*http.Request, error) {
 	url := fmt.Sprintf("%s/openai/deployments/%s/chat/completions?api-version=%s",
 		p.endpoint, p.deployment, p.apiVersion)
 
@@ -71,24 +80,34 @@ func (p *AzureOpenAIProvider) Chat(messages []Message) (string, error) {
 		Messages:    messages,
 		MaxTokens:   2000,
 		Temperature: 0.7,
+		Stream:      stream,
 	}
 
 	jsonData, err := json.Marshal(requestBody)
 	if err != nil {
-		return "", fmt.Errorf("序列化请求失败: %w", err)
+		return nil, fmt.Errorf("序列化请求失败: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
-		return "", fmt.Errorf("创建请求失败: %w", err)
+		return nil, fmt.Errorf("创建请求失败: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("api-key", p.apiKey)
+	return req, nil
+}
+
+// Chat 使用Azure OpenAI进行聊天
+func (p *AzureOpenAIProvider) Chat(messages []Message) (string, error) {
+	req, err := p.newRequest(context.Background(), messages, false)
+	if err != nil {
+		return "", err
+	}
 
 	resp, err := p.httpClient.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("发送请求失败: %w", err)
+		return "", newRequestError(p.Name(), err)
 	}
 	defer resp.Body.Close()
 
@@ -98,7 +117,7 @@ func (p *AzureOpenAIProvider) Chat(messages []Message) (string, error) {
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("API错误: %s, 状态码: %d", string(body), resp.StatusCode)
+		return "", newAPIErrorFromBody(p.Name(), resp, body)
 	}
 
 	var response AzureOpenAIResponse
@@ -108,7 +127,7 @@ func (p *AzureOpenAIProvider) Chat(messages []Message) (string, error) {
 	}
 
 	if response.Error != nil {
-		return "", fmt.Errorf("API错误: %s", response.Error.Message)
+		return "", newAPIError(p.Name(), resp, fmt.Sprint(response.Error.Code), response.Error.Message)
 	}
 
 	if len(response.Choices) == 0 {
@@ -117,3 +136,25 @@ func (p *AzureOpenAIProvider) Chat(messages []Message) (string, error) {
 
 	return response.Choices[0].Message.Content, nil
 }
+
+// ChatStream 使用Azure OpenAI进行流式聊天
+func (p *AzureOpenAIProvider) ChatStream(ctx context.Context, messages []Message, onDelta func(delta string) error) (string, error) {
+	req, err := p.newRequest(ctx, messages, true)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := streamHTTPClient.Do(req)
+	if err != nil {
+		return "", newRequestError(p.Name(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", newAPIErrorFromBody(p.Name(), resp, body)
+	}
+
+	return readChatCompletionStream(resp.Body, onDelta)
+}
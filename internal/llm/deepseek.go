@@ -2,6 +2,7 @@ package llm
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -16,6 +17,7 @@ type DeepseekProvider struct {
 	apiKey     string
 	endpoint   string
 	model      string
+	window     int
 	httpClient *http.Client
 }
 
@@ -25,6 +27,7 @@ func NewDeepseekProvider(cfg *config.Config) *DeepseekProvider {
 		apiKey:     cfg.DeepseekAPIKey,
 		endpoint:   cfg.DeepseekAPIEndpoint,
 		model:      cfg.DeepseekModel,
+		window:     cfg.DeepseekContextWindow,
 		httpClient: &http.Client{Timeout: 60 * time.Second},
 	}
 }
@@ -34,12 +37,18 @@ func (p *DeepseekProvider) Name() string {
 	return "DeepSeek"
 }
 
+// Limits 返回上下文窗口限制
+func (p *DeepseekProvider) Limits() ContextLimits {
+	return ContextLimits{Window: p.window, MaxOutput: 2000, Family: FamilyDeepseek}
+}
+
 // DeepseekRequest DeepSeek API请求结构
 type DeepseekRequest struct {
 	Model       string    `json:"model"`
 	Messages    []Message `json:"messages"`
 	MaxTokens   int       `json:"max_tokens,omitempty"`
 	Temperature float64   `json:"temperature,omitempty"`
+	Stream      bool      `json:"stream,omitempty"`
 }
 
 // DeepseekResponse DeepSeek API响应结构
@@ -61,8 +70,8 @@ type DeepseekResponse struct {
 	} `json:"error,omitempty"`
 }
 
-// Chat 使用DeepSeek进行聊天
-func (p *DeepseekProvider) Chat(messages []Message) (string, error) {
+// newRequest 构建发往DeepSeek的聊天请求
+func (p *DeepseekProvider) newRequest(ctx context.Context, messages []Message, stream bool) (*http.Request, error) {
 	url := fmt.Sprintf("%s/v1/chat/completions", p.endpoint)
 
 	requestBody := DeepseekRequest{
@@ -70,24 +79,34 @@ func (p *DeepseekProvider) Chat(messages []Message) (string, error) {
 		Messages:    messages,
 		MaxTokens:   2000,
 		Temperature: 0.7,
+		Stream:      stream,
 	}
 
 	jsonData, err := json.Marshal(requestBody)
 	if err != nil {
-		return "", fmt.Errorf("序列化请求失败: %w", err)
+		return nil, fmt.Errorf("序列化请求失败: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
-		return "", fmt.Errorf("创建请求失败: %w", err)
+		return nil, fmt.Errorf("创建请求失败: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", p.apiKey))
+	return req, nil
+}
+
+// Chat 使用DeepSeek进行聊天
+func (p *DeepseekProvider) Chat(messages []Message) (string, error) {
+	req, err := p.newRequest(context.Background(), messages, false)
+	if err != nil {
+		return "", err
+	}
 
 	resp, err := p.httpClient.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("发送请求失败: %w", err)
+		return "", newRequestError(p.Name(), err)
 	}
 	defer resp.Body.Close()
 
@@ -97,7 +116,7 @@ func (p *DeepseekProvider) Chat(messages []Message) (string, error) {
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("API错误: %s, 状态码: %d", string(body), resp.StatusCode)
+		return "", newAPIErrorFromBody(p.Name(), resp, body)
 	}
 
 	var response DeepseekResponse
@@ -107,7 +126,7 @@ func (p *DeepseekProvider) Chat(messages []Message) (string, error) {
 	}
 
 	if response.Error != nil {
-		return "", fmt.Errorf("API错误: %s", response.Error.Message)
+		return "", newAPIError(p.Name(), resp, fmt.Sprint(response.Error.Code), response.Error.Message)
 	}
 
 	if len(response.Choices) == 0 {
@@ -116,3 +135,25 @@ func (p *DeepseekProvider) Chat(messages []Message) (string, error) {
 
 	return response.Choices[0].Message.Content, nil
 }
+
+// ChatStream 使用DeepSeek进行流式聊天
+func (p *DeepseekProvider) ChatStream(ctx context.Context, messages []Message, onDelta func(delta string) error) (string, error) {
+	req, err := p.newRequest(ctx, messages, true)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := streamHTTPClient.Do(req)
+	if err != nil {
+		return "", newRequestError(p.Name(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", newAPIErrorFromBody(p.Name(), resp, body)
+	}
+
+	return readChatCompletionStream(resp.Body, onDelta)
+}
@@ -0,0 +1,189 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrorKind 表示LLM调用失败的类别
+type ErrorKind string
+
+const (
+	// ErrRateLimited 触发速率限制或配额不足
+	ErrRateLimited ErrorKind = "rate_limited"
+	// ErrAuth 密钥无效或无权限
+	ErrAuth ErrorKind = "auth_failed"
+	// ErrContextTooLong 输入超出模型上下文窗口
+	ErrContextTooLong ErrorKind = "context_too_long"
+	// ErrServer 服务端错误或服务不可用
+	ErrServer ErrorKind = "server_error"
+	// ErrTimeout 请求超时
+	ErrTimeout ErrorKind = "timeout"
+	// ErrBadRequest 请求本身有误，换用其他提供商通常也无法解决
+	ErrBadRequest ErrorKind = "bad_request"
+	// ErrUnknown 无法归类的错误
+	ErrUnknown ErrorKind = "unknown"
+)
+
+// Error 是提供商返回的类型化错误
+type Error struct {
+	Kind       ErrorKind
+	Provider   string
+	StatusCode int
+	// RetryAfter 服务端建议的重试等待时间，仅在速率限制时可能有值
+	RetryAfter time.Duration
+	Message    string
+	Err        error
+}
+
+// Error 实现 error 接口
+func (e *Error) Error() string {
+	if e.StatusCode > 0 {
+		return fmt.Sprintf("API错误: %s, 状态码: %d", e.Message, e.StatusCode)
+	}
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	}
+	return e.Message
+}
+
+// Unwrap 返回底层错误
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// ShouldFallback 判断该错误是否值得换用下一个提供商重试
+func (e *Error) ShouldFallback() bool {
+	return e.Kind != ErrBadRequest
+}
+
+// AsError 从错误链中取出类型化错误
+func AsError(err error) (*Error, bool) {
+	var llmErr *Error
+	if errors.As(err, &llmErr) {
+		return llmErr, true
+	}
+	return nil, false
+}
+
+// contextTooLongHints 各家服务描述上下文超限时使用的措辞
+var contextTooLongHints = []string{
+	"context_length_exceeded",
+	"maximum context length",
+	"context length",
+	"context window",
+	"prompt is too long",
+	"too many tokens",
+	"input is too long",
+	"reduce the length",
+}
+
+// newAPIError 根据HTTP状态码、响应头与错误信息构建类型化错误
+func newAPIError(provider string, resp *http.Response, code, message string) *Error {
+	e := &Error{
+		Provider:   provider,
+		StatusCode: resp.StatusCode,
+		Message:    message,
+	}
+	e.Kind = classify(resp.StatusCode, code, message)
+	if e.Kind == ErrRateLimited {
+		e.RetryAfter = parseRetryAfter(resp.Header)
+	}
+	return e
+}
+
+// newAPIErrorFromBody 从OpenAI风格的错误响应体中提取错误码与信息后构建类型化错误
+func newAPIErrorFromBody(provider string, resp *http.Response, body []byte) *Error {
+	var envelope struct {
+		Error *struct {
+			Message string `json:"message"`
+			Type    string `json:"type"`
+			Code    any    `json:"code"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &envelope) == nil && envelope.Error != nil && envelope.Error.Message != "" {
+		code := envelope.Error.Type
+		if envelope.Error.Code != nil {
+			code = fmt.Sprint(envelope.Error.Code) + " " + code
+		}
+		return newAPIError(provider, resp, code, envelope.Error.Message)
+	}
+	return newAPIError(provider, resp, "", string(body))
+}
+
+// newRequestError 把发送请求阶段的网络错误包装为类型化错误
+func newRequestError(provider string, err error) error {
+	// 调用方主动取消时不包装，避免触发后备提供商
+	if errors.Is(err, context.Canceled) {
+		return err
+	}
+
+	kind := ErrServer
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		kind = ErrTimeout
+	}
+	return &Error{
+		Kind:     kind,
+		Provider: provider,
+		Message:  "发送请求失败",
+		Err:      err,
+	}
+}
+
+// classify 根据状态码与错误描述判断错误类别
+func classify(statusCode int, code, message string) ErrorKind {
+	text := strings.ToLower(code + " " + message)
+	for _, hint := range contextTooLongHints {
+		if strings.Contains(text, hint) {
+			return ErrContextTooLong
+		}
+	}
+
+	switch {
+	case statusCode == http.StatusTooManyRequests || strings.Contains(text, "rate_limit") || strings.Contains(text, "rate limit"):
+		return ErrRateLimited
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden ||
+		strings.Contains(text, "authentication") || strings.Contains(text, "invalid_api_key"):
+		return ErrAuth
+	case statusCode == http.StatusRequestTimeout || statusCode == http.StatusGatewayTimeout:
+		return ErrTimeout
+	case statusCode == http.StatusRequestEntityTooLarge:
+		return ErrContextTooLong
+	case statusCode >= 500 || strings.Contains(text, "overloaded") || strings.Contains(text, "server_error"):
+		return ErrServer
+	case statusCode >= 400:
+		return ErrBadRequest
+	}
+	return ErrUnknown
+}
+
+// parseRetryAfter 解析 Retry-After（秒数或HTTP日期）以及部分服务使用的 retry-after-ms
+func parseRetryAfter(header http.Header) time.Duration {
+	if ms := header.Get("retry-after-ms"); ms != "" {
+		if n, err := strconv.ParseFloat(ms, 64); err == nil && n > 0 {
+			return time.Duration(n * float64(time.Millisecond))
+		}
+	}
+
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if d := time.Until(at); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
@@ -0,0 +1,230 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/eust-w/urlreader/config"
+)
+
+// newCompatibleTestServer 启动一个模拟 /chat/completions 的服务，返回对应的命名实例配置
+func newCompatibleTestServer(t *testing.T, name string, handle http.HandlerFunc) config.OpenAICompatibleConfig {
+	t.Helper()
+	srv := httptest.NewServer(handle)
+	t.Cleanup(srv.Close)
+	return config.OpenAICompatibleConfig{Name: name, BaseURL: srv.URL + "/v1", Model: "test-model", APIKey: "key"}
+}
+
+func TestErrorClassification(t *testing.T) {
+	retryAt := time.Now().Add(90 * time.Second).UTC().Format(http.TimeFormat)
+	tests := []struct {
+		name       string
+		status     int
+		header     map[string]string
+		body       string
+		kind       ErrorKind
+		retryAfter time.Duration
+	}{
+		{"429 seconds", http.StatusTooManyRequests, map[string]string{"Retry-After": "20"},
+			`{"error":{"message":"Rate limit reached","type":"requests"}}`, ErrRateLimited, 20 * time.Second},
+		{"429 http date", http.StatusTooManyRequests, map[string]string{"Retry-After": retryAt},
+			`{"error":{"message":"Too many requests"}}`, ErrRateLimited, 90 * time.Second},
+		{"429 milliseconds", http.StatusTooManyRequests, map[string]string{"retry-after-ms": "1500", "Retry-After": "20"},
+			`{"error":{"message":"Too many requests"}}`, ErrRateLimited, 1500 * time.Millisecond},
+		{"401", http.StatusUnauthorized, nil,
+			`{"error":{"message":"Incorrect API key provided","type":"invalid_request_error","code":"invalid_api_key"}}`, ErrAuth, 0},
+		{"context length", http.StatusBadRequest, nil,
+			`{"error":{"message":"This model's maximum context length is 8192 tokens","type":"invalid_request_error","code":"context_length_exceeded"}}`, ErrContextTooLong, 0},
+		{"500", http.StatusInternalServerError, nil, `{"error":{"message":"The server had an error","type":"server_error"}}`, ErrServer, 0},
+		{"503 plain body", http.StatusServiceUnavailable, nil, "upstream unavailable", ErrServer, 0},
+		{"400", http.StatusBadRequest, nil, `{"error":{"message":"Invalid value for 'temperature'"}}`, ErrBadRequest, 0},
+	}
+	for _, tt := range tests {
+		instance := newCompatibleTestServer(t, "test", func(w http.ResponseWriter, r *http.Request) {
+			for k, v := range tt.header {
+				w.Header().Set(k, v)
+			}
+			w.WriteHeader(tt.status)
+			fmt.Fprint(w, tt.body)
+		})
+		_, err := NewOpenAICompatibleProvider(instance).Chat([]Message{{Role: "user", Content: "hi"}})
+		llmErr, ok := AsError(err)
+		if !ok {
+			t.Errorf("%s: err = %v, want *Error", tt.name, err)
+			continue
+		}
+		if llmErr.Kind != tt.kind || llmErr.StatusCode != tt.status || llmErr.Provider != "test" {
+			t.Errorf("%s: kind = %s, status = %d, provider = %q", tt.name, llmErr.Kind, llmErr.StatusCode, llmErr.Provider)
+		}
+		// HTTP日期精确到秒，允许与期望值相差数秒
+		if diff := llmErr.RetryAfter - tt.retryAfter; diff < -2*time.Second || diff > 0 {
+			t.Errorf("%s: retry after = %s, want %s", tt.name, llmErr.RetryAfter, tt.retryAfter)
+		}
+		if llmErr.ShouldFallback() == (tt.kind == ErrBadRequest) {
+			t.Errorf("%s: ShouldFallback() = %v", tt.name, llmErr.ShouldFallback())
+		}
+	}
+}
+
+func TestParseRetryAfterInvalid(t *testing.T) {
+	for _, value := range []string{"", "soon", "-5", "0", time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat)} {
+		header := http.Header{}
+		header.Set("Retry-After", value)
+		if got := parseRetryAfter(header); got != 0 {
+			t.Errorf("Retry-After %q: got %s, want 0", value, got)
+		}
+	}
+}
+
+// newChainFactory 创建后备链为 primary -> backup 的工厂，并统计 backup 收到的请求数
+func newChainFactory(t *testing.T, primary http.HandlerFunc) (*LLMFactory, *int) {
+	t.Helper()
+	calls := new(int)
+	backup := newCompatibleTestServer(t, "backup", func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"from backup"}}]}`)
+	})
+	return NewLLMFactory(&config.Config{
+		OpenAICompatibleProviders: []config.OpenAICompatibleConfig{newCompatibleTestServer(t, "primary", primary), backup},
+		LLMFallbacks:              map[string][]string{"primary": {"missing", "backup"}},
+	}), calls
+}
+
+func TestChatWithFallback(t *testing.T) {
+	build := func(LLMProvider) []Message { return []Message{{Role: "user", Content: "hi"}} }
+
+	factory, calls := newChainFactory(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "3")
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprint(w, `{"error":{"message":"Rate limit reached"}}`)
+	})
+	response, provider, attempts, err := factory.ChatWithFallback(context.Background(), "primary", build)
+	if err != nil || response != "from backup" || provider.Name() != "backup" || *calls != 1 {
+		t.Fatalf("rate limited: response = %q, err = %v, calls = %d", response, err, *calls)
+	}
+	if len(attempts) != 2 || attempts[0].Model != "primary" || attempts[1].Model != "missing" {
+		t.Fatalf("attempts = %+v", attempts)
+	}
+	if reason := attempts[0].Reason(); reason != "rate_limited (retry after 3s): API错误: Rate limit reached, 状态码: 429" {
+		t.Errorf("reason = %q", reason)
+	}
+
+	// 请求本身有误时不尝试后备提供商
+	factory, calls = newChainFactory(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error":{"message":"Invalid value for 'temperature'"}}`)
+	})
+	_, _, attempts, err = factory.ChatWithFallback(context.Background(), "primary", build)
+	if llmErr, ok := AsError(err); !ok || llmErr.Kind != ErrBadRequest || *calls != 0 || len(attempts) != 1 {
+		t.Errorf("bad request: err = %v, calls = %d, attempts = %d", err, *calls, len(attempts))
+	}
+
+	// 客户端断开后不再尝试后备提供商
+	factory, calls = newChainFactory(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, attempts, err = factory.ChatWithFallback(ctx, "primary", build); err == nil || *calls != 0 || len(attempts) != 1 {
+		t.Errorf("canceled: err = %v, calls = %d, attempts = %d", err, *calls, len(attempts))
+	}
+
+	// 所有提供商都失败时返回 FallbackError，Unwrap 得到最后一个错误
+	factory = NewLLMFactory(&config.Config{
+		OpenAICompatibleProviders: []config.OpenAICompatibleConfig{newCompatibleTestServer(t, "primary", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+		})},
+		LLMFallbacks: map[string][]string{"primary": {"missing"}},
+	})
+	_, _, attempts, err = factory.ChatWithFallback(context.Background(), "primary", build)
+	var fallbackErr *FallbackError
+	if !errors.As(err, &fallbackErr) || len(attempts) != 2 || errors.Unwrap(err) != attempts[1].Err {
+		t.Errorf("exhausted: err = %v, attempts = %+v", err, attempts)
+	}
+}
+
+func TestRunChainCommitted(t *testing.T) {
+	factory, calls := newChainFactory(t, func(w http.ResponseWriter, r *http.Request) {})
+	serverErr := &Error{Kind: ErrServer, Message: "overloaded"}
+
+	// 已经输出内容后出错时返回部分回复，不再切换到后备提供商
+	var tried []string
+	response, provider, attempts, err := factory.runChain(context.Background(), "primary", func(p LLMProvider, _ []Attempt) (string, bool, error) {
+		tried = append(tried, p.Name())
+		return "partial", true, serverErr
+	})
+	if err != serverErr || response != "partial" || provider.Name() != "primary" || len(tried) != 1 || len(attempts) != 1 {
+		t.Errorf("committed: response = %q, err = %v, tried = %v", response, err, tried)
+	}
+
+	// 尚未输出内容时切换，onStart 收到此前被跳过的尝试
+	tried = nil
+	response, provider, attempts, err = factory.runChain(context.Background(), "primary", func(p LLMProvider, skipped []Attempt) (string, bool, error) {
+		tried = append(tried, p.Name())
+		if p.Name() == "primary" {
+			return "", false, serverErr
+		}
+		if len(skipped) != 2 {
+			t.Errorf("skipped = %+v, want primary and missing", skipped)
+		}
+		return "ok", true, nil
+	})
+	if err != nil || response != "ok" || provider.Name() != "backup" || len(tried) != 2 || len(attempts) != 2 {
+		t.Errorf("not committed: response = %q, err = %v, tried = %v", response, err, tried)
+	}
+
+	// 调用方取消时不切换
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	tried = nil
+	_, _, _, err = factory.runChain(ctx, "primary", func(p LLMProvider, _ []Attempt) (string, bool, error) {
+		tried = append(tried, p.Name())
+		return "", false, serverErr
+	})
+	if err != serverErr || len(tried) != 1 || *calls != 0 {
+		t.Errorf("canceled: err = %v, tried = %v", err, tried)
+	}
+}
+
+func TestChatStreamWithFallbackAfterOutput(t *testing.T) {
+	factory, calls := newChainFactory(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"Hel\"}}]}\n\n")
+		fmt.Fprint(w, "data: {\"error\":{\"message\":\"The server is overloaded\",\"type\":\"server_error\"}}\n\n")
+	})
+	var deltas []string
+	started := 0
+	response, provider, _, err := factory.ChatStreamWithFallback(context.Background(), "primary",
+		func(LLMProvider) []Message { return []Message{{Role: "user", Content: "hi"}} },
+		func(LLMProvider, []Attempt) error { started++; return nil },
+		func(delta string) error { deltas = append(deltas, delta); return nil })
+	if err == nil || response != "Hel" || provider.Name() != "primary" || started != 1 || len(deltas) != 1 || *calls != 0 {
+		t.Errorf("response = %q, err = %v, started = %d, deltas = %v, backup calls = %d", response, err, started, deltas, *calls)
+	}
+}
+
+func TestChainAliases(t *testing.T) {
+	factory := NewLLMFactory(&config.Config{LLMFallbacks: map[string][]string{
+		"azure_openai": {"deepseek", "openai", "Claude"},
+		"claude":       {"anthropic", "ollama"},
+	}})
+	for model, want := range map[string][]string{
+		"azure_openai": {"azure_openai", "deepseek", "Claude"},
+		"azure":        {"azure", "deepseek", "Claude"},
+		"OpenAI":       {"OpenAI", "deepseek", "Claude"},
+		// 别名单独配置的后备链优先
+		"claude":    {"claude", "ollama"},
+		"anthropic": {"anthropic"},
+		"deepseek":  {"deepseek"},
+	} {
+		if got := factory.Chain(model); !reflect.DeepEqual(got, want) {
+			t.Errorf("Chain(%q) = %v, want %v", model, got, want)
+		}
+	}
+}
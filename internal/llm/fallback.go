@@ -0,0 +1,138 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/eust-w/urlreader/internal/logger"
+)
+
+// Attempt 记录后备链中一次被跳过的提供商及原因
+type Attempt struct {
+	Model string
+	Err   error
+}
+
+// Reason 返回跳过原因的简短描述
+func (a Attempt) Reason() string {
+	if llmErr, ok := AsError(a.Err); ok {
+		if llmErr.RetryAfter > 0 {
+			return fmt.Sprintf("%s (retry after %s): %s", llmErr.Kind, llmErr.RetryAfter, llmErr.Error())
+		}
+		return fmt.Sprintf("%s: %s", llmErr.Kind, llmErr.Error())
+	}
+	return a.Err.Error()
+}
+
+// FallbackError 后备链上所有提供商都失败时返回
+type FallbackError struct {
+	Attempts []Attempt
+}
+
+// Error 实现 error 接口
+func (e *FallbackError) Error() string {
+	reasons := make([]string, 0, len(e.Attempts))
+	for _, a := range e.Attempts {
+		reasons = append(reasons, fmt.Sprintf("%s: %s", a.Model, a.Err.Error()))
+	}
+	return strings.Join(reasons, "；")
+}
+
+// Unwrap 返回最后一个提供商的错误，便于调用方判断错误类别
+func (e *FallbackError) Unwrap() error {
+	if len(e.Attempts) == 0 {
+		return nil
+	}
+	return e.Attempts[len(e.Attempts)-1].Err
+}
+
+// Chain 返回模型及其配置的后备模型，按尝试顺序排列且不重复。
+// 没有为别名（如 azure、claude）单独配置后备链时使用规范名称的后备链。
+func (f *LLMFactory) Chain(model string) []string {
+	fallbacks, ok := f.config.LLMFallbacks[strings.ToLower(model)]
+	if !ok {
+		fallbacks = f.config.LLMFallbacks[canonicalName(model)]
+	}
+
+	chain := []string{model}
+	seen := map[string]bool{canonicalName(model): true}
+	for _, next := range fallbacks {
+		if seen[canonicalName(next)] {
+			continue
+		}
+		seen[canonicalName(next)] = true
+		chain = append(chain, next)
+	}
+	return chain
+}
+
+// PromptFunc 为指定提供商构建消息，使提示词能够适配各自的上下文窗口
+type PromptFunc func(provider LLMProvider) []Message
+
+// ChatWithFallback 按后备链依次调用提供商，返回回复、最终作答的提供商以及被跳过的尝试。
+// ctx 取消（如客户端断开）时不再尝试后续提供商。
+func (f *LLMFactory) ChatWithFallback(ctx context.Context, model string, build PromptFunc) (string, LLMProvider, []Attempt, error) {
+	return f.runChain(ctx, model, func(provider LLMProvider, _ []Attempt) (string, bool, error) {
+		response, err := provider.Chat(build(provider))
+		return response, false, err
+	})
+}
+
+// ChatStreamWithFallback 流式版本的后备链调用。
+// 只有在尚未输出任何内容时才会切换到下一个提供商；onStart 在某个提供商输出第一段内容前调用，
+// 同时传入此前被跳过的尝试。
+func (f *LLMFactory) ChatStreamWithFallback(ctx context.Context, model string, build PromptFunc,
+	onStart func(provider LLMProvider, skipped []Attempt) error, onDelta func(delta string) error) (string, LLMProvider, []Attempt, error) {
+	return f.runChain(ctx, model, func(provider LLMProvider, skipped []Attempt) (string, bool, error) {
+		started := false
+		response, err := provider.ChatStream(ctx, build(provider), func(delta string) error {
+			if !started {
+				started = true
+				if err := onStart(provider, skipped); err != nil {
+					return err
+				}
+			}
+			return onDelta(delta)
+		})
+		return response, started, err
+	})
+}
+
+// runChain 依次执行后备链。call 返回的 committed 为 true 表示已经向客户端输出内容，不能再切换。
+func (f *LLMFactory) runChain(ctx context.Context, model string, call func(LLMProvider, []Attempt) (string, bool, error)) (string, LLMProvider, []Attempt, error) {
+	log := logger.GetLogger()
+	var attempts []Attempt
+
+	for _, name := range f.Chain(model) {
+		provider, err := f.GetProvider(name)
+		if err != nil {
+			log.Warnw("跳过未配置的LLM提供商", "model", name, "error", err)
+			attempts = append(attempts, Attempt{Model: name, Err: err})
+			continue
+		}
+
+		response, committed, err := call(provider, attempts)
+		if err == nil {
+			if len(attempts) > 0 {
+				log.Infow("后备LLM提供商作答", "requested", model, "answered_by", provider.Name(), "skipped", len(attempts))
+			}
+			return response, provider, attempts, nil
+		}
+
+		attempts = append(attempts, Attempt{Model: name, Err: err})
+		if committed || ctx.Err() != nil || errors.Is(err, context.Canceled) {
+			return response, provider, attempts, err
+		}
+
+		llmErr, ok := AsError(err)
+		if !ok || !llmErr.ShouldFallback() {
+			return response, provider, attempts, err
+		}
+		log.Warnw("LLM提供商调用失败，尝试下一个提供商", "model", name, "kind", llmErr.Kind,
+			"status", llmErr.StatusCode, "retry_after", llmErr.RetryAfter, "error", err)
+	}
+
+	return "", nil, attempts, &FallbackError{Attempts: attempts}
+}
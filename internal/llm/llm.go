@@ -1,6 +1,7 @@
 package llm
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strings"
@@ -12,6 +13,9 @@ import (
 // LLMProvider 接口定义了所有LLM提供商必须实现的方法
 type LLMProvider interface {
 	Chat(messages []Message) (string, error)
+	// ChatStream 流式聊天，每收到一段增量内容调用一次onDelta，返回完整回复；
+	// 中途出错或被取消时返回已收到的部分内容和错误
+	ChatStream(ctx context.Context, messages []Message, onDelta func(delta string) error) (string, error)
 	Name() string
 }
 
@@ -37,6 +41,22 @@ func NewLLMFactory(cfg *config.Config) *LLMFactory {
 	}
 }
 
+// providerAliases 内置提供商的别名及其规范名称
+var providerAliases = map[string]string{
+	"azure":  "azure_openai",
+	"openai": "azure_openai",
+	"claude": "anthropic",
+}
+
+// canonicalName 返回模型名对应的规范提供商名称（小写），别名解析为规范名称
+func canonicalName(name string) string {
+	name = strings.ToLower(name)
+	if canonical, ok := providerAliases[name]; ok {
+		return canonical
+	}
+	return name
+}
+
 // GetProvider 根据名称返回相应的LLM提供商
 func (f *LLMFactory) GetProvider(name string) (LLMProvider, error) {
 	log := logger.GetLogger()
@@ -56,7 +76,27 @@ func (f *LLMFactory) GetProvider(name string) (LLMProvider, error) {
 		}
 		log.Infow("使用 Deepseek Provider")
 		return NewDeepseekProvider(f.config), nil
+	case "ollama":
+		if f.config.OllamaEndpoint == "" || f.config.OllamaModel == "" {
+			log.Errorw("Ollama 端点或模型未配置", "OllamaEndpoint", f.config.OllamaEndpoint, "OllamaModel", f.config.OllamaModel)
+			return nil, errors.New("Ollama 端点或模型未配置")
+		}
+		log.Infow("使用 Ollama Provider", "model", f.config.OllamaModel)
+		return NewOllamaProvider(f.config), nil
+	case "anthropic", "claude":
+		if f.config.AnthropicAPIKey == "" {
+			log.Errorw("Anthropic API密钥未配置")
+			return nil, errors.New("Anthropic API密钥未配置")
+		}
+		log.Infow("使用 Anthropic Provider", "model", f.config.AnthropicModel)
+		return NewAnthropicProvider(f.config), nil
 	default:
+		for _, instance := range f.config.OpenAICompatibleProviders {
+			if strings.EqualFold(instance.Name, name) {
+				log.Infow("使用 OpenAI 兼容 Provider", "name", instance.Name, "base_url", instance.BaseURL)
+				return NewOpenAICompatibleProvider(instance), nil
+			}
+		}
 		log.Errorw("不支持的LLM提供商", "name", name)
 		return nil, fmt.Errorf("不支持的LLM提供商: %s", name)
 	}
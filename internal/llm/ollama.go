@@ -0,0 +1,206 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/eust-w/urlreader/config"
+)
+
+// OllamaProvider 对接本地 Ollama 的原生 /api/chat 接口，内容不会离开本机
+type OllamaProvider struct {
+	endpoint   string
+	model      string
+	numCtx     int
+	httpClient *http.Client
+}
+
+// NewOllamaProvider 创建一个新的Ollama提供商
+func NewOllamaProvider(cfg *config.Config) *OllamaProvider {
+	return &OllamaProvider{
+		endpoint: strings.TrimRight(cfg.OllamaEndpoint, "/"),
+		model:    cfg.OllamaModel,
+		numCtx:   cfg.OllamaNumCtx,
+		// 本地推理较慢，超时比云端服务更宽松
+		httpClient: &http.Client{Timeout: 5 * time.Minute},
+	}
+}
+
+// Name 返回提供商名称
+func (p *OllamaProvider) Name() string {
+	return "Ollama"
+}
+
+// ollamaDefaultNumCtx 未设置 num_ctx 时 Ollama 使用的上下文窗口
+const ollamaDefaultNumCtx = 4096
+
+// Limits 返回上下文窗口限制
+func (p *OllamaProvider) Limits() ContextLimits {
+	window := p.numCtx
+	if window <= 0 {
+		window = ollamaDefaultNumCtx
+	}
+	return ContextLimits{Window: window, MaxOutput: 2000, Family: FamilyForModel(p.model)}
+}
+
+// OllamaOptions Ollama 模型参数
+type OllamaOptions struct {
+	NumCtx      int     `json:"num_ctx,omitempty"`
+	NumPredict  int     `json:"num_predict,omitempty"`
+	Temperature float64 `json:"temperature,omitempty"`
+}
+
+// OllamaRequest Ollama /api/chat 请求结构
+type OllamaRequest struct {
+	Model    string        `json:"model"`
+	Messages []Message     `json:"messages"`
+	Stream   bool          `json:"stream"`
+	Options  OllamaOptions `json:"options,omitempty"`
+}
+
+// OllamaResponse Ollama /api/chat 响应结构，流式模式下每行一个
+type OllamaResponse struct {
+	Model     string `json:"model"`
+	CreatedAt string `json:"created_at"`
+	Message   struct {
+		Role    string `json:"role"`
+		Content string `json:"content"`
+	} `json:"message"`
+	Done       bool   `json:"done"`
+	DoneReason string `json:"done_reason,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// newRequest 构建发往Ollama的聊天请求
+func (p *OllamaProvider) newRequest(ctx context.Context, messages []Message, stream bool) (*http.Request, error) {
+	url := fmt.Sprintf("%s/api/chat", p.endpoint)
+
+	requestBody := OllamaRequest{
+		Model:    p.model,
+		Messages: messages,
+		Stream:   stream,
+		Options: OllamaOptions{
+			NumCtx:      p.numCtx,
+			NumPredict:  2000,
+			Temperature: 0.7,
+		},
+	}
+
+	jsonData, err := json.Marshal(requestBody)
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求失败: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+// Chat 使用Ollama进行聊天
+func (p *OllamaProvider) Chat(messages []Message) (string, error) {
+	req, err := p.newRequest(context.Background(), messages, false)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return "", newRequestError(p.Name(), err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("读取响应失败: %w", err)
+	}
+
+	var response OllamaResponse
+	if jsonErr := json.Unmarshal(body, &response); jsonErr != nil {
+		if resp.StatusCode != http.StatusOK {
+			return "", newAPIError(p.Name(), resp, "", string(body))
+		}
+		return "", fmt.Errorf("解析响应失败: %w", jsonErr)
+	}
+
+	if response.Error != "" {
+		return "", newAPIError(p.Name(), resp, "", response.Error)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", newAPIError(p.Name(), resp, "", string(body))
+	}
+
+	return response.Message.Content, nil
+}
+
+// ChatStream 使用Ollama进行流式聊天，Ollama 以 NDJSON 逐行返回增量内容
+func (p *OllamaProvider) ChatStream(ctx context.Context, messages []Message, onDelta func(delta string) error) (string, error) {
+	req, err := p.newRequest(ctx, messages, true)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := streamHTTPClient.Do(req)
+	if err != nil {
+		return "", newRequestError(p.Name(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		var response OllamaResponse
+		if json.Unmarshal(body, &response) == nil && response.Error != "" {
+			return "", newAPIError(p.Name(), resp, "", response.Error)
+		}
+		return "", newAPIError(p.Name(), resp, "", string(body))
+	}
+
+	return readOllamaStream(resp.Body, onDelta)
+}
+
+// readOllamaStream 解析Ollama的NDJSON流，出错时返回已收到的部分内容
+func readOllamaStream(r io.Reader, onDelta func(delta string) error) (string, error) {
+	var full strings.Builder
+	reader := bufio.NewReader(r)
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		line = bytes.TrimSpace(line)
+
+		if len(line) > 0 {
+			var chunk OllamaResponse
+			if jsonErr := json.Unmarshal(line, &chunk); jsonErr != nil {
+				return full.String(), fmt.Errorf("解析流式响应失败: %w", jsonErr)
+			}
+			if chunk.Error != "" {
+				return full.String(), fmt.Errorf("API错误: %s", chunk.Error)
+			}
+			if chunk.Message.Content != "" {
+				full.WriteString(chunk.Message.Content)
+				if cbErr := onDelta(chunk.Message.Content); cbErr != nil {
+					return full.String(), cbErr
+				}
+			}
+			if chunk.Done {
+				return full.String(), nil
+			}
+		}
+
+		if err != nil {
+			if err == io.EOF {
+				return full.String(), errStreamTruncated
+			}
+			return full.String(), fmt.Errorf("读取流式响应失败: %w", err)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/eust-w/urlreader/config"
+)
+
+// newOllamaTestServer 启动一个模拟 Ollama /api/chat 的服务，并记录收到的请求
+func newOllamaTestServer(t *testing.T, handle func(w http.ResponseWriter, req OllamaRequest)) (*httptest.Server, *OllamaProvider) {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" || r.Method != http.MethodPost {
+			http.NotFound(w, r)
+			return
+		}
+		var req OllamaRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode request: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		handle(w, req)
+	}))
+	t.Cleanup(srv.Close)
+
+	provider := NewOllamaProvider(&config.Config{
+		OllamaEndpoint: srv.URL + "/",
+		OllamaModel:    "llama3.1",
+		OllamaNumCtx:   8192,
+	})
+	return srv, provider
+}
+
+func TestOllamaChat(t *testing.T) {
+	_, provider := newOllamaTestServer(t, func(w http.ResponseWriter, req OllamaRequest) {
+		if req.Model != "llama3.1" {
+			t.Errorf("model = %q, want llama3.1", req.Model)
+		}
+		if req.Stream {
+			t.Errorf("stream = true, want false")
+		}
+		if req.Options.NumCtx != 8192 {
+			t.Errorf("options.num_ctx = %d, want 8192", req.Options.NumCtx)
+		}
+		if len(req.Messages) != 2 || req.Messages[0].Role != "system" {
+			t.Errorf("unexpected messages: %+v", req.Messages)
+		}
+		fmt.Fprint(w, `{"model":"llama3.1","created_at":"2024-01-01T00:00:00Z","message":{"role":"assistant","content":"你好"},"done":true,"done_reason":"stop"}`)
+	})
+
+	got, err := provider.Chat([]Message{
+		{Role: "system", Content: "sys"},
+		{Role: "user", Content: "hi"},
+	})
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if got != "你好" {
+		t.Errorf("Chat() = %q, want 你好", got)
+	}
+}
+
+func TestOllamaChatError(t *testing.T) {
+	_, provider := newOllamaTestServer(t, func(w http.ResponseWriter, req OllamaRequest) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error":"model \"llama3.1\" not found, try pulling it first"}`)
+	})
+
+	_, err := provider.Chat([]Message{{Role: "user", Content: "hi"}})
+	if err == nil {
+		t.Fatal("Chat() error = nil, want error")
+	}
+	if !strings.Contains(err.Error(), "not found") || !strings.Contains(err.Error(), "404") {
+		t.Errorf("Chat() error = %v, want upstream message and status", err)
+	}
+}
+
+func TestOllamaChatStream(t *testing.T) {
+	_, provider := newOllamaTestServer(t, func(w http.ResponseWriter, req OllamaRequest) {
+		if !req.Stream {
+			t.Errorf("stream = false, want true")
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		for _, part := range []string{"Hel", "lo", " world"} {
+			fmt.Fprintf(w, `{"model":"llama3.1","message":{"role":"assistant","content":%q},"done":false}`+"\n", part)
+			w.(http.Flusher).Flush()
+		}
+		fmt.Fprint(w, `{"model":"llama3.1","message":{"role":"assistant","content":""},"done":true,"done_reason":"stop"}`+"\n")
+	})
+
+	var deltas []string
+	got, err := provider.ChatStream(context.Background(), []Message{{Role: "user", Content: "hi"}}, func(delta string) error {
+		deltas = append(deltas, delta)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ChatStream() error = %v", err)
+	}
+	if got != "Hello world" {
+		t.Errorf("ChatStream() = %q, want %q", got, "Hello world")
+	}
+	if strings.Join(deltas, "|") != "Hel|lo| world" {
+		t.Errorf("deltas = %q", deltas)
+	}
+}
+
+func TestOllamaChatStreamMidStreamError(t *testing.T) {
+	_, provider := newOllamaTestServer(t, func(w http.ResponseWriter, req OllamaRequest) {
+		fmt.Fprint(w, `{"message":{"role":"assistant","content":"partial"},"done":false}`+"\n")
+		fmt.Fprint(w, `{"error":"out of memory"}`+"\n")
+	})
+
+	got, err := provider.ChatStream(context.Background(), []Message{{Role: "user", Content: "hi"}}, func(string) error { return nil })
+	if err == nil || !strings.Contains(err.Error(), "out of memory") {
+		t.Fatalf("ChatStream() error = %v, want out of memory", err)
+	}
+	if got != "partial" {
+		t.Errorf("ChatStream() partial = %q, want %q", got, "partial")
+	}
+}
+
+func TestOllamaChatStreamAbort(t *testing.T) {
+	_, provider := newOllamaTestServer(t, func(w http.ResponseWriter, req OllamaRequest) {
+		fmt.Fprint(w, `{"message":{"role":"assistant","content":"a"},"done":false}`+"\n")
+		fmt.Fprint(w, `{"message":{"role":"assistant","content":"b"},"done":false}`+"\n")
+		fmt.Fprint(w, `{"message":{"role":"assistant","content":""},"done":true}`+"\n")
+	})
+
+	stop := fmt.Errorf("client gone")
+	got, err := provider.ChatStream(context.Background(), []Message{{Role: "user", Content: "hi"}}, func(string) error { return stop })
+	if err != stop {
+		t.Fatalf("ChatStream() error = %v, want %v", err, stop)
+	}
+	if got != "a" {
+		t.Errorf("ChatStream() partial = %q, want %q", got, "a")
+	}
+}
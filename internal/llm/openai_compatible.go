@@ -0,0 +1,210 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/eust-w/urlreader/config"
+)
+
+// OpenAICompatibleProvider 对接任意实现了 /v1/chat/completions 的服务，
+// 如 vLLM、LM Studio 以及各类OpenAI兼容网关
+type OpenAICompatibleProvider struct {
+	name        string
+	baseURL     string
+	model       string
+	apiKey      string
+	authHeader  string
+	authScheme  string
+	headers     map[string]string
+	maxTokens   int
+	temperature float64
+	window      int
+	family      TokenFamily
+	httpClient  *http.Client
+}
+
+// NewOpenAICompatibleProvider 根据命名实例配置创建OpenAI兼容提供商
+func NewOpenAICompatibleProvider(cfg config.OpenAICompatibleConfig) *OpenAICompatibleProvider {
+	authHeader := cfg.AuthHeader
+	authScheme := cfg.AuthScheme
+	if authHeader == "" {
+		authHeader = "Authorization"
+		if authScheme == "" {
+			authScheme = "Bearer"
+		}
+	}
+
+	maxTokens := cfg.MaxTokens
+	if maxTokens == 0 {
+		maxTokens = 2000
+	}
+	temperature := 0.7
+	if cfg.Temperature != nil {
+		temperature = *cfg.Temperature
+	}
+	family := TokenFamily(strings.ToLower(cfg.TokenFamily))
+	if _, ok := tokenRatios[family]; !ok {
+		family = FamilyForModel(cfg.Model)
+	}
+	timeout := 60 * time.Second
+	if cfg.TimeoutSeconds > 0 {
+		timeout = time.Duration(cfg.TimeoutSeconds) * time.Second
+	}
+
+	return &OpenAICompatibleProvider{
+		name:        cfg.Name,
+		baseURL:     strings.TrimRight(cfg.BaseURL, "/"),
+		model:       cfg.Model,
+		apiKey:      cfg.APIKey,
+		authHeader:  authHeader,
+		authScheme:  authScheme,
+		headers:     cfg.Headers,
+		maxTokens:   maxTokens,
+		temperature: temperature,
+		window:      cfg.ContextWindow,
+		family:      family,
+		httpClient:  &http.Client{Timeout: timeout},
+	}
+}
+
+// Name 返回提供商名称
+func (p *OpenAICompatibleProvider) Name() string {
+	return p.name
+}
+
+// Limits 返回上下文窗口限制
+func (p *OpenAICompatibleProvider) Limits() ContextLimits {
+	return ContextLimits{Window: p.window, MaxOutput: p.maxTokens, Family: p.family}
+}
+
+// OpenAICompatibleRequest OpenAI兼容接口请求结构
+type OpenAICompatibleRequest struct {
+	Model       string    `json:"model,omitempty"`
+	Messages    []Message `json:"messages"`
+	MaxTokens   int       `json:"max_tokens,omitempty"`
+	Temperature float64   `json:"temperature"`
+	Stream      bool      `json:"stream,omitempty"`
+}
+
+// OpenAICompatibleResponse OpenAI兼容接口响应结构
+type OpenAICompatibleResponse struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	Choices []struct {
+		Message struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		} `json:"message"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Error *struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+		Code    any    `json:"code"`
+	} `json:"error,omitempty"`
+}
+
+// newRequest 构建发往OpenAI兼容服务的聊天请求
+func (p *OpenAICompatibleProvider) newRequest(ctx context.Context, messages []Message, stream bool) (*http.Request, error) {
+	url := p.baseURL + "/chat/completions"
+
+	requestBody := OpenAICompatibleRequest{
+		Model:       p.model,
+		Messages:    messages,
+		MaxTokens:   p.maxTokens,
+		Temperature: p.temperature,
+		Stream:      stream,
+	}
+
+	jsonData, err := json.Marshal(requestBody)
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求失败: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range p.headers {
+		req.Header.Set(key, value)
+	}
+	if p.apiKey != "" {
+		value := p.apiKey
+		if p.authScheme != "" {
+			value = p.authScheme + " " + p.apiKey
+		}
+		req.Header.Set(p.authHeader, value)
+	}
+	return req, nil
+}
+
+// Chat 使用OpenAI兼容服务进行聊天
+func (p *OpenAICompatibleProvider) Chat(messages []Message) (string, error) {
+	req, err := p.newRequest(context.Background(), messages, false)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return "", newRequestError(p.Name(), err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("读取响应失败: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", newAPIErrorFromBody(p.Name(), resp, body)
+	}
+
+	var response OpenAICompatibleResponse
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		return "", fmt.Errorf("解析响应失败: %w", err)
+	}
+
+	if response.Error != nil {
+		return "", newAPIError(p.Name(), resp, fmt.Sprint(response.Error.Code), response.Error.Message)
+	}
+
+	if len(response.Choices) == 0 {
+		return "", fmt.Errorf("API没有返回任何选择")
+	}
+
+	return response.Choices[0].Message.Content, nil
+}
+
+// ChatStream 使用OpenAI兼容服务进行流式聊天
+func (p *OpenAICompatibleProvider) ChatStream(ctx context.Context, messages []Message, onDelta func(delta string) error) (string, error) {
+	req, err := p.newRequest(ctx, messages, true)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := streamHTTPClient.Do(req)
+	if err != nil {
+		return "", newRequestError(p.Name(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", newAPIErrorFromBody(p.Name(), resp, body)
+	}
+
+	return readChatCompletionStream(resp.Body, onDelta)
+}
@@ -0,0 +1,80 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/eust-w/urlreader/config"
+)
+
+func TestOpenAICompatibleRequest(t *testing.T) {
+	var requests []*http.Request
+	var bodies []OpenAICompatibleRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req OpenAICompatibleRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		requests = append(requests, r)
+		bodies = append(bodies, req)
+		if req.Stream {
+			fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"ok\"}}]}\n\ndata: [DONE]\n\n")
+			return
+		}
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`)
+	}))
+	defer srv.Close()
+
+	temperature := 0.2
+	factory := NewLLMFactory(&config.Config{OpenAICompatibleProviders: []config.OpenAICompatibleConfig{
+		{Name: "vllm", BaseURL: srv.URL + "/vllm/v1/", Model: "Qwen/Qwen2.5-7B-Instruct", APIKey: "token"},
+		{Name: "Gateway", BaseURL: srv.URL + "/gw/openai/v1", Model: "gpt-4o-mini", APIKey: "secret",
+			AuthHeader: "api-key", Headers: map[string]string{"X-Team": "urlreader"}, MaxTokens: 512, Temperature: &temperature},
+	}})
+	messages := []Message{{Role: "user", Content: "hi"}}
+
+	tests := []struct {
+		name, path, model, header, value string
+		maxTokens                        int
+		temperature                      float64
+	}{
+		{"vllm", "/vllm/v1/chat/completions", "Qwen/Qwen2.5-7B-Instruct", "Authorization", "Bearer token", 2000, 0.7},
+		{"gateway", "/gw/openai/v1/chat/completions", "gpt-4o-mini", "api-key", "secret", 512, 0.2},
+	}
+	for _, tt := range tests {
+		provider, err := factory.GetProvider(tt.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := provider.Chat(messages); err != nil || got != "ok" {
+			t.Fatalf("%s: Chat() = %q, %v", tt.name, got, err)
+		}
+		if got, err := provider.ChatStream(context.Background(), messages, func(string) error { return nil }); err != nil || got != "ok" {
+			t.Fatalf("%s: ChatStream() = %q, %v", tt.name, got, err)
+		}
+
+		for i, r := range requests {
+			body := bodies[i]
+			if r.Method != http.MethodPost || r.URL.Path != tt.path || r.Header.Get(tt.header) != tt.value ||
+				r.Header.Get("Content-Type") != "application/json" {
+				t.Errorf("%s: %s %s, headers = %v", tt.name, r.Method, r.URL.Path, r.Header)
+			}
+			if body.Model != tt.model || body.MaxTokens != tt.maxTokens || body.Temperature != tt.temperature ||
+				len(body.Messages) != 1 || body.Stream != (i == 1) {
+				t.Errorf("%s: body = %+v", tt.name, body)
+			}
+		}
+		if tt.header != "Authorization" && (requests[0].Header.Get("Authorization") != "" || requests[0].Header.Get("X-Team") != "urlreader") {
+			t.Errorf("%s: headers = %v", tt.name, requests[0].Header)
+		}
+		requests, bodies = nil, nil
+	}
+
+	if _, err := factory.GetProvider("unknown"); err == nil {
+		t.Error("GetProvider(unknown) succeeded")
+	}
+}
@@ -0,0 +1,90 @@
+package llm
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// streamHTTPClient 用于流式请求，不设置整体超时，由调用方的context控制生命周期
+var streamHTTPClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		ResponseHeaderTimeout: 60 * time.Second,
+	},
+}
+
+// errStreamTruncated 连接在流式响应的结束标记之前关闭，已收到的回复可能不完整
+var errStreamTruncated = errors.New("流式响应意外结束")
+
+// chatCompletionChunk OpenAI兼容接口 stream: true 时返回的单个数据块
+type chatCompletionChunk struct {
+	Choices []struct {
+		Delta struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		} `json:"delta"`
+		FinishReason *string `json:"finish_reason"`
+	} `json:"choices"`
+	Error *struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+		Code    any    `json:"code"`
+	} `json:"error,omitempty"`
+}
+
+// readChatCompletionStream 解析OpenAI兼容的SSE流，逐段回调增量内容并返回完整回复。
+// 部分兼容服务不发送 [DONE]，收到 finish_reason 后连接关闭视为正常结束；
+// 出错或在结束前连接关闭时仍返回已收到的内容，便于调用方保存部分回复。
+func readChatCompletionStream(r io.Reader, onDelta func(delta string) error) (string, error) {
+	var full strings.Builder
+	reader := bufio.NewReader(r)
+	finished := false
+
+	for {
+		line, err := reader.ReadString('\n')
+		line = strings.TrimSpace(line)
+
+		if strings.HasPrefix(line, "data:") {
+			data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+			if data == "[DONE]" {
+				return full.String(), nil
+			}
+
+			var chunk chatCompletionChunk
+			if jsonErr := json.Unmarshal([]byte(data), &chunk); jsonErr != nil {
+				return full.String(), fmt.Errorf("解析流式响应失败: %w", jsonErr)
+			}
+			if chunk.Error != nil {
+				return full.String(), fmt.Errorf("API错误: %s", chunk.Error.Message)
+			}
+			for _, choice := range chunk.Choices {
+				if choice.FinishReason != nil && *choice.FinishReason != "" {
+					finished = true
+				}
+				if choice.Delta.Content == "" {
+					continue
+				}
+				full.WriteString(choice.Delta.Content)
+				if cbErr := onDelta(choice.Delta.Content); cbErr != nil {
+					return full.String(), cbErr
+				}
+			}
+		}
+
+		if err != nil {
+			if err == io.EOF {
+				if finished {
+					return full.String(), nil
+				}
+				return full.String(), errStreamTruncated
+			}
+			return full.String(), fmt.Errorf("读取流式响应失败: %w", err)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package llm
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestReadChatCompletionStream(t *testing.T) {
+	const deltas = "data: {\"choices\":[{\"delta\":{\"role\":\"assistant\"}}]}\n\n" +
+		": keep-alive\n\n" +
+		"data: {\"choices\":[{\"delta\":{\"content\":\"你好\"}}]}\n\n" +
+		"data:{\"choices\":[{\"delta\":{\"content\":\"，世界\"},\"finish_reason\":null}]}\n\n" +
+		"data: {\"choices\":[{\"delta\":{},\"finish_reason\":\"stop\"}]}\n\n"
+	tests := []struct {
+		name    string
+		stream  string
+		want    string
+		wantErr string
+	}{
+		{"done", deltas + "data: [DONE]\n\n", "你好，世界", ""},
+		{"done without trailing newline", deltas + "data: [DONE]", "你好，世界", ""},
+		// 部分兼容服务在 finish_reason 之后直接关闭连接，不发送 [DONE]
+		{"finished without done", deltas, "你好，世界", ""},
+		{"truncated", deltas[:strings.Index(deltas, "data: {\"choices\":[{\"delta\":{},")], "你好，世界", "流式响应意外结束"},
+		{"null finish reason", "data:{\"choices\":[{\"delta\":{\"content\":\"你好\"},\"finish_reason\":null}]}\n\n", "你好", "流式响应意外结束"},
+		{"empty", "", "", "流式响应意外结束"},
+		{"error chunk", deltas + "data: {\"error\":{\"message\":\"The server is overloaded\",\"type\":\"server_error\"}}\n\n",
+			"你好，世界", "API错误: The server is overloaded"},
+		{"invalid chunk", deltas + "data: {not json}\n\n", "你好，世界", "解析流式响应失败"},
+	}
+	for _, tt := range tests {
+		var got []string
+		full, err := readChatCompletionStream(strings.NewReader(tt.stream), func(delta string) error {
+			got = append(got, delta)
+			return nil
+		})
+		if full != tt.want || strings.Join(got, "") != tt.want {
+			t.Errorf("%s: full = %q, deltas = %q, want %q", tt.name, full, got, tt.want)
+		}
+		if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestReadChatCompletionStreamAbort(t *testing.T) {
+	stream := "data: {\"choices\":[{\"delta\":{\"content\":\"Hel\"}}]}\n\n" +
+		"data: {\"choices\":[{\"delta\":{\"content\":\"lo\"}}]}\n\n" +
+		"data: {\"choices\":[{\"delta\":{\"content\":\" world\"}}]}\n\n" +
+		"data: [DONE]\n\n"
+
+	// 回调出错（如客户端断开）时停止读取，返回已收到的内容
+	aborted := errors.New("client gone")
+	calls := 0
+	full, err := readChatCompletionStream(strings.NewReader(stream), func(delta string) error {
+		calls++
+		if calls == 2 {
+			return aborted
+		}
+		return nil
+	})
+	if err != aborted || full != "Hello" || calls != 2 {
+		t.Errorf("abort: full = %q, err = %v, calls = %d", full, err, calls)
+	}
+
+	// 连接中途出错时返回部分内容
+	broken := io.MultiReader(strings.NewReader(stream[:strings.Index(stream, "\n\n")+2]), iotest.ErrReader(io.ErrUnexpectedEOF))
+	full, err = readChatCompletionStream(broken, func(string) error { return nil })
+	if full != "Hel" || err == nil || !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("read error: full = %q, err = %v", full, err)
+	}
+}
+
+func TestStreamTruncatedConsistent(t *testing.T) {
+	noop := func(string) error { return nil }
+	readers := map[string]func(io.Reader, func(string) error) (string, error){
+		"chat completion": readChatCompletionStream,
+		"anthropic": func(r io.Reader, onDelta func(string) error) (string, error) {
+			return readAnthropicStream("Anthropic", r, onDelta)
+		},
+		"ollama": readOllamaStream,
+	}
+	streams := map[string]string{
+		"chat completion": "data: {\"choices\":[{\"delta\":{\"content\":\"partial\"}}]}\n\n",
+		"anthropic":       "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"partial\"}}\n\n",
+		"ollama":          "{\"message\":{\"role\":\"assistant\",\"content\":\"partial\"},\"done\":false}\n",
+	}
+	for name, read := range readers {
+		full, err := read(strings.NewReader(streams[name]), noop)
+		if full != "partial" || !errors.Is(err, errStreamTruncated) {
+			t.Errorf("%s: full = %q, err = %v", name, full, err)
+		}
+	}
+}
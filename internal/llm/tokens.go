@@ -0,0 +1,122 @@
+package llm
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// TokenFamily 表示分词器家族，不同家族对中英文的切分粒度差异较大
+type TokenFamily string
+
+const (
+	FamilyGPT      TokenFamily = "gpt"
+	FamilyClaude   TokenFamily = "claude"
+	FamilyDeepseek TokenFamily = "deepseek"
+	FamilyLlama    TokenFamily = "llama"
+)
+
+// tokenRatio 描述某个分词器家族的平均切分粒度
+type tokenRatio struct {
+	// charsPerToken 非CJK文本平均每个token对应的字符数
+	charsPerToken float64
+	// tokensPerCJK 每个中日韩字符平均占用的token数
+	tokensPerCJK float64
+}
+
+var tokenRatios = map[TokenFamily]tokenRatio{
+	FamilyGPT:      {charsPerToken: 4.0, tokensPerCJK: 1.0},
+	FamilyClaude:   {charsPerToken: 3.5, tokensPerCJK: 1.3},
+	FamilyDeepseek: {charsPerToken: 3.8, tokensPerCJK: 0.6},
+	FamilyLlama:    {charsPerToken: 3.6, tokensPerCJK: 1.1},
+}
+
+// messageOverhead 每条消息的角色与分隔符开销
+const messageOverhead = 4
+
+// EstimateTokens 估算文本在指定分词器家族下的token数。
+// 结果略偏保守，用于上下文窗口预算而非计费。
+func EstimateTokens(family TokenFamily, text string) int {
+	ratio, ok := tokenRatios[family]
+	if !ok {
+		ratio = tokenRatios[FamilyGPT]
+	}
+
+	cjk, other := 0, 0
+	for _, r := range text {
+		if isCJK(r) {
+			cjk++
+		} else {
+			other++
+		}
+	}
+	return int(math.Ceil(float64(cjk)*ratio.tokensPerCJK + float64(other)/ratio.charsPerToken))
+}
+
+// EstimateMessagesTokens 估算一组消息的token数
+func EstimateMessagesTokens(family TokenFamily, messages []Message) int {
+	total := 0
+	for _, msg := range messages {
+		total += messageOverhead + EstimateTokens(family, msg.Content)
+	}
+	return total
+}
+
+// FamilyForModel 根据模型名推断分词器家族
+func FamilyForModel(model string) TokenFamily {
+	model = strings.ToLower(model)
+	switch {
+	case strings.Contains(model, "claude"):
+		return FamilyClaude
+	case strings.Contains(model, "deepseek"):
+		return FamilyDeepseek
+	case strings.Contains(model, "gpt"), strings.HasPrefix(model, "o1"), strings.HasPrefix(model, "o3"), strings.HasPrefix(model, "o4"):
+		return FamilyGPT
+	case model == "":
+		return FamilyGPT
+	default:
+		return FamilyLlama
+	}
+}
+
+// isCJK 判断字符是否属于中日韩文字
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
+
+// ContextLimits 描述提供商的上下文窗口限制
+type ContextLimits struct {
+	// Window 上下文窗口总token数（输入+输出）
+	Window int
+	// MaxOutput 为回复预留的token数
+	MaxOutput int
+	Family    TokenFamily
+}
+
+// LimitsReporter 可选接口，提供商通过它报告自己的上下文窗口
+type LimitsReporter interface {
+	Limits() ContextLimits
+}
+
+// defaultLimits 未报告限制的提供商使用的保守默认值
+var defaultLimits = ContextLimits{Window: 8192, MaxOutput: 2000, Family: FamilyGPT}
+
+// LimitsOf 返回提供商的上下文窗口限制
+func LimitsOf(p LLMProvider) ContextLimits {
+	reporter, ok := p.(LimitsReporter)
+	if !ok {
+		return defaultLimits
+	}
+	limits := reporter.Limits()
+	if limits.Window <= 0 {
+		limits.Window = defaultLimits.Window
+	}
+	if limits.MaxOutput <= 0 {
+		limits.MaxOutput = defaultLimits.MaxOutput
+	}
+	if limits.Family == "" {
+		limits.Family = defaultLimits.Family
+	}
+	return limits
+}
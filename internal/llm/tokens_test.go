@@ -0,0 +1,93 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/eust-w/urlreader/config"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	english := strings.Repeat("abcdefghijklmnopqrstuvwxyz0123456789 .,", 100) // 3900 个字符
+	chinese := strings.Repeat("网页内容读取与对话", 100)                               // 900 个汉字
+	tests := []struct {
+		family           TokenFamily
+		english, chinese int
+	}{
+		{FamilyGPT, 975, 900},
+		{FamilyClaude, 1115, 1170},
+		{FamilyDeepseek, 1027, 540},
+		{FamilyLlama, 1084, 991}, // 900*1.1 的浮点误差向上取整
+		{"unknown", 975, 900},
+	}
+	for _, tt := range tests {
+		if got := EstimateTokens(tt.family, english); got != tt.english {
+			t.Errorf("%s english: got %d, want %d", tt.family, got, tt.english)
+		}
+		if got := EstimateTokens(tt.family, chinese); got != tt.chinese {
+			t.Errorf("%s chinese: got %d, want %d", tt.family, got, tt.chinese)
+		}
+	}
+
+	// 混合文本分别计算，假名与谚文也按CJK比例计算
+	if got := EstimateTokens(FamilyGPT, "abcd中文かなカナ한글"); got != 9 {
+		t.Errorf("mixed: got %d, want 9", got)
+	}
+	if got := EstimateMessagesTokens(FamilyGPT, []Message{{Role: "user", Content: "abcd"}, {Role: "assistant"}}); got != 2*messageOverhead+1 {
+		t.Errorf("messages: got %d", got)
+	}
+}
+
+func TestFamilyForModel(t *testing.T) {
+	for model, want := range map[string]TokenFamily{
+		"gpt-4o":                   FamilyGPT,
+		"o3-mini":                  FamilyGPT,
+		"":                         FamilyGPT,
+		"claude-3-5-sonnet-latest": FamilyClaude,
+		"deepseek-chat":            FamilyDeepseek,
+		"Qwen2.5-72B-Instruct":     FamilyLlama,
+		"llama3.1":                 FamilyLlama,
+	} {
+		if got := FamilyForModel(model); got != want {
+			t.Errorf("FamilyForModel(%q) = %s, want %s", model, got, want)
+		}
+	}
+}
+
+// plainProvider 不报告上下文窗口的提供商
+type plainProvider struct{}
+
+func (plainProvider) Chat([]Message) (string, error) { return "", nil }
+func (plainProvider) ChatStream(context.Context, []Message, func(string) error) (string, error) {
+	return "", nil
+}
+func (plainProvider) Name() string { return "plain" }
+
+func TestLimitsOf(t *testing.T) {
+	cfg := &config.Config{
+		AnthropicContextWindow: 200000,
+		AnthropicMaxTokens:     4096,
+		DeepseekContextWindow:  64000,
+		OllamaModel:            "qwen2.5",
+	}
+	tests := []struct {
+		provider LLMProvider
+		want     ContextLimits
+	}{
+		{plainProvider{}, defaultLimits},
+		{NewAnthropicProvider(cfg), ContextLimits{Window: 200000, MaxOutput: 4096, Family: FamilyClaude}},
+		{NewDeepseekProvider(cfg), ContextLimits{Window: 64000, MaxOutput: 2000, Family: FamilyDeepseek}},
+		{NewOllamaProvider(cfg), ContextLimits{Window: ollamaDefaultNumCtx, MaxOutput: 2000, Family: FamilyLlama}},
+		// 未配置窗口与分词器家族时使用默认值
+		{NewOpenAICompatibleProvider(config.OpenAICompatibleConfig{Name: "gw", Model: "deepseek-v3"}),
+			ContextLimits{Window: defaultLimits.Window, MaxOutput: 2000, Family: FamilyDeepseek}},
+		{NewOpenAICompatibleProvider(config.OpenAICompatibleConfig{Name: "gw", Model: "m", ContextWindow: 32768, MaxTokens: 1024, TokenFamily: "Claude"}),
+			ContextLimits{Window: 32768, MaxOutput: 1024, Family: FamilyClaude}},
+	}
+	for _, tt := range tests {
+		if got := LimitsOf(tt.provider); got != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.provider.Name(), got, tt.want)
+		}
+	}
+}
@@ -1,8 +1,42 @@
 package models
 
+import "time"
+
 // ParseRequest 表示URL解析请求
 type ParseRequest struct {
-	URL string `json:"url" binding:"required"`
+	URL    string `json:"url" binding:"required"`
+	Format string `json:"format,omitempty" binding:"omitempty,oneof=text markdown html"`
+	// Crawl 非空时从 URL 开始抓取同站的多个页面
+	Crawl *CrawlRequest `json:"crawl,omitempty"`
+	// Feed 非空时按 RSS/Atom 订阅源读取 URL，不能与 Crawl 同时使用
+	Feed *FeedRequest `json:"feed,omitempty" binding:"excluded_with=Crawl"`
+	// Cache 缓存模式：为空时有效期内使用缓存、过期后重新验证；bypass 重新抓取；
+	// prefer 有缓存就使用；only 只使用缓存。只对单页面抓取生效。
+	Cache string `json:"cache,omitempty" binding:"omitempty,oneof=bypass prefer only"`
+}
+
+// CrawlRequest 表示多页面抓取的范围，未指定或超出服务端上限时使用上限
+type CrawlRequest struct {
+	MaxDepth int      `json:"max_depth,omitempty" binding:"omitempty,min=1"`
+	MaxPages int      `json:"max_pages,omitempty" binding:"omitempty,min=1"`
+	Include  []string `json:"include,omitempty"`
+	Exclude  []string `json:"exclude,omitempty"`
+	// Sitemap 为 true 时同时抓取站点 sitemap 中列出的页面
+	Sitemap bool `json:"sitemap,omitempty"`
+}
+
+// FeedRequest 表示订阅源模式的参数
+type FeedRequest struct {
+	// Articles 抓取最新多少篇文章的全文，超出服务端上限时使用上限；0 表示只使用订阅源中的摘要
+	Articles int `json:"articles,omitempty" binding:"omitempty,min=0"`
+}
+
+// FeedEntry 表示订阅源中的一个条目
+type FeedEntry struct {
+	Title     string     `json:"title"`
+	URL       string     `json:"url,omitempty"`
+	Published *time.Time `json:"published,omitempty"`
+	Summary   string     `json:"summary,omitempty"`
 }
 
 // ParseResponse 表示URL解析响应
@@ -11,7 +45,34 @@ type ParseResponse struct {
 	Title   string `json:"title,omitempty"`
 	Content string `json:"content,omitempty"`
 	URL     string `json:"url,omitempty"`
-	Error   string `json:"error,omitempty"`
+	Format  string `json:"format,omitempty"`
+	// Pages 多页面抓取时每个页面的结果，第一个为起始页面；订阅源模式下为文章全文的抓取结果
+	Pages []PageResult `json:"pages,omitempty"`
+	// Entries 订阅源模式下的条目，按发布时间从新到旧排列
+	Entries []FeedEntry `json:"entries,omitempty"`
+	// Metadata 网页或文档的元数据，多页面抓取时为起始页面的元数据
+	Metadata *Metadata `json:"metadata,omitempty"`
+	// Encoding 网页或文档转换为UTF-8前的编码，如 utf-8、gbk、big5；多页面抓取时为起始页面的编码
+	Encoding string `json:"encoding,omitempty"`
+	// Cache 缓存状态：hit、revalidated 或 miss，未启用缓存或非单页面抓取时为空
+	Cache string `json:"cache,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// PageResult 表示多页面抓取中一个页面的结果
+type PageResult struct {
+	URL      string `json:"url"`
+	FinalURL string `json:"final_url,omitempty"`
+	Title    string `json:"title,omitempty"`
+	Content  string `json:"content,omitempty"`
+	Depth    int    `json:"depth"`
+	// Metadata 页面的元数据，只在返回正文时返回
+	Metadata *Metadata `json:"metadata,omitempty"`
+	// Encoding 页面转换为UTF-8前的编码
+	Encoding string `json:"encoding,omitempty"`
+	Error    string `json:"error,omitempty"`
+	// Code 抓取失败的错误码，如 response_too_large、unsupported_content_type
+	Code string `json:"code,omitempty"`
 }
 
 // ChatRequest 表示聊天请求
@@ -20,6 +81,10 @@ type ChatRequest struct {
 	Message        string `json:"message" binding:"required"`
 	Model          string `json:"model,omitempty"`
 	ConversationID string `json:"conversation_id,omitempty"`
+	// Crawl 首次对话时抓取同站的多个页面，每个页面作为会话的一个来源
+	Crawl *CrawlRequest `json:"crawl,omitempty"`
+	// Feed 首次对话时按订阅源读取 URL，概览与抓取的文章各作为一个来源
+	Feed *FeedRequest `json:"feed,omitempty" binding:"excluded_with=Crawl"`
 }
 
 // ChatResponse 表示聊天响应
@@ -28,11 +93,196 @@ type ChatResponse struct {
 	Response       string `json:"response,omitempty"`
 	ConversationID string `json:"conversation_id,omitempty"`
 	Model          string `json:"model,omitempty"`
-	Error          string `json:"error,omitempty"`
+	// Skipped 实际作答前被跳过的提供商及原因
+	Skipped []SkippedProvider `json:"skipped,omitempty"`
+	// Citations 回答引用的网页原文，已在服务端校验
+	Citations []Citation `json:"citations,omitempty"`
+	Error     string     `json:"error,omitempty"`
+}
+
+// Citation 表示回答引用的一段网页原文
+type Citation struct {
+	ChunkID int `json:"chunk_id"`
+	// SourceID 片段所属来源的编号
+	SourceID    int      `json:"source_id"`
+	Quote       string   `json:"quote"`
+	HeadingPath []string `json:"heading_path,omitempty"`
+	// URL 带文本片段（#:~:text=）的原文链接，浏览器打开后高亮摘录
+	URL string `json:"url,omitempty"`
+}
+
+// SkippedProvider 表示后备链中被跳过的提供商
+type SkippedProvider struct {
+	Model  string `json:"model"`
+	Reason string `json:"reason"`
+}
+
+// ChatStreamEvent 表示流式聊天中单个SSE事件的数据
+type ChatStreamEvent struct {
+	ConversationID string            `json:"conversation_id,omitempty"`
+	Model          string            `json:"model,omitempty"`
+	Content        string            `json:"content,omitempty"`
+	Response       string            `json:"response,omitempty"`
+	Skipped        []SkippedProvider `json:"skipped,omitempty"`
+	Citations      []Citation        `json:"citations,omitempty"`
+	Error          string            `json:"error,omitempty"`
+	Code           string            `json:"code,omitempty"`
+}
+
+// AttachSourceRequest 表示向会话添加来源的请求
+type AttachSourceRequest struct {
+	URL string `json:"url" binding:"required"`
+	// Crawl 抓取同站的多个页面，每个页面作为一个来源添加
+	Crawl *CrawlRequest `json:"crawl,omitempty"`
+	// Feed 按订阅源读取 URL，概览与抓取的文章各作为一个来源添加
+	Feed *FeedRequest `json:"feed,omitempty" binding:"excluded_with=Crawl"`
+}
+
+// SourceInfo 表示会话引用的一个网页，不包含正文
+type SourceInfo struct {
+	ID      int       `json:"id"`
+	URL     string    `json:"url"`
+	Title   string    `json:"title,omitempty"`
+	AddedAt time.Time `json:"added_at"`
+	// Chunks 该来源切分出的片段数
+	Chunks int `json:"chunks"`
+}
+
+// SourcesResponse 表示会话来源列表响应
+type SourcesResponse struct {
+	Success        bool         `json:"success"`
+	ConversationID string       `json:"conversation_id"`
+	Sources        []SourceInfo `json:"sources"`
+}
+
+// AttachSourceResponse 表示添加来源的响应
+type AttachSourceResponse struct {
+	Success        bool       `json:"success"`
+	ConversationID string     `json:"conversation_id"`
+	Source         SourceInfo `json:"source"`
+	// Sources 多页面抓取或订阅源模式下添加的全部来源，第一个与 Source 相同
+	Sources []SourceInfo `json:"sources,omitempty"`
+	// Pages 多页面抓取或订阅源模式下每个页面的结果，不含正文
+	Pages []PageResult `json:"pages,omitempty"`
+}
+
+// ExtractionRule 表示某个站点的正文提取规则，规则文件为该结构的数组
+type ExtractionRule struct {
+	// Domain 适用的主机名，"*.example.com" 匹配所有子域名；规则文件中必填，试运行时可以省略
+	Domain string `json:"domain"`
+	// Paths 适用的路径通配模式，语法与多页面抓取的 include 相同；为空时适用于整个站点
+	Paths []string `json:"paths,omitempty"`
+	// Title 标题所在元素的选择器，为空时使用 <title>
+	Title string `json:"title,omitempty"`
+	// Include 正文所在元素的选择器，为空时对整个页面使用通用提取
+	Include []string `json:"include,omitempty"`
+	// Exclude 提取前移除的元素的选择器
+	Exclude []string `json:"exclude,omitempty"`
+	// WaitFor 页面中必须存在的元素的选择器，不存在时（如登录页、未渲染的页面）不使用该规则
+	WaitFor string `json:"wait_for,omitempty"`
+	// Strip 从提取结果的标题与正文中删除的正则表达式，按行匹配
+	Strip []string `json:"strip,omitempty"`
+}
+
+// RuleTestRequest 表示试运行提取规则的请求
+type RuleTestRequest struct {
+	URL    string `json:"url" binding:"required"`
+	Format string `json:"format,omitempty" binding:"omitempty,oneof=text markdown html"`
+	// Rule 要试运行的规则，为空时使用规则文件中匹配该URL的规则
+	Rule *ExtractionRule `json:"rule,omitempty"`
+}
+
+// RuleTestResponse 表示试运行提取规则的结果
+type RuleTestResponse struct {
+	Success  bool   `json:"success"`
+	URL      string `json:"url"`
+	FinalURL string `json:"final_url,omitempty"`
+	Title    string `json:"title"`
+	Content  string `json:"content"`
+	Format   string `json:"format"`
+	// Rule 使用的规则，没有匹配的规则时为空
+	Rule *ExtractionRule `json:"rule,omitempty"`
+	// Applied 是否按规则提取；为 false 时内容来自通用提取，原因见 Reason
+	Applied bool   `json:"applied"`
+	Reason  string `json:"reason,omitempty"`
 }
 
 // ErrorResponse 表示API错误响应
 type ErrorResponse struct {
 	Success bool   `json:"success"`
 	Error   string `json:"error"`
+	// Code 机器可读的错误码，如 rate_limited、timeout
+	Code string `json:"code,omitempty"`
+}
+
+// Metadata 表示网页的元数据。通用字段依次取 JSON-LD、OpenGraph/Twitter 与普通 meta 标签中的第一个非空值
+type Metadata struct {
+	Description string `json:"description,omitempty"`
+	Author      string `json:"author,omitempty"`
+	// Published、Modified 能识别格式时为 RFC 3339，否则保留原文
+	Published    string   `json:"published,omitempty"`
+	Modified     string   `json:"modified,omitempty"`
+	SiteName     string   `json:"site_name,omitempty"`
+	Language     string   `json:"language,omitempty"`
+	CanonicalURL string   `json:"canonical_url,omitempty"`
+	Image        string   `json:"image,omitempty"`
+	Keywords     []string `json:"keywords,omitempty"`
+	// OpenGraph og: 与 article: 开头的属性，键保留前缀；同名属性只保留第一个
+	OpenGraph map[string]string `json:"open_graph,omitempty"`
+	// Twitter twitter: 开头的卡片属性，键保留前缀
+	Twitter map[string]string `json:"twitter,omitempty"`
+	// JSONLD 从 JSON-LD 中解析出的结构化数据
+	JSONLD *StructuredData `json:"json_ld,omitempty"`
+}
+
+// StructuredData 表示 JSON-LD 中支持的几类结构化数据
+type StructuredData struct {
+	Articles []ArticleData `json:"articles,omitempty"`
+	Products []ProductData `json:"products,omitempty"`
+	Recipes  []RecipeData  `json:"recipes,omitempty"`
+	FAQ      []FAQItem     `json:"faq,omitempty"`
+}
+
+// ArticleData 表示 Article 及其子类型（NewsArticle、BlogPosting 等）
+type ArticleData struct {
+	Type          string `json:"type"`
+	Headline      string `json:"headline,omitempty"`
+	Description   string `json:"description,omitempty"`
+	Author        string `json:"author,omitempty"`
+	Publisher     string `json:"publisher,omitempty"`
+	DatePublished string `json:"date_published,omitempty"`
+	DateModified  string `json:"date_modified,omitempty"`
+	Section       string `json:"section,omitempty"`
+}
+
+// ProductData 表示 Product，价格取第一个报价
+type ProductData struct {
+	Name         string `json:"name"`
+	Description  string `json:"description,omitempty"`
+	Brand        string `json:"brand,omitempty"`
+	SKU          string `json:"sku,omitempty"`
+	Price        string `json:"price,omitempty"`
+	Currency     string `json:"currency,omitempty"`
+	Availability string `json:"availability,omitempty"`
+	Rating       string `json:"rating,omitempty"`
+	ReviewCount  string `json:"review_count,omitempty"`
+}
+
+// RecipeData 表示 Recipe，时间为 ISO 8601 时长（如 PT30M）
+type RecipeData struct {
+	Name         string   `json:"name"`
+	Description  string   `json:"description,omitempty"`
+	Author       string   `json:"author,omitempty"`
+	PrepTime     string   `json:"prep_time,omitempty"`
+	CookTime     string   `json:"cook_time,omitempty"`
+	TotalTime    string   `json:"total_time,omitempty"`
+	Yield        string   `json:"yield,omitempty"`
+	Ingredients  []string `json:"ingredients,omitempty"`
+	Instructions []string `json:"instructions,omitempty"`
+}
+
+// FAQItem 表示 FAQPage 中的一个问答
+type FAQItem struct {
+	Question string `json:"question"`
+	Answer   string `json:"answer"`
 }
@@ -0,0 +1,164 @@
+package prompt
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/eust-w/urlreader/internal/models"
+	"github.com/eust-w/urlreader/internal/rag"
+)
+
+// 模型在回答末尾输出引用块的起止标记
+const (
+	citationsStart = "<citations>"
+	citationsEnd   = "</citations>"
+)
+
+// citationInstruction 要求模型按片段编号引用原文
+const citationInstruction = "网页内容已切分为带编号的片段（如 [片段 3]）。回答中依据某个片段的内容时，在该句末尾标注片段编号，如 [3]。" +
+	"回答结束后另起一行输出引用块，每行一条，格式为 [片段编号] \"逐字摘录的原文\"，摘录不超过50个字，必须与片段原文完全一致:\n" +
+	citationsStart + "\n[3] \"摘录的原文\"\n" + citationsEnd + "\n没有引用时不要输出引用块。"
+
+// citationLine 匹配引用块中的一行：[3] "原文" 或 - [3]: 原文
+var citationLine = regexp.MustCompile(`^\s*[-*]?\s*\[(?:片段\s*)?(\d+)\]\s*[:：]?\s*(.+)$`)
+
+// ExtractCitations 从回答中取出并移除引用块，只保留能在所标注片段的原文中找到的摘录。
+// 编号错误的摘录直接丢弃而不改归其他片段：回答中的 [n] 标记（流式输出时已发送给客户端）无法随之修改。
+func ExtractCitations(response string, chunks []rag.Chunk, sources []Source) (string, []models.Citation) {
+	start := strings.LastIndex(response, citationsStart)
+	if start < 0 {
+		return response, nil
+	}
+	block := response[start+len(citationsStart):]
+	rest := ""
+	if end := strings.Index(block, citationsEnd); end >= 0 {
+		rest = strings.TrimSpace(block[end+len(citationsEnd):])
+		block = block[:end]
+	}
+	answer := strings.TrimSpace(response[:start])
+	if rest != "" {
+		answer += "\n\n" + rest
+	}
+
+	var citations []models.Citation
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(block, "\n") {
+		m := citationLine.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		id, _ := strconv.Atoi(m[1])
+		quote := collapseSpaces(strings.Trim(strings.TrimSpace(m[2]), "\"'“”‘’「」『』"))
+		if quote == "" {
+			continue
+		}
+
+		chunk, ok := findQuote(chunks, id, quote)
+		if !ok {
+			continue
+		}
+		key := fmt.Sprintf("%d\x00%s", chunk.ID, quote)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		citation := models.Citation{
+			ChunkID:     chunk.ID,
+			SourceID:    chunk.Source,
+			Quote:       quote,
+			HeadingPath: chunk.Heading,
+		}
+		for _, src := range sources {
+			if src.ID == chunk.Source {
+				citation.URL = textFragmentURL(src.URL, quote)
+			}
+		}
+		citations = append(citations, citation)
+	}
+	return answer, citations
+}
+
+// findQuote 在模型标注的片段中查找摘录
+func findQuote(chunks []rag.Chunk, id int, quote string) (rag.Chunk, bool) {
+	for _, c := range chunks {
+		if c.ID == id && strings.Contains(collapseSpaces(c.Text), quote) {
+			return c, true
+		}
+	}
+	return rag.Chunk{}, false
+}
+
+// collapseSpaces 把连续空白合并为一个空格
+func collapseSpaces(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// textFragmentURL 生成定位到摘录的文本片段链接（#:~:text=），摘录较长时使用 起始,结束 形式
+func textFragmentURL(pageURL, quote string) string {
+	if pageURL == "" {
+		return ""
+	}
+	if i := strings.Index(pageURL, "#"); i >= 0 {
+		pageURL = pageURL[:i]
+	}
+	// 列表项与表格在抓取结果中带有标记，页面上并不存在
+	quote = strings.TrimPrefix(quote, "- ")
+
+	directive := encodeFragment(quote)
+	if words := strings.Fields(quote); len(words) > 10 {
+		directive = encodeFragment(strings.Join(words[:4], " ")) + "," + encodeFragment(strings.Join(words[len(words)-4:], " "))
+	} else if runes := []rune(quote); len(words) <= 1 && len(runes) > 40 {
+		directive = encodeFragment(string(runes[:12])) + "," + encodeFragment(string(runes[len(runes)-12:]))
+	}
+	return pageURL + "#:~:text=" + directive
+}
+
+// encodeFragment 按文本片段语法转义，其中 - , & 有特殊含义必须转义
+func encodeFragment(s string) string {
+	var b strings.Builder
+	for _, c := range []byte(s) {
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte("._~!$'()*+;=:@/?", c) >= 0 {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+// CitationFilter 在流式输出中隐藏引用块，引用块在结束后由 ExtractCitations 统一解析
+type CitationFilter struct {
+	pending string
+	hidden  bool
+}
+
+// Write 返回可以立即发送给客户端的部分；可能是引用块开头的内容会暂时保留
+func (f *CitationFilter) Write(delta string) string {
+	if f.hidden {
+		return ""
+	}
+	text := f.pending + delta
+	f.pending = ""
+
+	if i := strings.Index(text, citationsStart); i >= 0 {
+		f.hidden = true
+		return text[:i]
+	}
+	for n := min(len(citationsStart)-1, len(text)); n > 0; n-- {
+		if strings.HasPrefix(citationsStart, text[len(text)-n:]) {
+			f.pending = text[len(text)-n:]
+			return text[:len(text)-n]
+		}
+	}
+	return text
+}
+
+// Flush 返回流结束时仍保留的内容
+func (f *CitationFilter) Flush() string {
+	text := f.pending
+	f.pending = ""
+	return text
+}
@@ -0,0 +1,74 @@
+package prompt
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/eust-w/urlreader/internal/rag"
+)
+
+var testChunks = []rag.Chunk{
+	{ID: 1, Source: 1, Heading: []string{"安装"}, Text: "从发布页面下载压缩包，解压后即可运行。"},
+	{ID: 2, Source: 1, Heading: []string{"安装", "配置"}, Text: "默认监听 8080 端口，\n可以通过 PORT 环境变量修改。"},
+}
+
+func TestExtractCitations(t *testing.T) {
+	response := "服务默认使用 8080 端口 [2]，解压即可运行 [1]。\n\n" +
+		"<citations>\n" +
+		"[2] \"默认监听 8080 端口， 可以通过 PORT 环境变量修改\"\n" +
+		"[1] \"解压后即可运行\"\n" +
+		"[1] \"支持 Windows 与 macOS\"\n" +
+		"[1] \"解压后即可运行\"\n" +
+		"</citations>"
+
+	answer, citations := ExtractCitations(response, testChunks, []Source{{ID: 1, URL: "https://example.com/docs#install"}})
+	if answer != "服务默认使用 8080 端口 [2]，解压即可运行 [1]。" {
+		t.Fatalf("answer = %q", answer)
+	}
+	// 编造的摘录与重复的引用被丢弃
+	if len(citations) != 2 {
+		t.Fatalf("got %d citations: %+v", len(citations), citations)
+	}
+
+	if c := citations[0]; c.ChunkID != 2 || strings.Join(c.HeadingPath, " > ") != "安装 > 配置" {
+		t.Errorf("citation 0 = %+v", c)
+	}
+	if c := citations[1]; c.ChunkID != 1 || c.SourceID != 1 || c.Quote != "解压后即可运行" {
+		t.Errorf("citation 1 = %+v", c)
+	}
+	if want := "https://example.com/docs#:~:text=%E8%A7%A3%E5%8E%8B%E5%90%8E%E5%8D%B3%E5%8F%AF%E8%BF%90%E8%A1%8C"; citations[1].URL != want {
+		t.Errorf("url = %q, want %q", citations[1].URL, want)
+	}
+}
+
+func TestExtractCitationsWrongChunk(t *testing.T) {
+	// 摘录实际位于片段1，但回答与引用块都标注为片段2或不存在的片段9
+	response := "解压即可运行 [2]，从发布页面下载 [9]。\n" +
+		"<citations>\n[2] \"解压后即可运行\"\n[9] \"从发布页面下载\"\n</citations>"
+
+	answer, citations := ExtractCitations(response, testChunks, nil)
+	if answer != "解压即可运行 [2]，从发布页面下载 [9]。" {
+		t.Fatalf("answer = %q", answer)
+	}
+	// 不改归到片段1，否则回答中的 [2] 与引用列表不一致
+	if len(citations) != 0 {
+		t.Fatalf("citations = %+v, want none", citations)
+	}
+}
+
+func TestCitationFilter(t *testing.T) {
+	var f CitationFilter
+	var out strings.Builder
+	for _, delta := range []string{"答案 [1]。", "\n<cit", "ations>\n[1] \"从发布", "页面下载\"\n</citations>"} {
+		out.WriteString(f.Write(delta))
+	}
+	out.WriteString(f.Flush())
+	if out.String() != "答案 [1]。\n" {
+		t.Fatalf("filtered = %q", out.String())
+	}
+
+	f = CitationFilter{}
+	if got := f.Write("a <b") + f.Flush(); got != "a <b" {
+		t.Fatalf("filtered = %q", got)
+	}
+}
@@ -0,0 +1,183 @@
+package prompt
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/eust-w/urlreader/internal/llm"
+)
+
+// contentGap 被省略的内容之间的分隔标记
+const contentGap = "……"
+
+// block 网页内容中的一个段落
+type block struct {
+	index  int
+	text   string
+	tokens int
+	score  float64
+}
+
+// selectContent 在预算内挑选与问题最相关的段落，按原文顺序拼接，省略处以 contentGap 标出。
+// 内容未超出预算时原样返回。
+func selectContent(content, query string, budget int, family llm.TokenFamily) string {
+	if budget <= 0 {
+		return ""
+	}
+	if llm.EstimateTokens(family, content) <= budget {
+		return content
+	}
+
+	var blocks []block
+	for _, text := range strings.Split(content, "\n\n") {
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+		blocks = append(blocks, block{
+			index:  len(blocks),
+			text:   text,
+			tokens: llm.EstimateTokens(family, text) + 1,
+		})
+	}
+
+	terms := queryTerms(query)
+	for i := range blocks {
+		blocks[i].score = scoreBlock(blocks[i], terms, len(blocks))
+	}
+
+	ranked := make([]int, len(blocks))
+	for i := range ranked {
+		ranked[i] = i
+	}
+	sort.SliceStable(ranked, func(a, b int) bool {
+		return blocks[ranked[a]].score > blocks[ranked[b]].score
+	})
+
+	selected := make(map[int]string)
+	gapCost := llm.EstimateTokens(family, contentGap) + 1
+	used := 0
+	for _, i := range ranked {
+		b := blocks[i]
+		cost := b.tokens + gapCost
+		if used+cost <= budget {
+			selected[i] = b.text
+			used += cost
+			continue
+		}
+		// 最相关的段落本身超出剩余预算时截取开头部分
+		remaining := budget - used - gapCost
+		if len(selected) == 0 && remaining > 0 {
+			selected[i] = truncateToTokens(b.text, remaining, family)
+			used = budget
+		}
+		if used >= budget-gapCost {
+			break
+		}
+	}
+
+	var parts []string
+	last := -1
+	for i := range blocks {
+		text, ok := selected[i]
+		if !ok {
+			continue
+		}
+		if i != last+1 {
+			parts = append(parts, contentGap)
+		}
+		parts = append(parts, text)
+		last = i
+	}
+	if last != len(blocks)-1 && len(parts) > 0 {
+		parts = append(parts, contentGap)
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// scoreBlock 根据问题关键词命中情况与位置给段落打分
+func scoreBlock(b block, terms map[string]bool, total int) float64 {
+	lower := strings.ToLower(b.text)
+	hits := 0
+	for term := range terms {
+		if strings.Contains(lower, term) {
+			hits++
+		}
+	}
+	score := float64(hits) / math.Sqrt(float64(b.tokens)+1) * 10
+
+	// 开头几段通常是摘要或导语，标题提供结构信息
+	if b.index < 3 {
+		score += 1.5 - 0.5*float64(b.index)
+	}
+	if isHeading(b.text) {
+		score += 0.5
+	}
+	return score
+}
+
+// isHeading 判断段落是否为抓取结果中的标题行
+func isHeading(text string) bool {
+	return strings.HasPrefix(text, "[h") || strings.HasPrefix(text, "#")
+}
+
+// queryTerms 从问题中提取关键词：英文单词与中日韩文字的二元组
+func queryTerms(query string) map[string]bool {
+	terms := make(map[string]bool)
+	var word []rune
+	var cjk []rune
+	flushWord := func() {
+		if len(word) >= 2 && !stopWords[string(word)] {
+			terms[string(word)] = true
+		}
+		word = word[:0]
+	}
+	flushCJK := func() {
+		for i := 0; i+1 < len(cjk); i++ {
+			terms[string(cjk[i:i+2])] = true
+		}
+		cjk = cjk[:0]
+	}
+
+	for _, r := range strings.ToLower(query) {
+		switch {
+		case unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r):
+			flushWord()
+			cjk = append(cjk, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushCJK()
+			word = append(word, r)
+		default:
+			flushWord()
+			flushCJK()
+		}
+	}
+	flushWord()
+	flushCJK()
+	return terms
+}
+
+// stopWords 不参与相关度计算的常见英文词
+var stopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "are": true, "what": true, "how": true, "why": true,
+	"this": true, "that": true, "with": true, "does": true, "is": true, "of": true, "to": true,
+	"in": true, "on": true, "it": true, "be": true, "an": true, "or": true, "can": true, "you": true,
+	"about": true, "page": true, "please": true,
+}
+
+// truncateToTokens 截取文本开头，使其不超过给定的token数
+func truncateToTokens(text string, budget int, family llm.TokenFamily) string {
+	runes := []rune(text)
+	lo, hi := 0, len(runes)
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if llm.EstimateTokens(family, string(runes[:mid])) <= budget {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	return string(runes[:lo])
+}
@@ -0,0 +1,330 @@
+package prompt
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/eust-w/urlreader/internal/llm"
+	"github.com/eust-w/urlreader/internal/rag"
+)
+
+const (
+	// contentAck 网页内容消息之后的助手确认消息
+	contentAck = "我已经阅读了网页内容，请问有什么我可以帮助你的？"
+	// minHistoryShare 内容与历史同时超出预算时，历史至少保留的预算比例
+	minHistoryShare = 0.3
+	// summaryShare 被省略轮次的摘要最多占用的历史预算比例
+	summaryShare = 0.1
+	// descriptionRunes 系统提示中网页简介的最大字符数
+	descriptionRunes = 200
+)
+
+// weekdays 星期的中文名称，按 time.Weekday 排列
+var weekdays = [...]string{"星期日", "星期一", "星期二", "星期三", "星期四", "星期五", "星期六"}
+
+// Source 会话引用的一个网页
+type Source struct {
+	ID      int
+	Title   string
+	URL     string
+	Content string
+	// 以下为网页元数据中的关键字段，写入系统提示，便于回答"这篇文章什么时候发布"这类问题
+	Description string
+	Author      string
+	Published   string
+	Modified    string
+	SiteName    string
+	Language    string
+}
+
+// Input 构建提示词所需的会话信息
+type Input struct {
+	// Sources 会话引用的网页，按添加顺序排列
+	Sources []Source
+	// History 之前的 user/assistant 对话轮次，不包含本次问题
+	History  []llm.Message
+	Question string
+	// Chunks 网页内容的编号片段，非空时代替来源全文发送，并要求模型按编号引用
+	Chunks []rag.Chunk
+	// Retrieved 表示 Chunks 是按相关度从高到低排列的检索结果，而不是按原文顺序的全部片段
+	Retrieved bool
+	// Today 当前日期，非零时写入系统提示，便于回答"本周有哪些更新"这类相对时间的问题
+	Today time.Time
+}
+
+// Build 在提供商的上下文窗口内组装消息：
+// 为回复预留空间，历史过长时省略最早的轮次并附上摘要，网页内容过长时按与问题的相关度挑选段落。
+// 提供了片段时以编号片段代替网页内容，并要求模型按编号引用原文。
+func Build(in Input, limits llm.ContextLimits) []llm.Message {
+	family := limits.Family
+	multi := len(in.Sources) > 1
+	system := llm.Message{Role: "system", Content: systemPrompt(in.Sources)}
+	if !in.Today.IsZero() {
+		system.Content += fmt.Sprintf("今天是 %s（%s）。", in.Today.Format("2006-01-02"), weekdays[in.Today.Weekday()])
+	}
+	if len(in.Chunks) > 0 {
+		system.Content += "\n\n" + citationInstruction
+	}
+	question := llm.Message{Role: "user", Content: in.Question}
+	ack := llm.Message{Role: "assistant", Content: contentAck}
+
+	// 预留回复空间与估算误差
+	budget := limits.Window - limits.MaxOutput - limits.Window/20
+	fixed := llm.EstimateMessagesTokens(family, []llm.Message{system, question, ack}) +
+		llm.EstimateTokens(family, contentHeader(false)) + 4
+	available := budget - fixed
+
+	fullContent := joinSources(in.Sources)
+	content := fullContent
+	if len(in.Chunks) > 0 {
+		content = renderChunks(in.Chunks, -1, family, multi)
+	}
+	historyTokens := llm.EstimateMessagesTokens(family, in.History)
+	contentTokens := llm.EstimateTokens(family, content)
+
+	history := in.History
+	var summary string
+	truncated := false
+
+	if historyTokens+contentTokens > available {
+		historyBudget := historyTokens
+		if limit := max(int(float64(available)*minHistoryShare), available-contentTokens); historyBudget > limit {
+			historyBudget = limit
+		}
+		history, summary = fitHistory(in.History, historyBudget, family)
+
+		used := llm.EstimateMessagesTokens(family, history) + llm.EstimateTokens(family, summary)
+		if len(in.Chunks) > 0 {
+			content = renderChunks(rankChunks(in, family), available-used, family, multi)
+			truncated = true
+		} else {
+			content = selectContent(fullContent, RelevanceQuery(in), available-used, family)
+			truncated = content != fullContent
+		}
+	}
+
+	header := contentHeader(truncated)
+	if len(in.Chunks) > 0 {
+		header = chunksHeader(in.Retrieved || truncated)
+	}
+
+	messages := []llm.Message{system}
+	if content != "" {
+		messages = append(messages,
+			llm.Message{Role: "user", Content: header + content},
+			ack,
+		)
+	}
+	if summary != "" {
+		messages = append(messages, llm.Message{Role: "system", Content: summary})
+	}
+	messages = append(messages, history...)
+	messages = append(messages, question)
+	return messages
+}
+
+// systemPrompt 说明助手的角色；多个来源时列出每个来源的编号、标题与URL，便于模型对比和区分
+func systemPrompt(sources []Source) string {
+	const rules = "请保持回答简洁、准确，并直接基于提供的网页内容。"
+	if len(sources) == 1 {
+		prompt := fmt.Sprintf("你是一个网页内容助手。你将基于从URL %s 抓取的内容回答问题。%s", sources[0].URL, rules)
+		if details := sourceDetails(sources[0]); details != "" {
+			prompt += "\n网页信息: " + details + "。\n"
+		}
+		return prompt
+	}
+
+	var b strings.Builder
+	b.WriteString("你是一个网页内容助手。你将基于从以下网页抓取的内容回答问题:\n")
+	for _, src := range sources {
+		b.WriteString(sourceLabel(src))
+		if details := sourceDetails(src); details != "" {
+			b.WriteString("（" + details + "）")
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString(rules)
+	b.WriteString("涉及多个网页时，请说明信息来自哪个来源；比较不同网页时分别引用各自的内容。")
+	return b.String()
+}
+
+// sourceLabel 以编号、标题和URL标注来源
+func sourceLabel(src Source) string {
+	label := fmt.Sprintf("[来源 %d]", src.ID)
+	if src.Title != "" {
+		label += " " + src.Title
+	}
+	return label + " " + src.URL
+}
+
+// sourceDetails 列出来源的站点、作者、时间、语言与简介，没有元数据时返回空字符串
+func sourceDetails(src Source) string {
+	var fields []string
+	for _, f := range []struct{ label, value string }{
+		{"站点", src.SiteName},
+		{"作者", src.Author},
+		{"发布时间", src.Published},
+		{"更新时间", src.Modified},
+		{"语言", src.Language},
+		{"简介", truncateRunes(src.Description, descriptionRunes)},
+	} {
+		if f.value != "" {
+			fields = append(fields, f.label+": "+f.value)
+		}
+	}
+	return strings.Join(fields, "；")
+}
+
+// joinSources 拼接各来源的全文，多个来源时在每个来源前加上标注
+func joinSources(sources []Source) string {
+	if len(sources) == 1 {
+		return sources[0].Content
+	}
+	parts := make([]string, 0, len(sources))
+	for _, src := range sources {
+		parts = append(parts, sourceLabel(src)+"\n\n"+src.Content)
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// contentHeader 网页内容消息的开头说明
+func contentHeader(truncated bool) string {
+	if truncated {
+		return "以下是从网页抓取的内容（内容较长，仅保留与问题最相关的部分，省略处以……标出）:\n\n"
+	}
+	return "以下是从网页抓取的内容:\n\n"
+}
+
+// chunksHeader 编号片段消息的开头说明
+func chunksHeader(partial bool) string {
+	if partial {
+		return "以下是网页中与问题相关的片段（按原文顺序排列，未列出的片段已省略）:\n\n"
+	}
+	return "以下是从网页抓取的内容，已按片段编号:\n\n"
+}
+
+// rankChunks 返回按相关度排列的片段；未经检索的片段按与问题的关键词命中情况排序
+func rankChunks(in Input, family llm.TokenFamily) []rag.Chunk {
+	if in.Retrieved {
+		return in.Chunks
+	}
+	terms := queryTerms(RelevanceQuery(in))
+	scores := make(map[int]float64, len(in.Chunks))
+	for i, c := range in.Chunks {
+		text := c.HeadingPath() + "\n" + c.Text
+		b := block{index: i, text: text, tokens: llm.EstimateTokens(family, text) + 1}
+		scores[c.ID] = scoreBlock(b, terms, len(in.Chunks))
+	}
+
+	ranked := append([]rag.Chunk(nil), in.Chunks...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return scores[ranked[i].ID] > scores[ranked[j].ID]
+	})
+	return ranked
+}
+
+// renderChunks 按相关度依次选取放得下的片段，再按原文顺序输出。budget 小于0时不限制。
+func renderChunks(chunks []rag.Chunk, budget int, family llm.TokenFamily, withSource bool) string {
+	var selected []rag.Chunk
+	used := 0
+	for _, c := range chunks {
+		cost := llm.EstimateTokens(family, formatChunk(c, withSource)) + 1
+		if budget >= 0 && used+cost > budget {
+			continue
+		}
+		used += cost
+		selected = append(selected, c)
+	}
+	sort.Slice(selected, func(i, j int) bool {
+		return selected[i].ID < selected[j].ID
+	})
+
+	parts := make([]string, len(selected))
+	for i, c := range selected {
+		parts[i] = formatChunk(c, withSource)
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// formatChunk 以编号和标题路径标注片段，多个来源时同时标注来源编号
+func formatChunk(c rag.Chunk, withSource bool) string {
+	label := fmt.Sprintf("[片段 %d]", c.ID)
+	if withSource {
+		label += fmt.Sprintf("（来源 %d）", c.Source)
+	}
+	if path := c.HeadingPath(); path != "" {
+		label += " " + path
+	}
+	return label + "\n" + c.Text
+}
+
+// RelevanceQuery 用本次问题和最近一次用户提问作为挑选内容的依据，便于处理“展开说说”之类的追问
+func RelevanceQuery(in Input) string {
+	query := in.Question
+	for i := len(in.History) - 1; i >= 0; i-- {
+		if in.History[i].Role == "user" {
+			query += "\n" + in.History[i].Content
+			break
+		}
+	}
+	return query
+}
+
+// fitHistory 从最新的轮次开始保留历史，放不下的早期轮次压缩为用户提问摘要
+func fitHistory(history []llm.Message, budget int, family llm.TokenFamily) ([]llm.Message, string) {
+	if budget <= 0 {
+		return nil, ""
+	}
+
+	summaryBudget := int(float64(budget) * summaryShare)
+	keepBudget := budget - summaryBudget
+	start := len(history)
+	used := 0
+	for i := len(history) - 1; i >= 0; i-- {
+		cost := llm.EstimateMessagesTokens(family, history[i:i+1])
+		if used+cost > keepBudget {
+			break
+		}
+		used += cost
+		start = i
+	}
+	if start == 0 {
+		return history, ""
+	}
+
+	// 摘要从最近被省略的提问开始倒序填充，再恢复时间顺序
+	var lines []string
+	summaryUsed := llm.EstimateTokens(family, summaryHeader)
+	for i := start - 1; i >= 0; i-- {
+		if history[i].Role != "user" {
+			continue
+		}
+		line := "- " + truncateRunes(strings.Join(strings.Fields(history[i].Content), " "), 80)
+		cost := llm.EstimateTokens(family, line) + 1
+		if summaryUsed+cost > summaryBudget {
+			break
+		}
+		summaryUsed += cost
+		lines = append([]string{line}, lines...)
+	}
+
+	kept := history[start:]
+	if len(lines) == 0 {
+		return kept, ""
+	}
+	return kept, summaryHeader + strings.Join(lines, "\n")
+}
+
+// summaryHeader 早期轮次摘要的开头说明
+const summaryHeader = "较早的对话已省略，用户此前问过:\n"
+
+// truncateRunes 按字符截断文本
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n]) + "…"
+}
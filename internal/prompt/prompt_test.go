@@ -0,0 +1,109 @@
+package prompt
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/eust-w/urlreader/internal/llm"
+)
+
+func TestSystemPromptMetadata(t *testing.T) {
+	src := Source{ID: 1, Title: "Launch day", URL: "https://example.com/launch", Author: "Ada", Published: "2026-10-01T08:00:00Z", SiteName: "Eng Blog"}
+
+	single := systemPrompt([]Source{src})
+	if !strings.Contains(single, "网页信息: 站点: Eng Blog；作者: Ada；发布时间: 2026-10-01T08:00:00Z。") {
+		t.Errorf("single source prompt = %q", single)
+	}
+
+	other := Source{ID: 2, Title: "Docs", URL: "https://example.com/docs"}
+	multi := systemPrompt([]Source{src, other})
+	if !strings.Contains(multi, "[来源 1] Launch day https://example.com/launch（站点: Eng Blog；作者: Ada；发布时间: 2026-10-01T08:00:00Z）\n") ||
+		!strings.Contains(multi, "[来源 2] Docs https://example.com/docs\n") {
+		t.Errorf("multi source prompt = %q", multi)
+	}
+}
+
+func TestBuildFitsWindow(t *testing.T) {
+	var content strings.Builder
+	for i := 0; i < 400; i++ {
+		fmt.Fprintf(&content, "Paragraph %d describes the release schedule, pricing and support policy in detail.\n\n网页第%d段介绍发布计划、价格与支持政策。\n\n", i, i)
+	}
+	var history []llm.Message
+	for i := 0; i < 60; i++ {
+		history = append(history,
+			llm.Message{Role: "user", Content: fmt.Sprintf("Question %d: %s", i, strings.Repeat("what about pricing? ", 20))},
+			llm.Message{Role: "assistant", Content: fmt.Sprintf("Answer %d: %s", i, strings.Repeat("it depends on the plan. ", 20))},
+		)
+	}
+	in := Input{
+		Sources:  []Source{{ID: 1, Title: "Docs", URL: "https://example.com/docs", Content: content.String()}},
+		History:  history,
+		Question: "What is the support policy?",
+	}
+
+	for _, family := range []llm.TokenFamily{llm.FamilyGPT, llm.FamilyClaude, llm.FamilyDeepseek, llm.FamilyLlama} {
+		limits := llm.ContextLimits{Window: 8192, MaxOutput: 2000, Family: family}
+		messages := Build(in, limits)
+		if used := llm.EstimateMessagesTokens(family, messages); used > limits.Window-limits.MaxOutput {
+			t.Errorf("%s: prompt uses %d tokens, window leaves %d", family, used, limits.Window-limits.MaxOutput)
+		}
+
+		// 保留系统提示与本次问题，省略的是最早的轮次
+		if messages[0].Role != "system" || messages[0].Content != systemPrompt(in.Sources) {
+			t.Errorf("%s: first message = %+v", family, messages[0])
+		}
+		if last := messages[len(messages)-1]; last.Role != "user" || last.Content != in.Question {
+			t.Errorf("%s: last message = %+v", family, last)
+		}
+		var kept []llm.Message
+		var summary string
+		for _, msg := range messages[1 : len(messages)-1] {
+			switch {
+			case strings.HasPrefix(msg.Content, summaryHeader):
+				summary = msg.Content
+			case strings.HasPrefix(msg.Content, "Question"), strings.HasPrefix(msg.Content, "Answer"):
+				kept = append(kept, msg)
+			}
+		}
+		if len(kept) == 0 || len(kept) == len(history) {
+			t.Fatalf("%s: kept %d of %d history messages", family, len(kept), len(history))
+		}
+		for i, msg := range kept {
+			if want := history[len(history)-len(kept)+i]; msg != want {
+				t.Errorf("%s: kept history is not the latest turns: %q", family, msg.Content)
+				break
+			}
+		}
+		if !strings.Contains(summary, "- Question") || strings.Contains(summary, "Answer") {
+			t.Errorf("%s: summary = %q", family, summary)
+		}
+	}
+}
+
+func TestBuildCJKBudget(t *testing.T) {
+	// 同样的中文内容，汉字占用token少的家族能放下更多段落
+	var content strings.Builder
+	for i := 0; i < 300; i++ {
+		fmt.Fprintf(&content, "第%d段：本产品提供七天无理由退货，退货运费由买家承担，质量问题除外。\n\n", i)
+	}
+	in := Input{
+		Sources:  []Source{{ID: 1, Title: "退货政策", URL: "https://example.com/returns", Content: content.String()}},
+		Question: "退货运费谁承担？",
+	}
+	kept := make(map[llm.TokenFamily]int)
+	for _, family := range []llm.TokenFamily{llm.FamilyDeepseek, llm.FamilyGPT, llm.FamilyClaude} {
+		limits := llm.ContextLimits{Window: 6000, MaxOutput: 1000, Family: family}
+		messages := Build(in, limits)
+		if used := llm.EstimateMessagesTokens(family, messages); used > limits.Window-limits.MaxOutput {
+			t.Errorf("%s: prompt uses %d tokens, window leaves %d", family, used, limits.Window-limits.MaxOutput)
+		}
+		if len(messages) < 2 || !strings.HasPrefix(messages[1].Content, contentHeader(true)) {
+			t.Fatalf("%s: content was not truncated: %+v", family, messages)
+		}
+		kept[family] = strings.Count(messages[1].Content, "退货运费由买家承担")
+	}
+	if !(kept[llm.FamilyDeepseek] > kept[llm.FamilyGPT] && kept[llm.FamilyGPT] > kept[llm.FamilyClaude] && kept[llm.FamilyClaude] > 0) {
+		t.Errorf("paragraphs kept per family = %v", kept)
+	}
+}
@@ -0,0 +1,141 @@
+package rag
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Chunk 是网页内容中按标题与段落切分出的一个片段
+type Chunk struct {
+	// ID 片段编号，从1开始按文档顺序递增，在同一会话的所有来源中唯一
+	ID int `json:"id"`
+	// Source 片段所属来源的编号
+	Source int `json:"source,omitempty"`
+	// Heading 片段所在的标题路径，从最外层标题开始
+	Heading []string  `json:"heading,omitempty"`
+	Text    string    `json:"text"`
+	Vector  []float32 `json:"vector,omitempty"`
+}
+
+// HeadingPath 返回以 " > " 连接的标题路径
+func (c Chunk) HeadingPath() string {
+	return strings.Join(c.Heading, " > ")
+}
+
+var (
+	// textHeading 匹配纯文本格式中的 "[h2] 标题"
+	textHeading = regexp.MustCompile(`^\[h([1-6])\]\s+(.+)$`)
+	// markdownHeading 匹配 Markdown 格式中的 "## 标题"
+	markdownHeading = regexp.MustCompile(`^(#{1,6})\s+(.+)$`)
+)
+
+// DefaultChunkSize 单个片段的默认最大字符数
+const DefaultChunkSize = 800
+
+// ChunkContent 按标题与段落切分网页内容。
+// 每遇到一个标题开始新的片段，同一标题下的段落合并到不超过 maxRunes 个字符。
+func ChunkContent(content string, maxRunes int) []Chunk {
+	if maxRunes <= 0 {
+		maxRunes = DefaultChunkSize
+	}
+
+	var chunks []Chunk
+	var headings [6]string
+	var current []string
+	currentLen := 0
+
+	headingPath := func() []string {
+		var path []string
+		for _, h := range headings {
+			if h != "" {
+				path = append(path, h)
+			}
+		}
+		return path
+	}
+	flush := func() {
+		if len(current) == 0 {
+			return
+		}
+		chunks = append(chunks, Chunk{
+			ID:      len(chunks) + 1,
+			Heading: headingPath(),
+			Text:    strings.Join(current, "\n\n"),
+		})
+		current = nil
+		currentLen = 0
+	}
+
+	for _, para := range strings.Split(content, "\n\n") {
+		para = strings.TrimSpace(para)
+		if para == "" {
+			continue
+		}
+
+		if level, title, ok := parseHeading(para); ok {
+			flush()
+			headings[level-1] = title
+			for i := level; i < len(headings); i++ {
+				headings[i] = ""
+			}
+			continue
+		}
+
+		for _, piece := range splitLong(para, maxRunes) {
+			length := utf8.RuneCountInString(piece)
+			if currentLen > 0 {
+				// 段落之间以空行连接
+				length += 2
+			}
+			if currentLen > 0 && currentLen+length > maxRunes {
+				flush()
+				length -= 2
+			}
+			current = append(current, piece)
+			currentLen += length
+		}
+	}
+	flush()
+
+	return chunks
+}
+
+// parseHeading 识别纯文本或 Markdown 格式的标题行
+func parseHeading(para string) (int, string, bool) {
+	if strings.Contains(para, "\n") {
+		return 0, "", false
+	}
+	if m := textHeading.FindStringSubmatch(para); m != nil {
+		return int(m[1][0] - '0'), strings.TrimSpace(m[2]), true
+	}
+	if m := markdownHeading.FindStringSubmatch(para); m != nil {
+		return len(m[1]), strings.TrimSpace(m[2]), true
+	}
+	return 0, "", false
+}
+
+// splitLong 把超长段落按句子切分为不超过 maxRunes 的若干段
+func splitLong(para string, maxRunes int) []string {
+	if utf8.RuneCountInString(para) <= maxRunes {
+		return []string{para}
+	}
+
+	var pieces []string
+	var b strings.Builder
+	length := 0
+	for _, r := range para {
+		b.WriteRune(r)
+		length++
+		sentenceEnd := strings.ContainsRune("。！？.!?\n", r)
+		if (sentenceEnd && length >= maxRunes/2) || length >= maxRunes {
+			pieces = append(pieces, strings.TrimSpace(b.String()))
+			b.Reset()
+			length = 0
+		}
+	}
+	if rest := strings.TrimSpace(b.String()); rest != "" {
+		pieces = append(pieces, rest)
+	}
+	return pieces
+}
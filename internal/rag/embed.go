@@ -0,0 +1,245 @@
+package rag
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/eust-w/urlreader/config"
+)
+
+// Embedder 把文本转换为向量
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+	Name() string
+}
+
+// 向量化实现名称
+const (
+	EmbedderHash   = "hash"
+	EmbedderOpenAI = "openai"
+)
+
+// NewEmbedder 根据配置创建向量化实现，默认使用本地哈希向量
+func NewEmbedder(cfg *config.Config) (Embedder, error) {
+	switch strings.ToLower(cfg.EmbeddingsProvider) {
+	case "", EmbedderHash:
+		return NewHashEmbedder(cfg.EmbeddingsDimensions), nil
+	case EmbedderOpenAI:
+		if cfg.EmbeddingsBaseURL == "" || cfg.EmbeddingsModel == "" {
+			return nil, fmt.Errorf("OpenAI兼容向量服务的地址或模型未配置")
+		}
+		return NewOpenAIEmbedder(cfg.EmbeddingsBaseURL, cfg.EmbeddingsModel, cfg.EmbeddingsAPIKey), nil
+	default:
+		return nil, fmt.Errorf("不支持的向量化实现: %s", cfg.EmbeddingsProvider)
+	}
+}
+
+// HashEmbedder 使用特征哈希生成确定性向量，不依赖外部服务，适合离线环境与测试
+type HashEmbedder struct {
+	dimensions int
+}
+
+// NewHashEmbedder 创建哈希向量化实现
+func NewHashEmbedder(dimensions int) *HashEmbedder {
+	if dimensions <= 0 {
+		dimensions = 512
+	}
+	return &HashEmbedder{dimensions: dimensions}
+}
+
+// Name 返回实现名称
+func (e *HashEmbedder) Name() string {
+	return EmbedderHash
+}
+
+// Embed 把每个文本的词项哈希到固定维度并做L2归一化
+func (e *HashEmbedder) Embed(_ context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vec := make([]float32, e.dimensions)
+		for _, term := range Terms(text) {
+			h := fnv.New64a()
+			h.Write([]byte(term))
+			sum := h.Sum64()
+			sign := float32(1)
+			if sum&(1<<63) != 0 {
+				sign = -1
+			}
+			vec[sum%uint64(e.dimensions)] += sign
+		}
+		normalize(vec)
+		vectors[i] = vec
+	}
+	return vectors, nil
+}
+
+// Terms 把文本切分为检索用的词项：英文单词与中日韩文字的二元组
+func Terms(text string) []string {
+	var terms []string
+	var word, cjk []rune
+	flushWord := func() {
+		if len(word) >= 2 {
+			terms = append(terms, string(word))
+		}
+		word = word[:0]
+	}
+	flushCJK := func() {
+		if len(cjk) == 1 {
+			terms = append(terms, string(cjk))
+		}
+		for i := 0; i+1 < len(cjk); i++ {
+			terms = append(terms, string(cjk[i:i+2]))
+		}
+		cjk = cjk[:0]
+	}
+
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r):
+			flushWord()
+			cjk = append(cjk, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushCJK()
+			word = append(word, r)
+		default:
+			flushWord()
+			flushCJK()
+		}
+	}
+	flushWord()
+	flushCJK()
+	return terms
+}
+
+// OpenAIEmbedder 调用OpenAI兼容的 /embeddings 接口
+type OpenAIEmbedder struct {
+	baseURL    string
+	model      string
+	apiKey     string
+	httpClient *http.Client
+}
+
+// NewOpenAIEmbedder 创建OpenAI兼容向量化实现，baseURL 需包含 /v1
+func NewOpenAIEmbedder(baseURL, model, apiKey string) *OpenAIEmbedder {
+	return &OpenAIEmbedder{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		model:      model,
+		apiKey:     apiKey,
+		httpClient: &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// Name 返回实现名称
+func (e *OpenAIEmbedder) Name() string {
+	return EmbedderOpenAI + ":" + e.model
+}
+
+// embeddingsBatchSize 单次请求最多提交的文本数
+const embeddingsBatchSize = 64
+
+// EmbeddingsRequest /embeddings 请求结构
+type EmbeddingsRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+// EmbeddingsResponse /embeddings 响应结构
+type EmbeddingsResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// Embed 分批请求向量并按输入顺序返回
+func (e *OpenAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += embeddingsBatchSize {
+		end := min(start+embeddingsBatchSize, len(texts))
+		batch, err := e.embedBatch(ctx, texts[start:end])
+		if err != nil {
+			return nil, err
+		}
+		vectors = append(vectors, batch...)
+	}
+	return vectors, nil
+}
+
+// embedBatch 请求一批文本的向量
+func (e *OpenAIEmbedder) embedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	jsonData, err := json.Marshal(EmbeddingsRequest{Model: e.model, Input: texts})
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求失败: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", e.baseURL+"/embeddings", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.apiKey)
+	}
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("发送请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API错误: %s, 状态码: %d", string(body), resp.StatusCode)
+	}
+
+	var response EmbeddingsResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("解析响应失败: %w", err)
+	}
+	if response.Error != nil {
+		return nil, fmt.Errorf("API错误: %s", response.Error.Message)
+	}
+	if len(response.Data) != len(texts) {
+		return nil, fmt.Errorf("向量数量不匹配: 期望 %d, 实际 %d", len(texts), len(response.Data))
+	}
+
+	vectors := make([][]float32, len(texts))
+	for _, item := range response.Data {
+		if item.Index < 0 || item.Index >= len(texts) {
+			return nil, fmt.Errorf("向量序号越界: %d", item.Index)
+		}
+		normalize(item.Embedding)
+		vectors[item.Index] = item.Embedding
+	}
+	return vectors, nil
+}
+
+// normalize 对向量做L2归一化，使点积等于余弦相似度
+func normalize(vec []float32) {
+	var sum float64
+	for _, v := range vec {
+		sum += float64(v) * float64(v)
+	}
+	if sum == 0 {
+		return
+	}
+	norm := float32(math.Sqrt(sum))
+	for i := range vec {
+		vec[i] /= norm
+	}
+}
@@ -0,0 +1,131 @@
+package rag
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testContent = `[h1] 安装指南
+
+本文介绍如何安装和配置服务。
+
+[h2] 下载
+
+从发布页面下载对应平台的压缩包，解压后即可得到可执行文件。
+
+[h2] 配置数据库
+
+服务使用 PostgreSQL 保存数据，需要在配置文件中填写 database url 与连接池大小。
+
+[h1] 常见问题
+
+[h2] 端口冲突
+
+默认监听 8080 端口，如被占用可以通过 PORT 环境变量修改。`
+
+func TestChunkContentHeadingPaths(t *testing.T) {
+	chunks := ChunkContent(testContent, 200)
+	var paths []string
+	for i, c := range chunks {
+		if c.ID != i+1 {
+			t.Errorf("chunk %d has ID %d", i, c.ID)
+		}
+		paths = append(paths, c.HeadingPath())
+	}
+
+	want := []string{"安装指南", "安装指南 > 下载", "安装指南 > 配置数据库", "常见问题 > 端口冲突"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Fatalf("heading paths = %q, want %q", paths, want)
+	}
+}
+
+func TestChunkContentSplitsLongParagraphs(t *testing.T) {
+	para := strings.Repeat("这是一个很长的句子。", 100)
+	chunks := ChunkContent("# 标题\n\n"+para, 120)
+	if len(chunks) < 2 {
+		t.Fatalf("expected long paragraph to be split, got %d chunks", len(chunks))
+	}
+	for _, c := range chunks {
+		if n := len([]rune(c.Text)); n > 120 {
+			t.Errorf("chunk %d has %d runes, want <= 120", c.ID, n)
+		}
+		if c.HeadingPath() != "标题" {
+			t.Errorf("chunk %d heading = %q", c.ID, c.HeadingPath())
+		}
+	}
+}
+
+func TestHashEmbedderRetrieve(t *testing.T) {
+	ctx := context.Background()
+	embedder := NewHashEmbedder(256)
+	chunks := ChunkContent(testContent, 200)
+	if err := EmbedChunks(ctx, embedder, chunks); err != nil {
+		t.Fatal(err)
+	}
+
+	// 相同输入必须得到相同向量
+	again, _ := embedder.Embed(ctx, []string{chunks[0].HeadingPath() + "\n" + chunks[0].Text})
+	if !reflect.DeepEqual(again[0], chunks[0].Vector) {
+		t.Fatal("hash embedder is not deterministic")
+	}
+
+	got, err := Retrieve(ctx, embedder, chunks, "如何配置数据库连接池？", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].HeadingPath() != "安装指南 > 配置数据库" {
+		t.Fatalf("top chunk = %+v", got)
+	}
+
+	got, _ = Retrieve(ctx, embedder, chunks, "8080 端口被占用怎么办", 1)
+	if len(got) != 1 || got[0].HeadingPath() != "常见问题 > 端口冲突" {
+		t.Fatalf("top chunk = %+v", got)
+	}
+}
+
+func TestOpenAIEmbedder(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/embeddings" || r.Header.Get("Authorization") != "Bearer sk-test" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		var req EmbeddingsRequest
+		json.NewDecoder(r.Body).Decode(&req)
+
+		// 倒序返回，验证按 index 还原顺序
+		var resp EmbeddingsResponse
+		for i := len(req.Input) - 1; i >= 0; i-- {
+			item := struct {
+				Index     int       `json:"index"`
+				Embedding []float32 `json:"embedding"`
+			}{Index: i, Embedding: []float32{float32(i + 1), 0}}
+			resp.Data = append(resp.Data, item)
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer srv.Close()
+
+	embedder := NewOpenAIEmbedder(srv.URL+"/v1/", "text-embedding-3-small", "sk-test")
+	vectors, err := embedder.Embed(context.Background(), []string{"a", "b", "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vectors) != 3 {
+		t.Fatalf("got %d vectors", len(vectors))
+	}
+	for i, v := range vectors {
+		if v[0] != 1 || v[1] != 0 {
+			t.Errorf("vector %d = %v, want normalized [1 0]", i, v)
+		}
+	}
+
+	embedder = NewOpenAIEmbedder(srv.URL+"/v1", "m", "wrong")
+	if _, err := embedder.Embed(context.Background(), []string{"a"}); err == nil {
+		t.Fatal("expected error for rejected request")
+	}
+}
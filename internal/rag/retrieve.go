@@ -0,0 +1,77 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// EmbedChunks 为片段生成向量，标题路径参与向量化以便按章节检索
+func EmbedChunks(ctx context.Context, embedder Embedder, chunks []Chunk) error {
+	if len(chunks) == 0 {
+		return nil
+	}
+	texts := make([]string, len(chunks))
+	for i, c := range chunks {
+		texts[i] = c.HeadingPath() + "\n" + c.Text
+	}
+
+	vectors, err := embedder.Embed(ctx, texts)
+	if err != nil {
+		return fmt.Errorf("生成片段向量失败: %w", err)
+	}
+	for i := range chunks {
+		chunks[i].Vector = vectors[i]
+	}
+	return nil
+}
+
+// Retrieve 返回与问题最相关的 k 个片段，按相关度从高到低排列
+func Retrieve(ctx context.Context, embedder Embedder, chunks []Chunk, query string, k int) ([]Chunk, error) {
+	if len(chunks) == 0 || k <= 0 {
+		return nil, nil
+	}
+
+	vectors, err := embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, fmt.Errorf("生成问题向量失败: %w", err)
+	}
+	return TopK(chunks, vectors[0], k), nil
+}
+
+// TopK 按余弦相似度选出前 k 个片段，按相关度从高到低排列。向量维度不匹配的片段不参与排序。
+func TopK(chunks []Chunk, query []float32, k int) []Chunk {
+	type scored struct {
+		chunk Chunk
+		score float32
+	}
+	var candidates []scored
+	for _, c := range chunks {
+		if len(c.Vector) != len(query) {
+			continue
+		}
+		candidates = append(candidates, scored{chunk: c, score: dot(c.Vector, query)})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+	if len(candidates) > k {
+		candidates = candidates[:k]
+	}
+
+	result := make([]Chunk, len(candidates))
+	for i, c := range candidates {
+		result[i] = c.chunk
+	}
+	return result
+}
+
+// dot 计算两个等长向量的点积
+func dot(a, b []float32) float32 {
+	var sum float32
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
@@ -0,0 +1,211 @@
+package scraper
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	neturl "net/url"
+	"sync"
+	"time"
+
+	"github.com/eust-w/urlreader/internal/logger"
+)
+
+// 缓存模式
+const (
+	// CacheDefault 有效期内直接使用缓存，过期后向服务端重新验证
+	CacheDefault = ""
+	// CacheBypass 不读取缓存，重新抓取并更新缓存
+	CacheBypass = "bypass"
+	// CachePrefer 有缓存时直接使用，不论是否过期
+	CachePrefer = "prefer"
+	// CacheOnly 只使用缓存，没有缓存时返回 ErrNotCached，不访问网络
+	CacheOnly = "only"
+)
+
+// 抓取结果的缓存状态
+const (
+	CacheHit         = "hit"
+	CacheRevalidated = "revalidated"
+	CacheMiss        = "miss"
+)
+
+var (
+	// ErrNotCached 表示 only 模式下没有该URL的缓存
+	ErrNotCached = errors.New("没有该URL的缓存")
+	// errNotModified 表示条件请求得到 304 响应
+	errNotModified = errors.New("内容未修改")
+)
+
+// CacheEntry 缓存的抓取结果及其验证器
+type CacheEntry struct {
+	Content      ScrapedContent `json:"content"`
+	ETag         string         `json:"etag,omitempty"`
+	LastModified string         `json:"last_modified,omitempty"`
+	// Rules 提取时站点规则的版本，规则修改后不再使用该条目
+	Rules string `json:"rules,omitempty"`
+	// StoredAt 抓取或最近一次重新验证的时间
+	StoredAt time.Time `json:"stored_at"`
+}
+
+// Cache 定义抓取结果缓存需要实现的方法，实现需可并发使用
+type Cache interface {
+	Get(key string) (CacheEntry, bool)
+	Put(key string, entry CacheEntry)
+}
+
+// newCache 按配置创建缓存，backend 为空或 none 时不使用缓存
+func newCache(backend, path string, size int) (Cache, error) {
+	switch backend {
+	case "", "none":
+		return nil, nil
+	case "memory":
+		return NewMemoryCache(size), nil
+	case "disk":
+		return NewDiskCache(path, size)
+	default:
+		return nil, fmt.Errorf("不支持的缓存类型: %s", backend)
+	}
+}
+
+// cacheKey 缓存键：输出格式、站点规则版本与规范化后的URL
+func cacheKey(url, format, rules string) string {
+	if rules != "" {
+		format += " rules:" + rules
+	}
+	u, err := neturl.Parse(url)
+	if err != nil {
+		return format + " " + url
+	}
+	return format + " " + canonicalURL(u)
+}
+
+// scrapeCached 按缓存模式抓取单个页面。过期的缓存带 If-None-Match/If-Modified-Since 重新验证，
+// 得到 304 时刷新缓存时间并返回缓存内容。
+func (s *Scraper) scrapeCached(ctx context.Context, url, format, mode string) (*ScrapedContent, error) {
+	if s.cache == nil {
+		if mode == CacheOnly {
+			return nil, fmt.Errorf("%w: 未启用抓取缓存", ErrNotCached)
+		}
+		content, _, err := s.scrape(ctx, url, format)
+		return content, err
+	}
+
+	url, err := normalizeURL(url)
+	if err != nil {
+		return nil, err
+	}
+	log := logger.GetLogger()
+	rules := s.rules.version()
+	key := cacheKey(url, format, rules)
+
+	var entry CacheEntry
+	var cached bool
+	if mode != CacheBypass {
+		entry, cached = s.cache.Get(key)
+		cached = cached && entry.Rules == rules
+	}
+	if cached && (mode == CachePrefer || mode == CacheOnly || time.Since(entry.StoredAt) < s.cacheTTL) {
+		log.Infow("使用缓存的抓取结果", "url", url, "age", time.Since(entry.StoredAt).Round(time.Second))
+		return cachedContent(entry, CacheHit), nil
+	}
+	if mode == CacheOnly {
+		return nil, fmt.Errorf("%w: %s", ErrNotCached, url)
+	}
+
+	var conditional http.Header
+	if cached && (entry.ETag != "" || entry.LastModified != "") {
+		conditional = http.Header{}
+		if entry.ETag != "" {
+			conditional.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			conditional.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+
+	content, page, err := s.scrapeConditional(ctx, url, format, conditional)
+	if errors.Is(err, errNotModified) && s.rules.version() != rules {
+		// 重新验证期间规则文件已修改，缓存的内容需要按新规则重新提取
+		log.Infow("站点规则已修改，重新抓取", "url", url)
+		rules = s.rules.version()
+		key = cacheKey(url, format, rules)
+		content, page, err = s.scrape(ctx, url, format)
+	}
+	if errors.Is(err, errNotModified) {
+		log.Infow("缓存重新验证通过", "url", url)
+		entry.StoredAt = time.Now()
+		s.cache.Put(key, entry)
+		return cachedContent(entry, CacheRevalidated), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !page.noStore {
+		s.cache.Put(key, CacheEntry{Content: *content, ETag: page.etag, LastModified: page.lastModified, Rules: rules, StoredAt: time.Now()})
+	}
+	content.Cache = CacheMiss
+	return content, nil
+}
+
+// cachedContent 返回缓存内容的副本并标注缓存状态
+func cachedContent(entry CacheEntry, status string) *ScrapedContent {
+	content := entry.Content
+	content.Cache = status
+	return &content
+}
+
+// MemoryCache 进程内的LRU缓存，超过容量时淘汰最久未使用的条目
+type MemoryCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List
+	items    map[string]*list.Element
+}
+
+// memoryItem LRU链表中的一个条目
+type memoryItem struct {
+	key   string
+	entry CacheEntry
+}
+
+// NewMemoryCache 创建最多保存 capacity 个条目的内存缓存
+func NewMemoryCache(capacity int) *MemoryCache {
+	return &MemoryCache{
+		capacity: max(capacity, 1),
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// Get 读取缓存并标记为最近使用
+func (c *MemoryCache) Get(key string) (CacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return CacheEntry{}, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*memoryItem).entry, true
+}
+
+// Put 保存缓存，超出容量时淘汰最久未使用的条目
+func (c *MemoryCache) Put(key string, entry CacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		el.Value.(*memoryItem).entry = entry
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.order.PushFront(&memoryItem{key: key, entry: entry})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*memoryItem).key)
+	}
+}
@@ -0,0 +1,182 @@
+package scraper
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eust-w/urlreader/internal/logger"
+)
+
+// DiskCache 把每个条目保存为目录中的一个JSON文件，可在重启后继续使用，也可被多个实例共享。
+// 启动时扫描一次目录，之后在内存中按访问顺序维护文件索引，条目数超过上限时淘汰最久未访问的条目。
+// 读取命中时会更新文件的修改时间，重启后仍能按访问顺序恢复索引。
+type DiskCache struct {
+	dir        string
+	maxEntries int
+	// mu 保护文件索引
+	mu sync.Mutex
+	// order 按访问时间排列的文件，最近访问的在前
+	order *list.List
+	files map[string]*list.Element
+}
+
+// diskFile 索引中的一个缓存文件
+type diskFile struct {
+	name       string
+	size       int64
+	accessedAt time.Time
+}
+
+// NewDiskCache 创建（必要时创建目录）最多保存 maxEntries 个条目的磁盘缓存
+func NewDiskCache(dir string, maxEntries int) (*DiskCache, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("缓存目录未配置")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("创建缓存目录失败: %w", err)
+	}
+	c := &DiskCache{
+		dir:        dir,
+		maxEntries: max(maxEntries, 1),
+		order:      list.New(),
+		files:      make(map[string]*list.Element),
+	}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// load 扫描目录建立索引，按文件修改时间（即最后访问时间）排序，并淘汰超出上限的条目
+func (c *DiskCache) load() error {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return fmt.Errorf("读取缓存目录失败: %w", err)
+	}
+	var files []*diskFile
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		if info, err := e.Info(); err == nil {
+			files = append(files, &diskFile{name: e.Name(), size: info.Size(), accessedAt: info.ModTime()})
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].accessedAt.After(files[j].accessedAt) })
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, f := range files {
+		c.files[f.name] = c.order.PushBack(f)
+	}
+	c.evict()
+	return nil
+}
+
+// name 返回缓存键对应的文件名，文件名为键的SHA-256
+func (c *DiskCache) name(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:]) + ".json"
+}
+
+// Get 读取缓存并标记为最近访问，文件不存在或损坏时视为未命中
+func (c *DiskCache) Get(key string) (CacheEntry, bool) {
+	name := c.name(key)
+	path := filepath.Join(c.dir, name)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		// 文件可能已被共享目录的其他实例淘汰
+		c.mu.Lock()
+		c.remove(name)
+		c.mu.Unlock()
+		return CacheEntry{}, false
+	}
+	var entry CacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		logger.GetLogger().Warnw("缓存文件损坏，忽略", "key", key, "error", err)
+		return CacheEntry{}, false
+	}
+
+	now := time.Now()
+	if err := os.Chtimes(path, now, now); err != nil {
+		logger.GetLogger().Debugw("更新缓存访问时间失败", "key", key, "error", err)
+	}
+	c.mu.Lock()
+	c.touch(name, int64(len(data)), now)
+	c.mu.Unlock()
+	return entry, true
+}
+
+// Put 先写入临时文件再重命名，读取方不会看到写了一半的文件
+func (c *DiskCache) Put(key string, entry CacheEntry) {
+	log := logger.GetLogger()
+	data, err := json.Marshal(entry)
+	if err != nil {
+		log.Errorw("序列化缓存失败", "key", key, "error", err)
+		return
+	}
+	tmp, err := os.CreateTemp(c.dir, "tmp-*")
+	if err != nil {
+		log.Errorw("写入缓存失败", "key", key, "error", err)
+		return
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	name := c.name(key)
+	if err == nil {
+		err = os.Rename(tmp.Name(), filepath.Join(c.dir, name))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		log.Errorw("写入缓存失败", "key", key, "error", err)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.touch(name, int64(len(data)), time.Now())
+	c.evict()
+}
+
+// touch 把文件记入索引并移到最近访问的位置，调用方需持有 mu
+func (c *DiskCache) touch(name string, size int64, accessedAt time.Time) {
+	if el, ok := c.files[name]; ok {
+		f := el.Value.(*diskFile)
+		f.size, f.accessedAt = size, accessedAt
+		c.order.MoveToFront(el)
+		return
+	}
+	c.files[name] = c.order.PushFront(&diskFile{name: name, size: size, accessedAt: accessedAt})
+}
+
+// remove 从索引中移除文件，调用方需持有 mu
+func (c *DiskCache) remove(name string) {
+	if el, ok := c.files[name]; ok {
+		c.order.Remove(el)
+		delete(c.files, name)
+	}
+}
+
+// evict 条目数超过上限时删除最久未访问的条目，调用方需持有 mu
+func (c *DiskCache) evict() {
+	for c.order.Len() > c.maxEntries {
+		f := c.order.Back().Value.(*diskFile)
+		c.remove(f.name)
+		if err := os.Remove(filepath.Join(c.dir, f.name)); err != nil && !os.IsNotExist(err) {
+			logger.GetLogger().Warnw("删除缓存文件失败", "file", f.name, "error", err)
+			continue
+		}
+		logger.GetLogger().Debugw("淘汰缓存文件", "file", f.name, "size", f.size, "accessed_at", f.accessedAt)
+	}
+}
@@ -0,0 +1,134 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/eust-w/urlreader/config"
+)
+
+func TestScrapeCache(t *testing.T) {
+	var requests, notModified atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, "<html><head><title>Cached</title></head><body><p>Cached page content.</p></body></html>")
+	}))
+	defer srv.Close()
+
+	s := NewScraper(&config.Config{ScrapeCacheBackend: "memory", ScrapeCacheSize: 10, ScrapeCacheTTL: 60})
+	ctx := context.Background()
+	// 规范化后与第一次请求相同的URL
+	urls := []string{srv.URL + "/page?utm_source=x", srv.URL + "/page/"}
+
+	steps := []struct {
+		url, mode, status string
+		requests          int32
+	}{
+		{urls[0], CacheDefault, CacheMiss, 1},
+		{urls[1], CacheDefault, CacheHit, 1},
+		{urls[1], CacheBypass, CacheMiss, 2},
+	}
+	for i, step := range steps {
+		content, err := s.ScrapeContext(ctx, step.url, ScrapeOptions{Cache: step.mode})
+		if err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+		if content.Cache != step.status || content.Title != "Cached" || requests.Load() != step.requests {
+			t.Errorf("step %d: cache = %q, title = %q, requests = %d", i, content.Cache, content.Title, requests.Load())
+		}
+	}
+
+	// 过期后带 If-None-Match 重新验证
+	s.cacheTTL = 0
+	content, err := s.ScrapeContext(ctx, urls[0], ScrapeOptions{})
+	if err != nil || content.Cache != CacheRevalidated || content.Content != "Cached page content." || notModified.Load() != 1 {
+		t.Errorf("revalidate: content = %+v, err = %v, 304s = %d", content, err, notModified.Load())
+	}
+	if content, err := s.ScrapeContext(ctx, urls[0], ScrapeOptions{Cache: CachePrefer}); err != nil || content.Cache != CacheHit {
+		t.Errorf("prefer: content = %+v, err = %v", content, err)
+	}
+
+	before := requests.Load()
+	if _, err := s.ScrapeContext(ctx, srv.URL+"/other", ScrapeOptions{Cache: CacheOnly}); !errors.Is(err, ErrNotCached) {
+		t.Errorf("only without cache: err = %v, want ErrNotCached", err)
+	}
+	if requests.Load() != before {
+		t.Errorf("only mode made %d requests", requests.Load()-before)
+	}
+}
+
+func TestCaches(t *testing.T) {
+	disk, err := NewDiskCache(t.TempDir(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, cache := range map[string]Cache{"memory": NewMemoryCache(2), "disk": disk} {
+		for i, key := range []string{"a", "b", "c"} {
+			cache.Put(key, CacheEntry{Content: ScrapedContent{Title: key}, ETag: key, StoredAt: time.Unix(int64(i), 0)})
+			if key == "b" {
+				// 访问过的条目不会被淘汰
+				if _, ok := cache.Get("a"); !ok {
+					t.Fatalf("%s: entry a missing", name)
+				}
+			}
+		}
+		if _, ok := cache.Get("b"); ok {
+			t.Errorf("%s: least recently used entry not evicted", name)
+		}
+		if _, ok := cache.Get("a"); !ok {
+			t.Errorf("%s: recently used entry evicted", name)
+		}
+		if entry, ok := cache.Get("c"); !ok || entry.Content.Title != "c" || entry.ETag != "c" || entry.StoredAt.Unix() != 2 {
+			t.Errorf("%s: entry = %+v, ok = %v", name, entry, ok)
+		}
+	}
+}
+
+func TestDiskCacheReload(t *testing.T) {
+	dir := t.TempDir()
+	disk, err := NewDiskCache(dir, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := time.Now().Add(-time.Hour)
+	for i, key := range []string{"a", "b", "c"} {
+		disk.Put(key, CacheEntry{ETag: key})
+		// 模拟不同的访问时间，a 最近被访问
+		at := base.Add(time.Duration(3-i) * time.Minute)
+		if err := os.Chtimes(filepath.Join(dir, disk.name(key)), at, at); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 重启时按访问时间恢复索引并淘汰超出上限的条目
+	reopened, err := NewDiskCache(dir, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := reopened.Get("c"); ok {
+		t.Error("least recently used entry not evicted on load")
+	}
+	for _, key := range []string{"a", "b"} {
+		if entry, ok := reopened.Get(key); !ok || entry.ETag != key {
+			t.Errorf("%s: entry = %+v, ok = %v", key, entry, ok)
+		}
+	}
+	if info, err := os.Stat(filepath.Join(dir, reopened.name("b"))); err != nil || time.Since(info.ModTime()) > time.Minute {
+		t.Errorf("access time not updated: info = %v, err = %v", info, err)
+	}
+}
@@ -0,0 +1,379 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	neturl "net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/eust-w/urlreader/internal/logger"
+	"github.com/eust-w/urlreader/internal/models"
+)
+
+// defaultCrawlPages 未指定页面数上限时最多抓取的页面数
+const defaultCrawlPages = 20
+
+// CrawlOptions 控制多页面抓取的范围
+type CrawlOptions struct {
+	// Format 每个页面的输出格式
+	Format string
+	// MaxDepth 从起始页面开始跟随链接的最大层数，0 表示只抓取起始页面
+	MaxDepth int
+	// MaxPages 最多抓取的页面数，包括抓取失败的页面
+	MaxPages int
+	// Include 非空时只跟随匹配其中任一模式的链接
+	Include []string
+	// Exclude 匹配其中任一模式的链接不会被跟随
+	Exclude []string
+	// Sitemap 为 true 时把站点 sitemap 中的同站页面作为第 1 层页面加入抓取队列
+	Sitemap bool
+}
+
+// CrawledPage 表示多页面抓取中的一个页面，抓取失败时只有 URL、Depth 和 Error
+type CrawledPage struct {
+	URL      string `json:"url"`
+	FinalURL string `json:"final_url,omitempty"`
+	Title    string `json:"title,omitempty"`
+	Content  string `json:"content,omitempty"`
+	Depth    int    `json:"depth"`
+	// Metadata 页面的元数据，没有时为 nil
+	Metadata *models.Metadata `json:"metadata,omitempty"`
+	// Encoding 页面的原始编码
+	Encoding string `json:"encoding,omitempty"`
+	Error    string `json:"error,omitempty"`
+	// Code 抓取失败的错误码，见 ErrorCode
+	Code string `json:"code,omitempty"`
+
+	err error
+}
+
+// Err 返回页面抓取失败的原因，可用 errors.Is 判断错误类型；抓取成功时返回 nil
+func (p CrawledPage) Err() error {
+	return p.err
+}
+
+// crawlTarget 待抓取的链接
+type crawlTarget struct {
+	url string
+	// key 去重使用的规范化URL
+	key   string
+	depth int
+}
+
+// Crawl 从起始页面开始按广度优先跟随同站链接，返回每个页面的抓取结果。
+// 起始页面总是会被抓取；页面按规范化URL去重，声明了 canonical 链接的页面也按 canonical 去重。
+// 开启 robots.txt 遵守时，被禁止的页面不会被抓取，相邻请求之间按 Crawl-delay 等待。
+// 起始页面被 robots.txt 禁止或指向内网地址时返回错误；超过时间上限时返回已抓取的页面。
+func (s *Scraper) Crawl(ctx context.Context, start string, opts CrawlOptions) ([]CrawledPage, error) {
+	format, err := normalizeFormat(opts.Format)
+	if err != nil {
+		return nil, err
+	}
+	include, err := compileGlobs(opts.Include)
+	if err != nil {
+		return nil, err
+	}
+	exclude, err := compileGlobs(opts.Exclude)
+	if err != nil {
+		return nil, err
+	}
+	if opts.MaxPages <= 0 {
+		opts.MaxPages = defaultCrawlPages
+	}
+	if opts.MaxDepth < 0 {
+		opts.MaxDepth = 0
+	}
+
+	if !strings.HasPrefix(start, "http://") && !strings.HasPrefix(start, "https://") {
+		start = "https://" + start
+	}
+	startURL, err := neturl.Parse(start)
+	if err != nil || startURL.Host == "" {
+		return nil, fmt.Errorf("无效的URL: %s", start)
+	}
+
+	ctx, cancel := s.withBudget(ctx)
+	defer cancel()
+
+	log := logger.GetLogger()
+	log.Infow("开始多页面抓取", "url", start, "max_depth", opts.MaxDepth, "max_pages", opts.MaxPages, "sitemap", opts.Sitemap)
+
+	if allowed, _ := s.robotsAllowed(ctx, startURL); !allowed {
+		log.Warnw("robots.txt 禁止抓取起始页面", "url", start, "agent", s.robotsAgent)
+		return nil, fmt.Errorf("%w: %s", ErrRobotsDisallowed, start)
+	}
+
+	site := siteHost(startURL.Hostname())
+	seen := map[string]bool{canonicalURL(startURL): true}
+	queue := []crawlTarget{{url: start, key: canonicalURL(startURL)}}
+	if opts.Sitemap {
+		for _, link := range s.discoverSitemap(ctx, startURL) {
+			if siteHost(link.Hostname()) != site || !allowedByGlobs(link, include, exclude) {
+				continue
+			}
+			key := canonicalURL(link)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			queue = append(queue, crawlTarget{url: link.String(), key: key, depth: 1})
+		}
+	}
+	var pages []CrawledPage
+	var lastFetch time.Time
+
+	for len(queue) > 0 && len(pages) < opts.MaxPages {
+		if err := ctx.Err(); err != nil {
+			break
+		}
+		target := queue[0]
+		queue = queue[1:]
+
+		if target.depth > 0 {
+			u, err := neturl.Parse(target.url)
+			if err != nil {
+				continue
+			}
+			allowed, delay := s.robotsAllowed(ctx, u)
+			if !allowed {
+				log.Infow("robots.txt 禁止抓取，跳过", "url", target.url, "agent", s.robotsAgent)
+				continue
+			}
+			if err := sleepContext(ctx, time.Until(lastFetch.Add(delay))); err != nil {
+				break
+			}
+		}
+
+		lastFetch = time.Now()
+		content, page, err := s.scrape(ctx, target.url, format)
+		err = s.budgetError(ctx, err)
+		if target.depth == 0 && errors.Is(err, ErrBlockedAddress) {
+			return nil, err
+		}
+		result := CrawledPage{URL: target.url, Depth: target.depth}
+		if err != nil {
+			result.Error = err.Error()
+			result.Code = ErrorCode(err)
+			result.err = err
+		} else {
+			result.FinalURL = content.FinalURL
+			result.Title = content.Title
+			result.Content = content.Content
+			result.Metadata = content.Metadata
+			result.Encoding = content.Encoding
+		}
+
+		if page != nil {
+			// 跳转后的地址和 canonical 链接指向已抓取过的页面时视为重复
+			final := canonicalURL(page.finalURL)
+			duplicate := final != target.key && seen[final]
+			seen[final] = true
+			if canonical := pageCanonical(page); canonical != "" && canonical != final {
+				duplicate = duplicate || seen[canonical]
+				seen[canonical] = true
+			}
+			if duplicate && target.depth > 0 {
+				continue
+			}
+
+			if target.depth < opts.MaxDepth {
+				for _, link := range pageLinks(page) {
+					if siteHost(link.Hostname()) != site || !allowedByGlobs(link, include, exclude) {
+						continue
+					}
+					key := canonicalURL(link)
+					if seen[key] {
+						continue
+					}
+					seen[key] = true
+					queue = append(queue, crawlTarget{url: link.String(), key: key, depth: target.depth + 1})
+				}
+			}
+		}
+
+		pages = append(pages, result)
+	}
+
+	log.Infow("多页面抓取完成", "url", start, "pages", len(pages), "pending", len(queue))
+	return pages, nil
+}
+
+// sleepContext 等待指定时长，ctx 结束时提前返回错误
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// skippedExtensions 明显不是网页的链接，不占用抓取预算
+var skippedExtensions = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".svg": true, ".webp": true, ".ico": true,
+	".css": true, ".js": true, ".zip": true, ".gz": true, ".tar": true, ".mp3": true, ".mp4": true,
+	".woff": true, ".woff2": true, ".ttf": true, ".exe": true, ".dmg": true,
+}
+
+// pageLinks 返回页面中所有 http(s) 链接的绝对地址，去掉锚点
+func pageLinks(page *fetchedPage) []*neturl.URL {
+	var links []*neturl.URL
+	if page.doc == nil {
+		return nil
+	}
+	for _, node := range page.doc.Find("a[href]").Nodes {
+		href := strings.TrimSpace(attr(node, "href"))
+		if href == "" || strings.HasPrefix(href, "#") {
+			continue
+		}
+		ref, err := neturl.Parse(href)
+		if err != nil {
+			continue
+		}
+		link := page.base.ResolveReference(ref)
+		if link.Scheme != "http" && link.Scheme != "https" {
+			continue
+		}
+		if skippedExtensions[strings.ToLower(path.Ext(link.Path))] {
+			continue
+		}
+		link.Fragment = ""
+		link.RawFragment = ""
+		links = append(links, link)
+	}
+	return links
+}
+
+// pageCanonical 返回页面声明的 canonical 链接的规范化形式
+func pageCanonical(page *fetchedPage) string {
+	if page.doc == nil {
+		return ""
+	}
+	href, ok := page.doc.Find(`link[rel="canonical"]`).First().Attr("href")
+	if !ok {
+		return ""
+	}
+	ref, err := neturl.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return ""
+	}
+	return canonicalURL(page.base.ResolveReference(ref))
+}
+
+// siteHost 返回用于判断同站的主机名，忽略 www. 前缀
+func siteHost(host string) string {
+	return strings.TrimPrefix(strings.ToLower(host), "www.")
+}
+
+// canonicalURL 规范化URL用于去重：小写协议与主机，去掉默认端口、锚点、跟踪参数与末尾斜杠，查询参数排序
+func canonicalURL(u *neturl.URL) string {
+	if u == nil {
+		return ""
+	}
+	c := *u
+	c.Scheme = strings.ToLower(c.Scheme)
+	host := siteHost(c.Hostname())
+	if port := c.Port(); port != "" && !(c.Scheme == "http" && port == "80") && !(c.Scheme == "https" && port == "443") {
+		host += ":" + port
+	}
+	c.Host = host
+	c.User = nil
+	c.Fragment = ""
+	c.RawFragment = ""
+	if c.Path == "" {
+		c.Path = "/"
+	}
+	if len(c.Path) > 1 {
+		c.Path = strings.TrimSuffix(c.Path, "/")
+	}
+	c.RawPath = ""
+
+	query := c.Query()
+	for key := range query {
+		if strings.HasPrefix(strings.ToLower(key), "utm_") {
+			query.Del(key)
+		}
+	}
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, key := range keys {
+		for _, value := range query[key] {
+			parts = append(parts, neturl.QueryEscape(key)+"="+neturl.QueryEscape(value))
+		}
+	}
+	c.RawQuery = strings.Join(parts, "&")
+	return c.String()
+}
+
+// compileGlobs 把URL通配模式编译为正则：** 匹配任意字符，* 匹配除 / 以外的字符，? 匹配单个字符。
+// 以协议开头的模式匹配完整URL，其余模式匹配路径（含查询参数）。
+func compileGlobs(patterns []string) ([]*regexp.Regexp, error) {
+	var compiled []*regexp.Regexp
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		var b strings.Builder
+		b.WriteString("^")
+		for i := 0; i < len(pattern); i++ {
+			switch c := pattern[i]; {
+			case c == '*' && i+1 < len(pattern) && pattern[i+1] == '*':
+				b.WriteString(".*")
+				i++
+			case c == '*':
+				b.WriteString("[^/]*")
+			case c == '?':
+				b.WriteString("[^/]")
+			default:
+				b.WriteString(regexp.QuoteMeta(string(c)))
+			}
+		}
+		b.WriteString("$")
+		re, err := regexp.Compile(b.String())
+		if err != nil {
+			return nil, fmt.Errorf("无效的URL模式 %q: %w", pattern, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// allowedByGlobs 判断链接是否满足 include/exclude 模式
+func allowedByGlobs(link *neturl.URL, include, exclude []*regexp.Regexp) bool {
+	matches := func(patterns []*regexp.Regexp) bool {
+		target := link.EscapedPath()
+		if link.RawQuery != "" {
+			target += "?" + link.RawQuery
+		}
+		for _, re := range patterns {
+			subject := target
+			if strings.HasPrefix(re.String(), "^http") {
+				subject = link.String()
+			}
+			if re.MatchString(subject) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(include) > 0 && !matches(include) {
+		return false
+	}
+	return !matches(exclude)
+}
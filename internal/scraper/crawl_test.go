@@ -0,0 +1,150 @@
+package scraper
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+
+	"github.com/eust-w/urlreader/config"
+)
+
+// newSiteServer 启动一个小型文档站点，links 为每个路径页面上的链接
+func newSiteServer(t *testing.T, links map[string][]string, extra func(path string) string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		targets, ok := links[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprintf(w, "<html><head><title>%s</title>", r.URL.Path)
+		if extra != nil {
+			fmt.Fprint(w, extra(r.URL.Path))
+		}
+		fmt.Fprintf(w, "</head><body><article><h1>%s</h1><p>Documentation page %s, long enough to be treated as the main content of the page.</p>", r.URL.Path, r.URL.Path)
+		for _, href := range targets {
+			fmt.Fprintf(w, `<a href="%s">link</a>`, href)
+		}
+		fmt.Fprint(w, "</article></body></html>")
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func crawledURLs(srv *httptest.Server, pages []CrawledPage) []string {
+	var urls []string
+	for _, p := range pages {
+		urls = append(urls, p.URL[len(srv.URL):])
+	}
+	sort.Strings(urls)
+	return urls
+}
+
+func TestCrawlDepthAndDedupe(t *testing.T) {
+	srv := newSiteServer(t, map[string][]string{
+		"/docs":        {"/docs/a", "/docs/a/", "/docs/a#intro", "/docs/b?utm_source=x", "https://other.example/x", "/logo.png"},
+		"/docs/a":      {"/docs/a/deep", "/docs"},
+		"/docs/b":      {"/docs/b/deep"},
+		"/docs/a/deep": {"/docs/a/deeper"},
+		"/docs/b/deep": {},
+	}, nil)
+
+	s := NewScraper(&config.Config{})
+	pages, err := s.Crawl(context.Background(), srv.URL+"/docs", CrawlOptions{MaxDepth: 2, MaxPages: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := fmt.Sprint(crawledURLs(srv, pages))
+	want := "[/docs /docs/a /docs/a/deep /docs/b/deep /docs/b?utm_source=x]"
+	if got != want {
+		t.Fatalf("crawled %s, want %s", got, want)
+	}
+	for _, p := range pages {
+		if p.Error != "" || p.Content == "" {
+			t.Errorf("page %s: error=%q content=%q", p.URL, p.Error, p.Content)
+		}
+	}
+
+	pages, _ = s.Crawl(context.Background(), srv.URL+"/docs", CrawlOptions{MaxDepth: 2, MaxPages: 2})
+	if len(pages) != 2 || pages[0].Depth != 0 {
+		t.Fatalf("page budget not honoured: %+v", pages)
+	}
+}
+
+func TestCrawlGlobsAndCanonical(t *testing.T) {
+	srv := newSiteServer(t, map[string][]string{
+		"/":            {"/docs/intro", "/docs/v1/old", "/blog/post", "/docs/print"},
+		"/docs/intro":  {},
+		"/docs/v1/old": {},
+		"/blog/post":   {},
+		"/docs/print":  {},
+	}, func(path string) string {
+		if path == "/docs/print" {
+			return `<link rel="canonical" href="/docs/intro">`
+		}
+		return ""
+	})
+
+	s := NewScraper(&config.Config{})
+	pages, err := s.Crawl(context.Background(), srv.URL+"/", CrawlOptions{
+		MaxDepth: 1,
+		Include:  []string{"/docs/**"},
+		Exclude:  []string{"/docs/v?/*"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(crawledURLs(srv, pages)); got != "[/ /docs/intro]" {
+		t.Fatalf("crawled %s", got)
+	}
+
+	if _, err := s.Crawl(context.Background(), srv.URL, CrawlOptions{Format: "pdf"}); err == nil {
+		t.Fatal("expected error for unsupported format")
+	}
+}
+
+func TestCrawlRobotsAndSitemap(t *testing.T) {
+	var srv *httptest.Server
+	pages := newSiteServer(t, map[string][]string{
+		"/":          {"/private/x", "/public"},
+		"/public":    {},
+		"/listed":    {},
+		"/private/x": {},
+	}, nil)
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			fmt.Fprintf(w, "User-agent: urlreader\nDisallow: /private\n\nSitemap: %s/sitemap_index.xml\n", srv.URL)
+		case "/sitemap_index.xml":
+			fmt.Fprintf(w, `<sitemapindex><sitemap><loc>%s/pages.xml.gz</loc></sitemap></sitemapindex>`, srv.URL)
+		case "/pages.xml.gz":
+			gz := gzip.NewWriter(w)
+			fmt.Fprintf(gz, `<urlset><url><loc>%s/listed</loc></url><url><loc>%s/private/y</loc></url></urlset>`, srv.URL, srv.URL)
+			gz.Close()
+		default:
+			pages.Config.Handler.ServeHTTP(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	s := NewScraper(&config.Config{RobotsUserAgent: "urlreader", RespectRobots: true})
+	crawled, err := s.Crawl(context.Background(), srv.URL+"/", CrawlOptions{MaxDepth: 1, MaxPages: 10, Sitemap: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := fmt.Sprint(crawledURLs(srv, crawled))
+	if want := "[/ /listed /public]"; got != want {
+		t.Fatalf("crawled %s, want %s", got, want)
+	}
+
+	if _, err := s.Crawl(context.Background(), srv.URL+"/private/x", CrawlOptions{}); !errors.Is(err, ErrRobotsDisallowed) {
+		t.Fatalf("disallowed start page: got %v", err)
+	}
+}
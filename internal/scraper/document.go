@@ -0,0 +1,392 @@
+package scraper
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	neturl "net/url"
+	"path"
+	"strings"
+
+	"github.com/eust-w/urlreader/internal/logger"
+	"github.com/eust-w/urlreader/internal/models"
+)
+
+// blockKind 文档块的类型
+type blockKind int
+
+const (
+	blockHeading blockKind = iota
+	blockParagraph
+	blockListItem
+	blockTable
+	blockCode
+)
+
+// docBlock 文档中的一个块。level 对标题为级别（1-6），对列表项为嵌套深度（从 0 开始）；
+// rows 只用于表格，第一行作为表头。
+type docBlock struct {
+	kind  blockKind
+	level int
+	text  string
+	rows  [][]string
+}
+
+// document 从 HTML 以外的响应（PDF、Office 文档、CSV、JSON、纯文本等）中提取的文档，
+// 按块记录结构，渲染时再转换为请求的输出格式。
+type document struct {
+	title  string
+	blocks []docBlock
+	// entries 订阅源的条目，只有 RSS/Atom 文档非 nil
+	entries []FeedEntry
+	// metadata 文档属性中的作者与日期，没有时为 nil
+	metadata *models.Metadata
+}
+
+func (d *document) heading(level int, text string) {
+	if text = collapseWhitespace(text); text != "" {
+		d.blocks = append(d.blocks, docBlock{kind: blockHeading, level: min(max(level, 1), 6), text: text})
+	}
+}
+
+func (d *document) paragraph(text string) {
+	if text = strings.TrimSpace(text); text != "" {
+		d.blocks = append(d.blocks, docBlock{kind: blockParagraph, text: text})
+	}
+}
+
+func (d *document) listItem(depth int, text string) {
+	if text = collapseWhitespace(text); text != "" {
+		d.blocks = append(d.blocks, docBlock{kind: blockListItem, level: max(depth, 0), text: text})
+	}
+}
+
+func (d *document) table(rows [][]string) {
+	if len(rows) > 0 {
+		d.blocks = append(d.blocks, docBlock{kind: blockTable, rows: rows})
+	}
+}
+
+func (d *document) code(text string) {
+	if strings.TrimSpace(text) != "" {
+		d.blocks = append(d.blocks, docBlock{kind: blockCode, text: strings.Trim(text, "\n")})
+	}
+}
+
+// render 按输出格式渲染文档，与网页正文的三种格式保持一致，连续的列表项合并为一个列表
+func (d *document) render(format string) string {
+	var parts []string
+	for i := 0; i < len(d.blocks); i++ {
+		if d.blocks[i].kind == blockListItem {
+			j := i
+			for j < len(d.blocks) && d.blocks[j].kind == blockListItem {
+				j++
+			}
+			parts = append(parts, renderList(d.blocks[i:j], format))
+			i = j - 1
+			continue
+		}
+		parts = append(parts, renderBlock(d.blocks[i], format))
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// renderBlock 渲染列表项以外的块
+func renderBlock(b docBlock, format string) string {
+	switch format {
+	case FormatMarkdown:
+		switch b.kind {
+		case blockHeading:
+			return strings.Repeat("#", b.level) + " " + b.text
+		case blockTable:
+			return markdownTable(b.rows)
+		case blockCode:
+			fence := "```"
+			for strings.Contains(b.text, fence) {
+				fence += "`"
+			}
+			return fence + "\n" + b.text + "\n" + fence
+		}
+		return b.text
+	case FormatHTML:
+		switch b.kind {
+		case blockHeading:
+			return fmt.Sprintf("<h%d>%s</h%d>", b.level, html.EscapeString(b.text), b.level)
+		case blockTable:
+			return htmlTable(b.rows)
+		case blockCode:
+			return "<pre><code>" + html.EscapeString(b.text) + "</code></pre>"
+		}
+		return "<p>" + strings.ReplaceAll(html.EscapeString(b.text), "\n", "<br>\n") + "</p>"
+	default:
+		switch b.kind {
+		case blockHeading:
+			return fmt.Sprintf("[h%d] %s", b.level, b.text)
+		case blockTable:
+			lines := []string{"[表格]"}
+			for _, row := range b.rows {
+				lines = append(lines, strings.Join(row, " | "))
+			}
+			return strings.Join(lines, "\n")
+		}
+		return b.text
+	}
+}
+
+// renderList 渲染连续的列表项，嵌套深度在 HTML 中转换为嵌套列表，其余格式按缩进表示
+func renderList(items []docBlock, format string) string {
+	if format != FormatHTML {
+		lines := make([]string, len(items))
+		for i, item := range items {
+			lines[i] = strings.Repeat("  ", item.level) + "- " + item.text
+		}
+		return strings.Join(lines, "\n")
+	}
+
+	var b strings.Builder
+	level := -1
+	for _, item := range items {
+		// 深度一次最多增加一层，避免出现空的列表项
+		depth := min(item.level, level+1)
+		if depth > level {
+			b.WriteString("<ul>")
+		} else {
+			b.WriteString("</li>")
+			for ; level > depth; level-- {
+				b.WriteString("</ul></li>")
+			}
+		}
+		level = depth
+		b.WriteString("<li>" + html.EscapeString(item.text))
+	}
+	b.WriteString("</li>")
+	for ; level > 0; level-- {
+		b.WriteString("</ul></li>")
+	}
+	b.WriteString("</ul>")
+	return b.String()
+}
+
+// markdownTable 渲染GFM表格，第一行作为表头
+func markdownTable(rows [][]string) string {
+	columns := 0
+	for _, row := range rows {
+		columns = max(columns, len(row))
+	}
+
+	var b strings.Builder
+	writeRow := func(row []string) {
+		b.WriteString("|")
+		for i := 0; i < columns; i++ {
+			cell := ""
+			if i < len(row) {
+				cell = strings.ReplaceAll(collapseWhitespace(row[i]), "|", `\|`)
+			}
+			b.WriteString(" " + cell + " |")
+		}
+		b.WriteString("\n")
+	}
+
+	writeRow(rows[0])
+	b.WriteString("|")
+	for i := 0; i < columns; i++ {
+		b.WriteString(" --- |")
+	}
+	b.WriteString("\n")
+	for _, row := range rows[1:] {
+		writeRow(row)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// htmlTable 渲染HTML表格，第一行作为表头
+func htmlTable(rows [][]string) string {
+	var b strings.Builder
+	writeRow := func(row []string, cell string) {
+		b.WriteString("<tr>")
+		for _, text := range row {
+			b.WriteString("<" + cell + ">" + html.EscapeString(text) + "</" + cell + ">")
+		}
+		b.WriteString("</tr>\n")
+	}
+
+	b.WriteString("<table>\n<thead>\n")
+	writeRow(rows[0], "th")
+	b.WriteString("</thead>\n<tbody>\n")
+	for _, row := range rows[1:] {
+		writeRow(row, "td")
+	}
+	b.WriteString("</tbody>\n</table>")
+	return b.String()
+}
+
+// documentFormat 一种非 HTML 文档格式的识别方式与解析函数
+type documentFormat struct {
+	name       string
+	mediaTypes []string
+	extensions []string
+	// magic 按文件头识别，为空时不按文件头识别
+	magic func(head []byte) bool
+	// text 为 true 时解析前按响应声明的字符集转换为UTF-8
+	text  bool
+	parse func(data []byte) (*document, error)
+}
+
+// documentFormats 支持的非 HTML 文档格式，识别时按顺序匹配
+var documentFormats = []*documentFormat{
+	{
+		name:       "pdf",
+		mediaTypes: []string{"application/pdf"},
+		extensions: []string{".pdf"},
+		magic:      func(head []byte) bool { return bytes.HasPrefix(head, []byte(pdfMagic)) },
+		parse:      parsePDF,
+	},
+	{
+		name: "office",
+		mediaTypes: []string{
+			"application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+			"application/vnd.openxmlformats-officedocument.presentationml.presentation",
+		},
+		extensions: []string{".docx", ".pptx"},
+		magic:      func(head []byte) bool { return bytes.HasPrefix(head, []byte("PK\x03\x04")) },
+		parse:      parseOffice,
+	},
+	{
+		name:       "feed",
+		mediaTypes: []string{"application/rss+xml", "application/atom+xml", "application/rdf+xml"},
+		extensions: []string{".rss", ".atom"},
+		magic:      isFeed,
+		text:       true,
+		parse:      parseFeed,
+	},
+	{
+		name:       "csv",
+		mediaTypes: []string{"text/csv", "application/csv", "text/tab-separated-values"},
+		extensions: []string{".csv", ".tsv"},
+		text:       true,
+		parse:      parseCSV,
+	},
+	{
+		name:       "json",
+		mediaTypes: []string{"application/json", "text/json"},
+		extensions: []string{".json"},
+		text:       true,
+		parse:      parseJSON,
+	},
+	{
+		name:       "markdown",
+		mediaTypes: []string{"text/markdown", "text/x-markdown"},
+		extensions: []string{".md", ".markdown"},
+		text:       true,
+		parse:      parseMarkdown,
+	},
+	{
+		name:       "text",
+		mediaTypes: []string{"text/plain"},
+		extensions: []string{".txt"},
+		text:       true,
+		parse:      parsePlainText,
+	},
+}
+
+// detectDocument 判断响应是否为支持的非 HTML 文档，是 HTML 或无法识别时返回 nil。
+// 依次按明确的媒体类型、文件头、URL扩展名识别；text/plain 与 application/octet-stream
+// 常被用于各种文件，因此只在扩展名无法识别时才把 text/plain 当作纯文本。
+func detectDocument(contentType, urlPath string, head []byte) *documentFormat {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	mediaType = strings.ToLower(mediaType)
+
+	if mediaType != "text/plain" {
+		for _, f := range documentFormats {
+			for _, t := range f.mediaTypes {
+				if t == mediaType {
+					return f
+				}
+			}
+		}
+		if strings.HasSuffix(mediaType, "+json") {
+			return documentFormatByName("json")
+		}
+	}
+	for _, f := range documentFormats {
+		if f.magic != nil && f.magic(head) {
+			return f
+		}
+	}
+	if mediaType == "text/html" || mediaType == "application/xhtml+xml" {
+		return nil
+	}
+	ext := strings.ToLower(path.Ext(urlPath))
+	for _, f := range documentFormats {
+		for _, e := range f.extensions {
+			if e == ext {
+				return f
+			}
+		}
+	}
+	if mediaType == "text/plain" {
+		return documentFormatByName("text")
+	}
+	return nil
+}
+
+// documentFormatByName 按名称查找文档格式
+func documentFormatByName(name string) *documentFormat {
+	for _, f := range documentFormats {
+		if f.name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// fetchDocument 读取并解析非 HTML 文档，文档没有标题时使用文件名。body 已按大小上限限制读取量。
+func fetchDocument(body io.Reader, format *documentFormat, contentType string, finalURL *neturl.URL) (*fetchedPage, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("读取文档失败: %w", err)
+	}
+	var encoding string
+	if format.text {
+		var declared func([]byte) string
+		if format.name == "feed" {
+			declared = xmlCharset
+		}
+		var source string
+		data, encoding, source = decodeBody(data, contentType, declared)
+		logger.GetLogger().Infow("识别文档编码", "url", finalURL.String(), "encoding", encoding, "source", source)
+	}
+
+	doc, err := format.parse(data)
+	if err != nil {
+		return nil, err
+	}
+	if doc.title == "" {
+		doc.title = documentTitle(finalURL)
+	}
+	logger.GetLogger().Infow("解析文档完成", "url", finalURL.String(), "format", format.name, "blocks", len(doc.blocks))
+	return &fetchedPage{document: doc, finalURL: finalURL, base: finalURL, encoding: encoding}, nil
+}
+
+// documentMetadata 由文档属性中的作者与时间构造元数据，都为空时返回 nil
+func documentMetadata(author, published, modified string) *models.Metadata {
+	author = strings.TrimSpace(author)
+	if author == "" && published == "" && modified == "" {
+		return nil
+	}
+	return &models.Metadata{Author: author, Published: published, Modified: modified}
+}
+
+// documentTitle 返回URL中的文件名，没有文件名时返回主机名
+func documentTitle(u *neturl.URL) string {
+	name := path.Base(u.Path)
+	if name == "." || name == "/" {
+		return u.Hostname()
+	}
+	if unescaped, err := neturl.PathUnescape(name); err == nil {
+		return unescaped
+	}
+	return name
+}
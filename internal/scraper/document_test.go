@@ -0,0 +1,197 @@
+package scraper
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/eust-w/urlreader/config"
+)
+
+// buildPDF 生成一个使用等宽 Helvetica 字体的最小 PDF，每个元素是一页的内容流
+func buildPDF(title string, streams ...string) []byte {
+	widths := strings.TrimSpace(strings.Repeat("600 ", 95))
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"", // 页面树，页面对象编号确定后填充
+		fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /FirstChar 32 /LastChar 126 /Widths [%s] >>", widths),
+		fmt.Sprintf("<< /Title (%s) >>", title),
+	}
+	var kids []string
+	for _, stream := range streams {
+		objects = append(objects, fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(stream), stream))
+		objects = append(objects, fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", len(objects)))
+		kids = append(kids, fmt.Sprintf("%d 0 R", len(objects)))
+	}
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids))
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R /Info 4 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
+
+func TestScrapePDF(t *testing.T) {
+	data := buildPDF("Annual Report",
+		// 第二行先于第一行绘制，单词分两次绘制，验证阅读顺序与补空格
+		"BT /F1 12 Tf 72 680 Td (Revenue grew.) Tj ET BT /F1 12 Tf 72 700 Td (Executive) Tj 84 0 Td (summary) Tj ET BT /F1 12 Tf 72 600 Td (Second paragraph.) Tj ET",
+		"",
+		"BT /F1 12 Tf 72 700 Td (Appendix) Tj ET",
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 故意声明为通用二进制类型，按文件头识别
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(data)
+	}))
+	defer srv.Close()
+
+	s := NewScraper(&config.Config{})
+	content, err := s.Scrape(srv.URL+"/report.pdf", ScrapeOptions{Format: FormatMarkdown})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content.Title != "Annual Report" {
+		t.Errorf("title = %q", content.Title)
+	}
+	want := "## 第 1 页\n\nExecutive summary Revenue grew.\n\nSecond paragraph.\n\n## 第 3 页\n\nAppendix"
+	if content.Content != want {
+		t.Errorf("content = %q, want %q", content.Content, want)
+	}
+
+	content, err = s.Scrape(srv.URL+"/report.pdf", ScrapeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(content.Content, "[h2] 第 1 页\n\nExecutive summary") {
+		t.Errorf("text content = %q", content.Content)
+	}
+}
+
+func TestScrapeBrokenPDF(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Write([]byte("%PDF-1.4\nnot really a pdf"))
+	}))
+	defer srv.Close()
+
+	if _, err := NewScraper(&config.Config{}).ScrapeContext(context.Background(), srv.URL, ScrapeOptions{}); err == nil || !strings.Contains(err.Error(), "PDF") {
+		t.Fatalf("expected PDF error, got %v", err)
+	}
+}
+
+// buildZip 生成包含给定部件的压缩包
+func buildZip(t *testing.T, parts map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range parts {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// serveFiles 按路径返回固定内容与类型的文件
+func serveFiles(t *testing.T, files map[string][2]string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		file, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if file[0] != "" {
+			w.Header().Set("Content-Type", file[0])
+		}
+		w.Write([]byte(file[1]))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestScrapeDocuments(t *testing.T) {
+	const w = `xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"`
+	docx := buildZip(t, map[string]string{
+		"docProps/core.xml": `<cp:coreProperties xmlns:cp="x" xmlns:dc="http://purl.org/dc/elements/1.1/"><dc:title>Design Doc</dc:title></cp:coreProperties>`,
+		"word/styles.xml":   `<w:styles ` + w + `><w:style w:styleId="1"><w:name w:val="heading 1"/></w:style></w:styles>`,
+		"word/document.xml": `<w:document ` + w + `><w:body>
+<w:p><w:pPr><w:pStyle w:val="1"/></w:pPr><w:r><w:t>Overview</w:t></w:r></w:p>
+<w:p><w:r><w:t xml:space="preserve">Plain </w:t></w:r><w:r><w:t>paragraph.</w:t></w:r></w:p>
+<w:p><w:pPr><w:numPr><w:ilvl w:val="1"/></w:numPr></w:pPr><w:r><w:t>Nested item</w:t></w:r></w:p>
+<w:tbl><w:tr><w:tc><w:p><w:r><w:t>Name</w:t></w:r></w:p></w:tc><w:tc><w:p><w:r><w:t>Value</w:t></w:r></w:p></w:tc></w:tr>
+<w:tr><w:tc><w:p><w:r><w:t>a|b</w:t></w:r></w:p></w:tc><w:tc><w:p><w:r><w:t>1</w:t></w:r></w:p></w:tc></w:tr></w:tbl>
+</w:body></w:document>`,
+	})
+	pptx := buildZip(t, map[string]string{
+		"ppt/presentation.xml": `<p:presentation xmlns:p="p"/>`,
+		"ppt/slides/slide10.xml": `<p:sld xmlns:p="p" xmlns:a="a"><p:cSld><p:spTree>
+<p:sp><p:nvSpPr><p:nvPr><p:ph type="title"/></p:nvPr></p:nvSpPr><p:txBody><a:p><a:r><a:t>Later</a:t></a:r></a:p></p:txBody></p:sp>
+</p:spTree></p:cSld></p:sld>`,
+		"ppt/slides/slide2.xml": `<p:sld xmlns:p="p" xmlns:a="a"><p:cSld><p:spTree>
+<p:sp><p:nvSpPr><p:nvPr><p:ph type="title"/></p:nvPr></p:nvSpPr><p:txBody><a:p><a:r><a:t>Roadmap</a:t></a:r></a:p></p:txBody></p:sp>
+<p:sp><p:nvSpPr><p:nvPr><p:ph idx="1"/></p:nvPr></p:nvSpPr><p:txBody><a:p><a:r><a:t>Ship it</a:t></a:r></a:p><a:p><a:pPr lvl="1"/><a:r><a:t>Soon</a:t></a:r></a:p></p:txBody></p:sp>
+</p:spTree></p:cSld></p:sld>`,
+	})
+
+	srv := serveFiles(t, map[string][2]string{
+		"/spec":      {"application/octet-stream", string(docx)},
+		"/deck.pptx": {"application/vnd.openxmlformats-officedocument.presentationml.presentation", string(pptx)},
+		"/data.csv":  {"text/plain", "name;score\nalice;3\nbob;5\n"},
+		"/api/items": {"application/json; charset=utf-8", `{"total":2,"items":[{"id":1,"name":"x"},{"id":2}],"meta":{"tags":["a","b"],"owner":null}}`},
+		"/notes.md":  {"", "---\ntitle: ignored\n---\n# Notes\n\nSome *text*\nsecond line\n\n```go\n# not a heading\n```\n"},
+		"/readme":    {"text/plain", "Line one\nline two\n\n\nNext paragraph"},
+		"/image.png": {"image/png", "\x89PNG"},
+	})
+
+	s := NewScraper(&config.Config{})
+	cases := []struct {
+		path, format, title, content string
+	}{
+		{"/spec", FormatMarkdown, "Design Doc", "# Overview\n\nPlain paragraph.\n\n  - Nested item\n\n| Name | Value |\n| --- | --- |\n| a\\|b | 1 |"},
+		{"/spec", FormatText, "Design Doc", "[h1] Overview\n\nPlain paragraph.\n\n  - Nested item\n\n[表格]\nName | Value\na|b | 1"},
+		{"/deck.pptx", FormatMarkdown, "Roadmap", "## 第 1 张幻灯片：Roadmap\n\n- Ship it\n  - Soon\n\n## 第 2 张幻灯片：Later"},
+		{"/deck.pptx", FormatHTML, "Roadmap", "<h2>第 1 张幻灯片：Roadmap</h2>\n\n<ul><li>Ship it<ul><li>Soon</li></ul></li></ul>\n\n<h2>第 2 张幻灯片：Later</h2>"},
+		{"/data.csv", FormatMarkdown, "data.csv", "| name | score |\n| --- | --- |\n| alice | 3 |\n| bob | 5 |"},
+		{"/api/items", FormatMarkdown, "items", "- total: 2\n- items:\n\n| id | name |\n| --- | --- |\n| 1 | x |\n| 2 |  |\n\n- meta:\n  - tags:\n    - a\n    - b\n  - owner: null"},
+		{"/notes.md", FormatText, "Notes", "[h1] Notes\n\nSome *text*\nsecond line\n\n# not a heading"},
+		{"/readme", FormatText, "readme", "Line one\nline two\n\nNext paragraph"},
+	}
+	for _, tc := range cases {
+		content, err := s.Scrape(srv.URL+tc.path, ScrapeOptions{Format: tc.format})
+		if err != nil {
+			t.Errorf("%s (%s): %v", tc.path, tc.format, err)
+			continue
+		}
+		if content.Title != tc.title {
+			t.Errorf("%s: title = %q, want %q", tc.path, content.Title, tc.title)
+		}
+		if content.Content != tc.content {
+			t.Errorf("%s (%s): content = %q, want %q", tc.path, tc.format, content.Content, tc.content)
+		}
+	}
+
+	if _, err := s.ScrapeURL(srv.URL + "/image.png"); err == nil || !strings.Contains(err.Error(), "不支持的内容类型") {
+		t.Errorf("image: got %v", err)
+	}
+}
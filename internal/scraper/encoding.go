@@ -0,0 +1,174 @@
+package scraper
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/saintfish/chardet"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+// 字符集的识别来源
+const (
+	charsetBOM     = "bom"
+	charsetHeader  = "header"
+	charsetMeta    = "meta"
+	charsetSniff   = "sniff"
+	charsetDefault = "default"
+)
+
+const (
+	// declarationBytes 查找 <meta> 或 XML 声明时最多检查的字节数
+	declarationBytes = 4096
+	// sniffBytes 统计识别字符集时最多检查的字节数
+	sniffBytes = 64 << 10
+)
+
+// boms 字节顺序标记及其对应的编码
+var boms = []struct {
+	bom  []byte
+	name string
+}{
+	{[]byte{0xef, 0xbb, 0xbf}, "utf-8"},
+	{[]byte{0xfe, 0xff}, "utf-16be"},
+	{[]byte{0xff, 0xfe}, "utf-16le"},
+}
+
+// xmlEncoding 匹配 XML 声明中的 encoding
+var xmlEncoding = regexp.MustCompile(`^\s*<\?xml[^>]*?encoding\s*=\s*["']([A-Za-z0-9._:-]+)["']`)
+
+// decodeBody 把响应正文转换为UTF-8，返回转换后的内容、编码名称与识别来源。
+// declared 从文档开头查找文档内的编码声明，可为 nil。
+func decodeBody(data []byte, contentType string, declared func([]byte) string) ([]byte, string, string) {
+	name, source := detectCharset(data, contentType, declared)
+	if name != "utf-8" {
+		if enc, _ := charset.Lookup(name); enc != nil {
+			if decoded, err := enc.NewDecoder().Bytes(data); err == nil {
+				data = decoded
+			}
+		}
+	}
+	return bytes.TrimPrefix(data, boms[0].bom), name, source
+}
+
+// detectCharset 依次按 BOM、HTTP 头、文档内声明与统计识别确定编码。
+// 声明为 UTF-8 但内容不是合法 UTF-8 的声明不可信，继续向后识别；
+// 很多服务端默认声明 ISO-8859-1，因此 HTTP 头为 windows-1252 时文档内的声明优先。
+func detectCharset(data []byte, contentType string, declared func([]byte) string) (string, string) {
+	for _, b := range boms {
+		if bytes.HasPrefix(data, b.bom) {
+			return b.name, charsetBOM
+		}
+	}
+
+	header := trustedCharset(contentCharset(contentType), data)
+	if header != "" && header != "windows-1252" {
+		return header, charsetHeader
+	}
+	if declared != nil {
+		if name := trustedCharset(declared(data[:min(len(data), declarationBytes)]), data); name != "" {
+			return name, charsetMeta
+		}
+	}
+	if header != "" {
+		return header, charsetHeader
+	}
+
+	if utf8.Valid(data) {
+		return "utf-8", charsetSniff
+	}
+	if name := sniffCharset(data, declared != nil); name != "" {
+		return name, charsetSniff
+	}
+	return "windows-1252", charsetDefault
+}
+
+// trustedCharset 返回编码标签对应的规范名称，标签无效或与内容矛盾时返回空字符串
+func trustedCharset(label string, data []byte) string {
+	if label == "" {
+		return ""
+	}
+	_, name := charset.Lookup(label)
+	if name == "utf-8" && !utf8.Valid(data) {
+		return ""
+	}
+	return name
+}
+
+// sniffCharset 按字节分布统计识别编码，无法识别或识别结果不是HTML标准编码时返回空字符串
+func sniffCharset(data []byte, isHTML bool) string {
+	detector := chardet.NewTextDetector()
+	if isHTML {
+		detector = chardet.NewHtmlDetector()
+	}
+	result, err := detector.DetectBest(data[:min(len(data), sniffBytes)])
+	if err != nil {
+		return ""
+	}
+	// chardet 使用 IANA 名称，如 GB-18030
+	_, name := charset.Lookup(result.Charset)
+	if name == "" {
+		_, name = charset.Lookup(strings.ReplaceAll(result.Charset, "-", ""))
+	}
+	return name
+}
+
+// contentCharset 返回 Content-Type 中的 charset 参数，容忍不规范的写法
+func contentCharset(contentType string) string {
+	i := strings.Index(strings.ToLower(contentType), "charset=")
+	if i < 0 {
+		return ""
+	}
+	value := strings.TrimLeft(contentType[i+len("charset="):], ` "'`)
+	if end := strings.IndexAny(value, ` ;,"'`); end >= 0 {
+		value = value[:end]
+	}
+	return value
+}
+
+// htmlCharset 返回 <meta charset> 或 <meta http-equiv="Content-Type"> 声明的编码，遇到 <body> 时停止查找
+func htmlCharset(head []byte) string {
+	z := html.NewTokenizer(bytes.NewReader(head))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return ""
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			switch string(name) {
+			case "body":
+				return ""
+			case "meta":
+				var httpEquiv, content string
+				for hasAttr {
+					var key, value []byte
+					key, value, hasAttr = z.TagAttr()
+					switch string(key) {
+					case "charset":
+						return strings.TrimSpace(string(value))
+					case "http-equiv":
+						httpEquiv = string(value)
+					case "content":
+						content = string(value)
+					}
+				}
+				if strings.EqualFold(strings.TrimSpace(httpEquiv), "content-type") {
+					if cs := contentCharset(content); cs != "" {
+						return cs
+					}
+				}
+			}
+		}
+	}
+}
+
+// xmlCharset 返回 XML 声明中的编码
+func xmlCharset(head []byte) string {
+	if m := xmlEncoding.FindSubmatch(head); m != nil {
+		return string(m[1])
+	}
+	return ""
+}
@@ -0,0 +1,79 @@
+package scraper
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/eust-w/urlreader/config"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func TestDecodeBody(t *testing.T) {
+	simplified := strings.Repeat("这是一个用于测试字符集识别的中文网页，内容包含常见的汉字与标点符号。", 10)
+	traditional := strings.Repeat("這是一個用於測試字元集識別的中文網頁，內容包含常見的漢字與標點符號。", 10)
+	encode := func(enc encoding.Encoding, s string) []byte {
+		data, err := enc.NewEncoder().Bytes([]byte(s))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	page := func(meta, text string) string {
+		return "<html><head>" + meta + "<title>Title</title></head><body><p>" + text + "</p></body></html>"
+	}
+	utf16 := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM)
+
+	tests := []struct {
+		name, contentType string
+		data              []byte
+		text              string
+		declared          func([]byte) string
+		encoding, source  string
+	}{
+		{"header", "text/html; charset=GBK", encode(simplifiedchinese.GBK, page("", simplified)), simplified, htmlCharset, "gbk", charsetHeader},
+		{"bom", "text/html; charset=gbk", encode(utf16, page("", simplified)), simplified, htmlCharset, "utf-16le", charsetBOM},
+		{"meta charset", "text/html", encode(traditionalchinese.Big5, page(`<meta charset="big5">`, traditional)), traditional, htmlCharset, "big5", charsetMeta},
+		{"http-equiv", "text/html", encode(simplifiedchinese.GBK, page(`<meta http-equiv="Content-Type" content="text/html; charset=gb2312">`, simplified)), simplified, htmlCharset, "gbk", charsetMeta},
+		// 声明为 UTF-8 但内容不是 UTF-8
+		{"wrong header", "text/html; charset=utf-8", encode(simplifiedchinese.GBK, page(`<meta charset="gbk">`, simplified)), simplified, htmlCharset, "gbk", charsetMeta},
+		// 服务端默认的 ISO-8859-1 让位于页面声明
+		{"latin1 header", "text/html; charset=ISO-8859-1", encode(simplifiedchinese.GBK, page(`<meta charset="gbk">`, simplified)), simplified, htmlCharset, "gbk", charsetMeta},
+		{"sniff", "text/html", encode(simplifiedchinese.GB18030, page("", simplified)), simplified, htmlCharset, "gb18030", charsetSniff},
+		{"utf-8", "", []byte(page("", simplified)), simplified, htmlCharset, "utf-8", charsetSniff},
+		{"xml declaration", "application/rss+xml", encode(simplifiedchinese.GBK, `<?xml version="1.0" encoding="GB2312"?><rss><channel><title>`+simplified+`</title></channel></rss>`), simplified, xmlCharset, "gbk", charsetMeta},
+		{"text sniff", "text/plain", encode(traditionalchinese.Big5, traditional), traditional, nil, "big5", charsetSniff},
+	}
+	for _, tt := range tests {
+		data, name, source := decodeBody(tt.data, tt.contentType, tt.declared)
+		if name != tt.encoding || source != tt.source {
+			t.Errorf("%s: encoding = %q (%s), want %q (%s)", tt.name, name, source, tt.encoding, tt.source)
+		}
+		if !strings.Contains(string(data), tt.text) || strings.HasPrefix(string(data), "\ufeff") {
+			t.Errorf("%s: decoded = %.60q", tt.name, data)
+		}
+	}
+}
+
+func TestScrapeEncoding(t *testing.T) {
+	body, _ := simplifiedchinese.GBK.NewEncoder().String(`<html><head><meta charset="gbk"><title>中文标题</title></head><body><p>` +
+		strings.Repeat("这是正文内容。", 20) + `</p></body></html>`)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, body)
+	}))
+	defer srv.Close()
+
+	content, err := NewScraper(&config.Config{}).ScrapeURL(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content.Encoding != "gbk" || content.Title != "中文标题" || !strings.Contains(content.Content, "这是正文内容。") {
+		t.Errorf("content = %+v", content)
+	}
+}
@@ -0,0 +1,350 @@
+package scraper
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	neturl "net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/eust-w/urlreader/internal/logger"
+	"golang.org/x/net/html"
+)
+
+// ErrNotFeed 表示URL返回的不是 RSS/Atom 订阅源
+var ErrNotFeed = errors.New("不是 RSS/Atom 订阅源")
+
+// feedSummaryRunes 订阅源概览中每个条目摘要的最大字符数
+const feedSummaryRunes = 600
+
+// dateLayouts 订阅源与网页元数据中常见的日期格式：RSS 使用 RFC 822 的各种变体，Atom 与 meta 标签多为 RFC 3339
+var dateLayouts = []string{
+	time.RFC1123Z, time.RFC1123, time.RFC3339, time.RFC3339Nano,
+	"Mon, 2 Jan 2006 15:04:05 -0700", "Mon, 2 Jan 2006 15:04:05 MST", "2 Jan 2006 15:04:05 -0700",
+	"Mon, 02 Jan 2006 15:04 -0700", time.RFC822Z, time.RFC822, "2006-01-02T15:04:05-0700",
+	"2006-01-02T15:04Z07:00", "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02", "2006/01/02",
+}
+
+// FeedEntry 订阅源中的一个条目
+type FeedEntry struct {
+	Title string `json:"title"`
+	URL   string `json:"url,omitempty"`
+	// Published 发布时间，订阅源未提供或无法解析时为零值
+	Published time.Time `json:"published"`
+	// Summary 条目的摘要或正文，已去除HTML标签
+	Summary string `json:"summary,omitempty"`
+}
+
+// FeedOptions 控制订阅源的读取
+type FeedOptions struct {
+	// Format 概览与文章正文的输出格式
+	Format string
+	// Articles 抓取最新多少篇文章的全文，0 表示只使用订阅源中的摘要
+	Articles int
+}
+
+// Feed 读取订阅源的结果
+type Feed struct {
+	Title    string `json:"title"`
+	URL      string `json:"url"`
+	FinalURL string `json:"final_url"`
+	// Content 订阅源概览：按发布时间从新到旧列出每个条目的标题、链接、时间与摘要
+	Content string      `json:"content"`
+	Entries []FeedEntry `json:"entries"`
+	// Articles 最新条目的全文抓取结果，抓取失败时只有 URL、Depth 和 Error
+	Articles []CrawledPage `json:"articles,omitempty"`
+}
+
+// ReadFeed 读取 RSS/Atom 订阅源，并按需抓取最新若干条目的文章全文。
+// URL 不是订阅源时返回 ErrNotFeed；文章抓取遵守 robots.txt，单篇失败不影响其他条目。
+// 订阅源与文章的抓取共用一个时间上限。
+func (s *Scraper) ReadFeed(ctx context.Context, url string, opts FeedOptions) (*Feed, error) {
+	format, err := normalizeFormat(opts.Format)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := s.withBudget(ctx)
+	defer cancel()
+	content, page, err := s.scrape(ctx, url, format)
+	if err != nil {
+		return nil, s.budgetError(ctx, err)
+	}
+	if page.document == nil || page.document.entries == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNotFeed, url)
+	}
+
+	feed := &Feed{
+		Title:    content.Title,
+		URL:      content.URL,
+		FinalURL: content.FinalURL,
+		Content:  content.Content,
+		Entries:  page.document.entries,
+	}
+
+	log := logger.GetLogger()
+	var lastFetch time.Time
+	for _, entry := range feed.Entries {
+		if len(feed.Articles) >= opts.Articles || ctx.Err() != nil {
+			break
+		}
+		if entry.URL == "" {
+			continue
+		}
+		ref, err := neturl.Parse(entry.URL)
+		if err != nil {
+			continue
+		}
+		// Atom 允许相对链接
+		target := page.finalURL.ResolveReference(ref)
+		article := CrawledPage{URL: target.String(), Depth: 1}
+		allowed, delay := s.robotsAllowed(ctx, target)
+		if !allowed {
+			article.Error = ErrRobotsDisallowed.Error()
+			article.Code = ErrorCode(ErrRobotsDisallowed)
+			article.err = ErrRobotsDisallowed
+			feed.Articles = append(feed.Articles, article)
+			continue
+		}
+		if err := sleepContext(ctx, time.Until(lastFetch.Add(delay))); err != nil {
+			break
+		}
+		lastFetch = time.Now()
+
+		if result, _, err := s.scrape(ctx, article.URL, format); err != nil {
+			err = s.budgetError(ctx, err)
+			article.Error = err.Error()
+			article.Code = ErrorCode(err)
+			article.err = err
+		} else {
+			article.FinalURL = result.FinalURL
+			article.Title = result.Title
+			article.Content = result.Content
+			article.Metadata = result.Metadata
+			article.Encoding = result.Encoding
+		}
+		feed.Articles = append(feed.Articles, article)
+	}
+
+	log.Infow("读取订阅源完成", "url", url, "entries", len(feed.Entries), "articles", len(feed.Articles))
+	return feed, nil
+}
+
+// isFeed 按根元素判断响应是否为 RSS 或 Atom 订阅源，跳过 XML 声明、注释与处理指令
+func isFeed(head []byte) bool {
+	rest := bytes.TrimPrefix(head, []byte("\xef\xbb\xbf"))
+	for {
+		rest = bytes.TrimSpace(rest)
+		if !bytes.HasPrefix(rest, []byte("<?")) && !bytes.HasPrefix(rest, []byte("<!")) {
+			break
+		}
+		end := bytes.IndexByte(rest, '>')
+		if end < 0 {
+			return false
+		}
+		rest = rest[end+1:]
+	}
+	for _, root := range []string{"<rss", "<feed", "<rdf:RDF"} {
+		if bytes.HasPrefix(rest, []byte(root)) {
+			return true
+		}
+	}
+	return false
+}
+
+// feedText 可能包含HTML的文本元素。Atom 的 xhtml 类型内容以子元素形式给出，其余类型为转义后的文本。
+type feedText struct {
+	Type  string `xml:"type,attr"`
+	Text  string `xml:",chardata"`
+	Inner string `xml:",innerxml"`
+}
+
+// String 返回元素的HTML或纯文本内容
+func (t feedText) String() string {
+	if t.Type == "xhtml" {
+		return t.Inner
+	}
+	return t.Text
+}
+
+// feedLink Atom 链接，rel 为空或 alternate 的链接指向文章
+type feedLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Text string `xml:",chardata"`
+}
+
+// feedItem 同时描述 RSS item 与 Atom entry
+type feedItem struct {
+	Title       string     `xml:"title"`
+	Links       []feedLink `xml:"link"`
+	GUID        string     `xml:"guid"`
+	PubDate     string     `xml:"pubDate"`
+	Date        string     `xml:"date"`
+	Published   string     `xml:"published"`
+	Updated     string     `xml:"updated"`
+	Description string     `xml:"description"`
+	Summary     feedText   `xml:"summary"`
+	// Encoded RSS 的 content:encoded 全文
+	Encoded string   `xml:"encoded"`
+	Content feedText `xml:"content"`
+}
+
+// feedXML 同时描述 RSS 2.0、RSS 1.0（RDF）与 Atom 文档
+type feedXML struct {
+	XMLName xml.Name
+	// RSS 2.0 的 channel 包含条目，RSS 1.0 的条目与 channel 同级
+	Channel struct {
+		Title string     `xml:"title"`
+		Items []feedItem `xml:"item"`
+	} `xml:"channel"`
+	Title   string     `xml:"title"`
+	Items   []feedItem `xml:"item"`
+	Entries []feedItem `xml:"entry"`
+}
+
+// parseFeed 解析订阅源，条目按发布时间从新到旧排列，渲染为每个条目一个二级标题的概览
+func parseFeed(data []byte) (*document, error) {
+	var raw feedXML
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.Strict = false
+	// 正文在解析前已转换为UTF-8，忽略 XML 声明中的编码
+	dec.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) { return input, nil }
+	if err := dec.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("解析订阅源失败: %w", err)
+	}
+	switch raw.XMLName.Local {
+	case "rss", "RDF", "feed":
+	default:
+		return nil, fmt.Errorf("解析订阅源失败: 不支持的根元素 <%s>", raw.XMLName.Local)
+	}
+
+	title := raw.Title
+	if raw.Channel.Title != "" {
+		title = raw.Channel.Title
+	}
+	items := append(append(raw.Channel.Items, raw.Items...), raw.Entries...)
+
+	doc := &document{title: collapseWhitespace(title), entries: []FeedEntry{}}
+	for _, item := range items {
+		doc.entries = append(doc.entries, item.entry())
+	}
+	// 未提供时间的条目保持原顺序排在最后
+	sort.SliceStable(doc.entries, func(i, j int) bool {
+		return doc.entries[i].Published.After(doc.entries[j].Published)
+	})
+
+	for _, entry := range doc.entries {
+		doc.heading(2, entry.Title)
+		if entry.URL != "" {
+			doc.paragraph("链接: " + entry.URL)
+		}
+		if !entry.Published.IsZero() {
+			doc.paragraph("发布时间: " + entry.Published.Format("2006-01-02 15:04 MST"))
+		}
+		doc.paragraph(truncateRunes(entry.Summary, feedSummaryRunes))
+	}
+	return doc, nil
+}
+
+// entry 把原始条目转换为 FeedEntry，摘要优先使用全文内容
+func (item feedItem) entry() FeedEntry {
+	entry := FeedEntry{Title: collapseWhitespace(item.Title)}
+
+	for _, link := range item.Links {
+		href := strings.TrimSpace(link.Href)
+		if href == "" {
+			href = strings.TrimSpace(link.Text)
+		}
+		if href != "" && (link.Rel == "" || link.Rel == "alternate") {
+			entry.URL = href
+			break
+		}
+	}
+	if entry.URL == "" && strings.HasPrefix(item.GUID, "http") {
+		entry.URL = strings.TrimSpace(item.GUID)
+	}
+
+	for _, value := range []string{item.PubDate, item.Published, item.Date, item.Updated} {
+		if t, ok := parseDate(value); ok {
+			entry.Published = t
+			break
+		}
+	}
+
+	for _, summary := range []string{item.Encoded, item.Content.String(), item.Description, item.Summary.String()} {
+		if strings.TrimSpace(summary) != "" {
+			entry.Summary = htmlToText(summary)
+			break
+		}
+	}
+	if entry.Title == "" {
+		entry.Title = truncateRunes(entry.Summary, 60)
+	}
+	return entry
+}
+
+// parseDate 按常见格式解析日期
+func parseDate(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, false
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// htmlToText 去除条目摘要中的HTML标签，块级元素之间保留空行
+func htmlToText(s string) string {
+	if !strings.Contains(s, "<") {
+		return collapseWhitespace(s)
+	}
+	root, err := html.Parse(strings.NewReader(s))
+	if err != nil {
+		return collapseWhitespace(s)
+	}
+
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		switch {
+		case n.Type == html.TextNode:
+			b.WriteString(n.Data)
+			return
+		case n.Type == html.ElementNode && (n.Data == "script" || n.Data == "style"):
+			return
+		case n.Type == html.ElementNode && (blockTags[n.Data] || n.Data == "br"):
+			b.WriteString("\n\n")
+			defer b.WriteString("\n\n")
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(root)
+
+	var paragraphs []string
+	for _, p := range blankLines.Split(b.String(), -1) {
+		if p = collapseWhitespace(p); p != "" {
+			paragraphs = append(paragraphs, p)
+		}
+	}
+	return strings.Join(paragraphs, "\n\n")
+}
+
+// truncateRunes 截断到最多 n 个字符，截断时加省略号
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return strings.TrimSpace(string(runes[:n])) + "…"
+}
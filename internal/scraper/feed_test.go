@@ -0,0 +1,76 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/eust-w/urlreader/config"
+)
+
+func TestReadFeed(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rss":
+			// 故意声明为通用 XML 类型，按根元素识别
+			w.Header().Set("Content-Type", "text/xml")
+			fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:content="http://purl.org/rss/1.0/modules/content/"><channel><title>Eng Blog</title>
+<item><title>Older post</title><link>%[1]s/posts/old</link><pubDate>Mon, 05 Oct 2026 09:00:00 +0000</pubDate><description>Old &lt;b&gt;news&lt;/b&gt;.</description></item>
+<item><title>Newest post</title><link>%[1]s/posts/new</link><pubDate>Wed, 14 Oct 2026 09:00:00 +0000</pubDate>
+<content:encoded><![CDATA[<p>Full text of the newest post.</p>]]></content:encoded></item>
+</channel></rss>`, srv.URL)
+		case "/atom":
+			w.Header().Set("Content-Type", "application/atom+xml")
+			fmt.Fprint(w, `<feed xmlns="http://www.w3.org/2005/Atom"><title>Changelog</title>
+<entry><title>v2</title><link rel="alternate" href="/posts/new"/><updated>2026-10-15T10:00:00Z</updated>
+<content type="xhtml"><div xmlns="http://www.w3.org/1999/xhtml"><p>Second release.</p></div></content></entry>
+</feed>`)
+		case "/posts/new", "/posts/old":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprintf(w, "<html><head><title>%s</title></head><body><article><p>Article body for %s, long enough to count as the main content.</p></article></body></html>", r.URL.Path, r.URL.Path)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	s := NewScraper(&config.Config{})
+	feed, err := s.ReadFeed(context.Background(), srv.URL+"/rss", FeedOptions{Format: FormatMarkdown, Articles: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if feed.Title != "Eng Blog" || len(feed.Entries) != 2 {
+		t.Fatalf("feed = %+v", feed)
+	}
+	newest := feed.Entries[0]
+	if newest.Title != "Newest post" || newest.Summary != "Full text of the newest post." || newest.Published.Day() != 14 {
+		t.Errorf("newest entry = %+v", newest)
+	}
+	if feed.Entries[1].Summary != "Old news." {
+		t.Errorf("older summary = %q", feed.Entries[1].Summary)
+	}
+	if !strings.HasPrefix(feed.Content, "## Newest post\n\n链接: "+srv.URL+"/posts/new\n\n发布时间: 2026-10-14 09:00") {
+		t.Errorf("overview = %q", feed.Content)
+	}
+	if len(feed.Articles) != 1 || feed.Articles[0].Title != "/posts/new" || feed.Articles[0].Error != "" {
+		t.Errorf("articles = %+v", feed.Articles)
+	}
+
+	feed, err = s.ReadFeed(context.Background(), srv.URL+"/atom", FeedOptions{Articles: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if feed.Entries[0].Summary != "Second release." || len(feed.Articles) != 1 || feed.Articles[0].URL != srv.URL+"/posts/new" {
+		t.Errorf("atom feed = %+v", feed)
+	}
+
+	if _, err := s.ReadFeed(context.Background(), srv.URL+"/posts/new", FeedOptions{}); !errors.Is(err, ErrNotFeed) {
+		t.Errorf("html page: got %v", err)
+	}
+}
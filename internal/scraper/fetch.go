@@ -0,0 +1,125 @@
+package scraper
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	neturl "net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/eust-w/urlreader/internal/logger"
+)
+
+// fetchedPage 保存一次抓取得到的页面，仅属于单次请求，不在请求之间共享。
+// 响应为 PDF、Office 文档等非 HTML 内容时 doc 为 nil，内容保存在 document 中。
+type fetchedPage struct {
+	doc      *goquery.Document
+	document *document
+	finalURL *neturl.URL
+	base     *neturl.URL
+	// encoding 正文转换为UTF-8前的编码，二进制文档为空
+	encoding string
+	// etag、lastModified 响应的缓存验证器，noStore 表示响应禁止缓存
+	etag         string
+	lastModified string
+	noStore      bool
+}
+
+// fetch 下载并解析页面。每次调用都使用独立的请求与DOM，可安全并发调用。
+// conditional 非空时作为条件请求头发送，服务端返回 304 时返回 errNotModified。
+func (s *Scraper) fetch(ctx context.Context, target string, conditional http.Header) (*fetchedPage, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, fmt.Errorf("访问URL失败: %w", err)
+	}
+	req.Header.Set("User-Agent", s.userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml;q=0.9,*/*;q=0.8")
+	for key, values := range conditional {
+		req.Header[key] = values
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("抓取错误 %s: %w", target, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && conditional != nil {
+		return nil, errNotModified
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("抓取错误 %s: %s", resp.Request.URL, http.StatusText(resp.StatusCode))
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	limited, err := s.limitBody(resp)
+	if err != nil {
+		return nil, err
+	}
+	reader := bufio.NewReader(limited)
+	// 服务端常把文档标为 application/octet-stream，因此同时按文件头与扩展名识别
+	head, _ := reader.Peek(512)
+	format := detectDocument(contentType, resp.Request.URL.Path, head)
+	if format == nil && !isHTMLContentType(contentType) || !s.typeAllowed(contentType, format) {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedType, contentType)
+	}
+	if format != nil {
+		page, err := fetchDocument(reader, format, contentType, resp.Request.URL)
+		if err != nil {
+			return nil, err
+		}
+		setValidators(page, resp)
+		return page, nil
+	}
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("读取网页失败: %w", err)
+	}
+	data, encoding, source := decodeBody(data, contentType, htmlCharset)
+	logger.GetLogger().Infow("识别网页编码", "url", resp.Request.URL.String(), "encoding", encoding, "source", source)
+
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("解析HTML失败: %w", err)
+	}
+
+	page := &fetchedPage{
+		doc:      doc,
+		finalURL: resp.Request.URL,
+		base:     resp.Request.URL,
+		encoding: encoding,
+	}
+	setValidators(page, resp)
+	if href, ok := doc.Find("base[href]").First().Attr("href"); ok {
+		if ref, err := neturl.Parse(href); err == nil {
+			page.base = page.finalURL.ResolveReference(ref)
+		}
+	}
+	return page, nil
+}
+
+// setValidators 记录响应的缓存验证器与 Cache-Control: no-store
+func setValidators(page *fetchedPage, resp *http.Response) {
+	page.etag = resp.Header.Get("ETag")
+	page.lastModified = resp.Header.Get("Last-Modified")
+	page.noStore = strings.Contains(strings.ToLower(resp.Header.Get("Cache-Control")), "no-store")
+}
+
+// isHTMLContentType 判断响应是否为HTML；未声明类型时按HTML处理
+func isHTMLContentType(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.Contains(strings.ToLower(contentType), "html")
+	}
+	return mediaType == "text/html" || mediaType == "application/xhtml+xml"
+}
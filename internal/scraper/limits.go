@@ -0,0 +1,118 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// 未配置或配置无效时使用的抓取限制
+const (
+	defaultMaxBodyBytes = 50 << 20
+	defaultMaxRedirects = 10
+	defaultTimeBudget   = 120 * time.Second
+)
+
+var (
+	// ErrTooLarge 表示响应超过配置的大小上限
+	ErrTooLarge = errors.New("响应超过大小上限")
+	// ErrTooManyRedirects 表示重定向次数超过上限，通常是重定向循环
+	ErrTooManyRedirects = errors.New("重定向次数过多")
+	// ErrUnsupportedType 表示响应的内容类型无法解析或不在允许的类型中
+	ErrUnsupportedType = errors.New("不支持的内容类型")
+	// ErrTimeBudget 表示一次请求中的抓取超过总时间上限
+	ErrTimeBudget = errors.New("超过抓取时间上限")
+)
+
+// checkRedirect 限制重定向次数
+func (s *Scraper) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) > s.maxRedirects {
+		return fmt.Errorf("%w（上限 %d 次）", ErrTooManyRedirects, s.maxRedirects)
+	}
+	return nil
+}
+
+// limitBody 限制响应正文的读取量。声明的长度已超出上限时直接返回错误，不下载正文。
+func (s *Scraper) limitBody(resp *http.Response) (io.Reader, error) {
+	if resp.ContentLength > s.maxBodyBytes {
+		return nil, s.tooLarge()
+	}
+	return &limitedBody{r: io.LimitReader(resp.Body, s.maxBodyBytes+1), limit: s.maxBodyBytes, err: s.tooLarge()}, nil
+}
+
+// tooLarge 返回带大小上限的 ErrTooLarge
+func (s *Scraper) tooLarge() error {
+	return fmt.Errorf("%w（%s）", ErrTooLarge, formatBytes(s.maxBodyBytes))
+}
+
+// limitedBody 读取量超过 limit 时返回 err
+type limitedBody struct {
+	r     io.Reader
+	read  int64
+	limit int64
+	err   error
+}
+
+func (l *limitedBody) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.read += int64(n)
+	if l.read > l.limit {
+		return n - int(l.read-l.limit), l.err
+	}
+	return n, err
+}
+
+// typeAllowed 判断响应是否属于允许的媒体类型。除响应声明的类型外，
+// 也按识别出的文档格式判断，这样以 application/octet-stream 返回的 PDF 可以按 application/pdf 放行。
+func (s *Scraper) typeAllowed(contentType string, format *documentFormat) bool {
+	if len(s.allowedTypes) == 0 {
+		return true
+	}
+	var candidates []string
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		candidates = append(candidates, strings.ToLower(mediaType))
+	}
+	if format != nil {
+		candidates = append(candidates, format.mediaTypes...)
+	} else {
+		candidates = append(candidates, "text/html")
+	}
+
+	for _, allowed := range s.allowedTypes {
+		for _, c := range candidates {
+			if allowed == c || strings.HasSuffix(allowed, "/*") && strings.HasPrefix(c, strings.TrimSuffix(allowed, "*")) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// withBudget 为一次请求中的所有抓取设置总时间上限
+func (s *Scraper) withBudget(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeoutCause(ctx, s.timeBudget, ErrTimeBudget)
+}
+
+// budgetError 时间上限耗尽时把抓取错误替换为 ErrTimeBudget，其余错误原样返回
+func (s *Scraper) budgetError(ctx context.Context, err error) error {
+	if err != nil && errors.Is(context.Cause(ctx), ErrTimeBudget) && !errors.Is(err, ErrTimeBudget) {
+		return fmt.Errorf("%w（%s）", ErrTimeBudget, s.timeBudget)
+	}
+	return err
+}
+
+// formatBytes 以 MB 或 KB 显示字节数
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<20 && n%(1<<20) == 0:
+		return fmt.Sprintf("%d MB", n>>20)
+	case n >= 1<<10:
+		return fmt.Sprintf("%d KB", n>>10)
+	}
+	return fmt.Sprintf("%d 字节", n)
+}
@@ -0,0 +1,73 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eust-w/urlreader/config"
+)
+
+func TestFetchLimits(t *testing.T) {
+	page := "<html><head><title>Page</title></head><body><p>" + strings.Repeat("Some content. ", 200) + "</p></body></html>"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/page":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, page)
+		case "/chunked":
+			// 不声明长度，只能在读取时发现超限
+			w.Header().Set("Content-Type", "text/html")
+			w.(http.Flusher).Flush()
+			fmt.Fprint(w, page)
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		case "/data.pdf":
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Write(buildPDF("Report", "BT /F1 12 Tf 72 720 Td (Hello PDF) Tj ET"))
+		case "/slow":
+			time.Sleep(1500 * time.Millisecond)
+			fmt.Fprint(w, page)
+		}
+	}))
+	defer srv.Close()
+
+	small := NewScraper(&config.Config{ScraperMaxBodyBytes: 1024})
+	for _, path := range []string{"/page", "/chunked"} {
+		if _, err := small.ScrapeURL(srv.URL + path); !errors.Is(err, ErrTooLarge) || ErrorCode(err) != "response_too_large" {
+			t.Errorf("%s: err = %v, want ErrTooLarge", path, err)
+		}
+	}
+
+	s := NewScraper(&config.Config{ScraperMaxRedirects: 3})
+	if _, err := s.ScrapeURL(srv.URL + "/loop"); !errors.Is(err, ErrTooManyRedirects) {
+		t.Errorf("redirect loop err = %v, want ErrTooManyRedirects", err)
+	}
+
+	htmlOnly := NewScraper(&config.Config{ScraperAllowedTypes: []string{"text/*"}})
+	if _, err := htmlOnly.ScrapeURL(srv.URL + "/page"); err != nil {
+		t.Errorf("html with text/* err = %v", err)
+	}
+	if _, err := htmlOnly.ScrapeURL(srv.URL + "/data.pdf"); !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("pdf with text/* err = %v, want ErrUnsupportedType", err)
+	}
+	// 按文件头识别出的格式也参与判断
+	pdfOnly := NewScraper(&config.Config{ScraperAllowedTypes: []string{"application/pdf"}})
+	if _, err := pdfOnly.ScrapeURL(srv.URL + "/data.pdf"); err != nil {
+		t.Errorf("pdf with application/pdf err = %v", err)
+	}
+
+	budget := NewScraper(&config.Config{ScraperTimeBudget: 1})
+	if _, err := budget.ScrapeURL(srv.URL + "/slow"); !errors.Is(err, ErrTimeBudget) {
+		t.Errorf("slow scrape err = %v, want ErrTimeBudget", err)
+	}
+	pages, err := budget.Crawl(context.Background(), srv.URL+"/slow", CrawlOptions{})
+	if err != nil || len(pages) != 1 || pages[0].Code != "time_budget_exceeded" || !errors.Is(pages[0].Err(), ErrTimeBudget) {
+		t.Errorf("slow crawl: pages = %+v, err = %v", pages, err)
+	}
+}
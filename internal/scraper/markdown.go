@@ -0,0 +1,424 @@
+package scraper
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+var (
+	spaceBeforeNewline = regexp.MustCompile(` +\n`)
+	extraBlankLines    = regexp.MustCompile(`\n{3,}`)
+	codeLanguageClass  = regexp.MustCompile(`(?:^|\s)(?:language|lang)-([\w+#-]+)`)
+)
+
+// markdownRenderer 按文档顺序把HTML节点转换为Markdown
+type markdownRenderer struct {
+	base *url.URL
+}
+
+// newMarkdownRenderer 创建Markdown渲染器，base用于把相对链接转换为绝对链接
+func newMarkdownRenderer(base *url.URL) *markdownRenderer {
+	return &markdownRenderer{base: base}
+}
+
+// render 渲染一组节点，块之间以空行分隔
+func (r *markdownRenderer) render(nodes []*html.Node) string {
+	var blocks []string
+	for _, n := range nodes {
+		blocks = append(blocks, r.blocks(n)...)
+	}
+	return strings.TrimSpace(strings.Join(blocks, "\n\n"))
+}
+
+// blocks 返回节点渲染出的Markdown块
+func (r *markdownRenderer) blocks(n *html.Node) []string {
+	switch n.Type {
+	case html.TextNode:
+		if text := collapseWhitespace(n.Data); text != "" {
+			return []string{text}
+		}
+		return nil
+	case html.ElementNode, html.DocumentNode:
+	default:
+		return nil
+	}
+
+	switch n.Data {
+	case "script", "style", "noscript", "template", "head":
+		return nil
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		text := cleanInline(r.inlineChildren(n))
+		if text == "" {
+			return nil
+		}
+		level := int(n.Data[1] - '0')
+		return []string{strings.Repeat("#", level) + " " + strings.ReplaceAll(text, "\n", " ")}
+	case "p", "figcaption", "dt", "dd", "address", "summary":
+		if text := cleanInline(r.inlineChildren(n)); text != "" {
+			return []string{text}
+		}
+		return nil
+	case "pre":
+		return []string{r.codeBlock(n)}
+	case "ul", "ol":
+		if list := r.list(n); list != "" {
+			return []string{list}
+		}
+		return nil
+	case "blockquote":
+		inner := strings.Join(r.childBlocks(n), "\n\n")
+		if inner == "" {
+			return nil
+		}
+		lines := strings.Split(inner, "\n")
+		for i, line := range lines {
+			lines[i] = strings.TrimRight("> "+line, " ")
+		}
+		return []string{strings.Join(lines, "\n")}
+	case "table":
+		if table := r.table(n); table != "" {
+			return []string{table}
+		}
+		return nil
+	case "hr":
+		return []string{"---"}
+	case "img":
+		if img := r.image(n); img != "" {
+			return []string{img}
+		}
+		return nil
+	}
+
+	return r.childBlocks(n)
+}
+
+// childBlocks 渲染容器的子节点：连续的行内内容合并为一个段落，块级子元素单独成块
+func (r *markdownRenderer) childBlocks(n *html.Node) []string {
+	var blocks []string
+	var inline strings.Builder
+	flush := func() {
+		if text := cleanInline(inline.String()); text != "" {
+			blocks = append(blocks, text)
+		}
+		inline.Reset()
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && blockTags[c.Data] {
+			flush()
+			blocks = append(blocks, r.blocks(c)...)
+			continue
+		}
+		inline.WriteString(r.inline(c))
+	}
+	flush()
+	return blocks
+}
+
+// inlineChildren 渲染节点的全部子节点为行内Markdown
+func (r *markdownRenderer) inlineChildren(n *html.Node) string {
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.WriteString(r.inline(c))
+	}
+	return b.String()
+}
+
+// inline 渲染单个行内节点
+func (r *markdownRenderer) inline(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return whitespaceRun.ReplaceAllString(n.Data, " ")
+	}
+	if n.Type != html.ElementNode {
+		return ""
+	}
+
+	switch n.Data {
+	case "script", "style", "noscript", "template":
+		return ""
+	case "br":
+		return "\n"
+	case "img":
+		return r.image(n)
+	case "a":
+		inner := r.inlineChildren(n)
+		text := strings.TrimSpace(inner)
+		href := r.resolve(attr(n, "href"))
+		if href == "" || strings.HasPrefix(href, "javascript:") {
+			return text
+		}
+		if text == "" {
+			return ""
+		}
+		return wrapInline("[", text, "]("+href+")", inner)
+	case "strong", "b":
+		inner := r.inlineChildren(n)
+		return wrapInline("**", strings.TrimSpace(inner), "**", inner)
+	case "em", "i":
+		inner := r.inlineChildren(n)
+		return wrapInline("*", strings.TrimSpace(inner), "*", inner)
+	case "del", "s", "strike":
+		inner := r.inlineChildren(n)
+		return wrapInline("~~", strings.TrimSpace(inner), "~~", inner)
+	case "code", "kbd", "samp":
+		text := strings.TrimSpace(rawText(n))
+		if text == "" {
+			return ""
+		}
+		fence := "`"
+		if strings.Contains(text, "`") {
+			fence = "``"
+		}
+		return fence + text + fence
+	}
+
+	text := r.inlineChildren(n)
+	if blockTags[n.Data] {
+		// 行内上下文中出现的块级元素，前后补空格避免文字粘连
+		return " " + text + " "
+	}
+	return text
+}
+
+// wrapInline 用标记包裹行内文本，并保留原文两侧的空白
+func wrapInline(open, text, close, original string) string {
+	if text == "" {
+		return original
+	}
+	var prefix, suffix string
+	if strings.HasPrefix(original, " ") {
+		prefix = " "
+	}
+	if strings.HasSuffix(original, " ") {
+		suffix = " "
+	}
+	return prefix + open + text + close + suffix
+}
+
+// image 渲染图片为 ![alt](src)
+func (r *markdownRenderer) image(n *html.Node) string {
+	src := attr(n, "src")
+	if src == "" || strings.HasPrefix(src, "data:") {
+		src = attr(n, "data-src")
+	}
+	src = r.resolve(src)
+	if src == "" {
+		return ""
+	}
+	alt := strings.ReplaceAll(collapseWhitespace(attr(n, "alt")), "]", `\]`)
+	return fmt.Sprintf("![%s](%s)", alt, src)
+}
+
+// codeBlock 把 pre/code 渲染为围栏代码块
+func (r *markdownRenderer) codeBlock(n *html.Node) string {
+	lang := ""
+	if m := codeLanguageClass.FindStringSubmatch(attr(n, "class")); m != nil {
+		lang = m[1]
+	}
+	for c := n.FirstChild; c != nil && lang == ""; c = c.NextSibling {
+		if c.Type == html.ElementNode && c.Data == "code" {
+			if m := codeLanguageClass.FindStringSubmatch(attr(c, "class")); m != nil {
+				lang = m[1]
+			}
+		}
+	}
+
+	code := strings.Trim(preformattedText(n), "\n")
+	fence := "```"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+	return fence + lang + "\n" + code + "\n" + fence
+}
+
+// list 渲染有序或无序列表，嵌套列表按标记宽度缩进
+func (r *markdownRenderer) list(n *html.Node) string {
+	var items []string
+	index := 1
+	if start := attr(n, "start"); start != "" {
+		fmt.Sscanf(start, "%d", &index)
+	}
+
+	for li := n.FirstChild; li != nil; li = li.NextSibling {
+		if li.Type != html.ElementNode || li.Data != "li" {
+			continue
+		}
+
+		marker := "- "
+		if n.Data == "ol" {
+			marker = fmt.Sprintf("%d. ", index)
+			index++
+		}
+
+		content := strings.Join(r.childBlocks(li), "\n\n")
+		if content == "" {
+			continue
+		}
+		indent := strings.Repeat(" ", len(marker))
+		lines := strings.Split(content, "\n")
+		for i := range lines {
+			if i == 0 {
+				lines[i] = marker + lines[i]
+			} else if lines[i] != "" {
+				lines[i] = indent + lines[i]
+			}
+		}
+		items = append(items, strings.Join(lines, "\n"))
+	}
+	return strings.Join(items, "\n")
+}
+
+// table 渲染GFM表格，第一行作为表头
+func (r *markdownRenderer) table(n *html.Node) string {
+	var rows [][]string
+	var walk func(*html.Node)
+	walk = func(node *html.Node) {
+		for c := node.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			switch c.Data {
+			case "table":
+				// 嵌套表格按单元格文本处理
+				continue
+			case "tr":
+				var cells []string
+				for cell := c.FirstChild; cell != nil; cell = cell.NextSibling {
+					if cell.Type == html.ElementNode && (cell.Data == "td" || cell.Data == "th") {
+						text := cleanInline(r.inlineChildren(cell))
+						text = strings.ReplaceAll(text, "\n", " ")
+						cells = append(cells, strings.ReplaceAll(text, "|", `\|`))
+					}
+				}
+				if len(cells) > 0 {
+					rows = append(rows, cells)
+				}
+			default:
+				walk(c)
+			}
+		}
+	}
+	walk(n)
+	if len(rows) == 0 {
+		return ""
+	}
+
+	columns := 0
+	for _, row := range rows {
+		if len(row) > columns {
+			columns = len(row)
+		}
+	}
+
+	var b strings.Builder
+	writeRow := func(row []string) {
+		b.WriteString("|")
+		for i := 0; i < columns; i++ {
+			cell := ""
+			if i < len(row) {
+				cell = row[i]
+			}
+			b.WriteString(" " + cell + " |")
+		}
+		b.WriteString("\n")
+	}
+
+	writeRow(rows[0])
+	b.WriteString("|")
+	for i := 0; i < columns; i++ {
+		b.WriteString(" --- |")
+	}
+	b.WriteString("\n")
+	for _, row := range rows[1:] {
+		writeRow(row)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// resolve 把相对链接转换为绝对链接
+func (r *markdownRenderer) resolve(ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" || r.base == nil {
+		return ref
+	}
+	u, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return r.base.ResolveReference(u).String()
+}
+
+// cleanInline 规整行内Markdown：合并空格、去除行尾空格和多余空行
+func cleanInline(s string) string {
+	s = strings.ReplaceAll(s, "\u00a0", " ")
+	for strings.Contains(s, "  ") {
+		s = strings.ReplaceAll(s, "  ", " ")
+	}
+	s = spaceBeforeNewline.ReplaceAllString(s, "\n")
+	s = strings.ReplaceAll(s, "\n ", "\n")
+	s = extraBlankLines.ReplaceAllString(s, "\n\n")
+	return strings.TrimSpace(s)
+}
+
+// preformattedText 返回 pre 内的原始文本，保留空白与换行
+func preformattedText(n *html.Node) string {
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			b.WriteString(n.Data)
+		case html.ElementNode:
+			if n.Data == "br" {
+				b.WriteString("\n")
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return b.String()
+}
+
+// attr 返回节点的属性值
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// renderHTML 把节点序列化为HTML，链接与图片地址转换为绝对地址
+func renderHTML(nodes []*html.Node, base *url.URL) string {
+	r := newMarkdownRenderer(base)
+	var buf bytes.Buffer
+	for _, n := range nodes {
+		absolutizeLinks(n, r)
+		if err := html.Render(&buf, n); err != nil {
+			continue
+		}
+		buf.WriteString("\n")
+	}
+	return strings.TrimSpace(buf.String())
+}
+
+// absolutizeLinks 把节点树中的 href/src 改写为绝对地址
+func absolutizeLinks(n *html.Node, r *markdownRenderer) {
+	if n.Type == html.ElementNode {
+		for i, a := range n.Attr {
+			if a.Key == "href" || a.Key == "src" {
+				n.Attr[i].Val = r.resolve(a.Val)
+			}
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		absolutizeLinks(c, r)
+	}
+}
@@ -0,0 +1,79 @@
+package scraper
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// renderMarkdown 把HTML片段的 body 渲染为Markdown
+func renderMarkdown(t *testing.T, fragment string) string {
+	t.Helper()
+	doc, err := html.Parse(strings.NewReader("<html><body>" + fragment + "</body></html>"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body *html.Node
+	var find func(*html.Node)
+	find = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "body" {
+			body = n
+		}
+		for c := n.FirstChild; c != nil && body == nil; c = c.NextSibling {
+			find(c)
+		}
+	}
+	find(doc)
+	base, _ := url.Parse("https://example.com/docs/page")
+	return newMarkdownRenderer(base).render([]*html.Node{body})
+}
+
+func TestMarkdownDeepNesting(t *testing.T) {
+	const depth = 200
+	var b strings.Builder
+	b.WriteString("<p>")
+	for i := 0; i < depth; i++ {
+		b.WriteString([]string{"<em>", "<strong>", "<a href='/x'>", "<del>"}[i%4])
+	}
+	b.WriteString("deep")
+	for i := depth - 1; i >= 0; i-- {
+		b.WriteString([]string{"</em>", "</strong>", "</a>", "</del>"}[i%4])
+	}
+	b.WriteString("</p>")
+
+	start := time.Now()
+	md := renderMarkdown(t, b.String())
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("rendering %d nested inline elements took %v", depth, elapsed)
+	}
+	if !strings.Contains(md, "deep") {
+		t.Errorf("markdown = %.200q", md)
+	}
+}
+
+func TestMarkdownRenderer(t *testing.T) {
+	tests := []struct {
+		name, html, want string
+	}{
+		{"headings", "<h1>Title</h1><h3>Sub <em>section</em></h3><p>Text</p>", "# Title\n\n### Sub *section*\n\nText"},
+		{"nested list", "<ul><li>One<ul><li>Nested</li></ul></li><li>Two</li></ul><ol start='3'><li>Three</li><li>Four</li></ol>",
+			"- One\n\n  - Nested\n- Two\n\n3. Three\n4. Four"},
+		{"links", `<p>See <a href="../guide?x=1">the guide</a>, <a href="https://other.example/">other</a> and <a href="javascript:void(0)">js</a>.</p>`,
+			"See [the guide](https://example.com/guide?x=1), [other](https://other.example/) and js."},
+		{"code block", "<pre><code class=\"language-go\">func main() {\n\tfmt.Println(\"hi\")\n}</code></pre><p>Use <code>go run</code>.</p>",
+			"```go\nfunc main() {\n\tfmt.Println(\"hi\")\n}\n```\n\nUse `go run`."},
+		{"table", "<table><tr><th>Name</th><th>Size</th></tr><tr><td>a|b</td><td>1</td></tr></table>",
+			"| Name | Size |\n| --- | --- |\n| a\\|b | 1 |"},
+		{"blockquote", "<blockquote><p>Quoted</p><p>Second <strong>bold</strong></p></blockquote>", "> Quoted\n>\n> Second **bold**"},
+		{"image", `<p><img src="/img/logo.png" alt="Logo"> <img src="data:image/png;base64,xx" data-src="lazy.png" alt="Lazy"></p>`,
+			"![Logo](https://example.com/img/logo.png) ![Lazy](https://example.com/docs/lazy.png)"},
+	}
+	for _, tt := range tests {
+		if got := renderMarkdown(t, tt.html); got != tt.want {
+			t.Errorf("%s:\ngot  %q\nwant %q", tt.name, got, tt.want)
+		}
+	}
+}
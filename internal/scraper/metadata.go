@@ -0,0 +1,361 @@
+package scraper
+
+import (
+	"encoding/json"
+	"fmt"
+	neturl "net/url"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/eust-w/urlreader/internal/models"
+)
+
+// extractMetadata 从 meta 标签、OpenGraph/Twitter 卡片与 JSON-LD 中提取页面元数据，没有任何元数据时返回 nil。
+// 作者与日期优先使用 JSON-LD 中的文章信息，其余字段优先使用 meta 标签。
+func extractMetadata(doc *goquery.Document, base *neturl.URL) *models.Metadata {
+	meta := make(map[string]string)
+	var tags []string
+	doc.Find("meta[content]").Each(func(_ int, s *goquery.Selection) {
+		key := ""
+		for _, attr := range []string{"property", "name", "itemprop", "http-equiv"} {
+			if v, ok := s.Attr(attr); ok && strings.TrimSpace(v) != "" {
+				key = strings.ToLower(strings.TrimSpace(v))
+				break
+			}
+		}
+		value := collapseWhitespace(s.AttrOr("content", ""))
+		if key == "" || value == "" {
+			return
+		}
+		if key == "article:tag" {
+			tags = append(tags, value)
+		}
+		// 同名标签只取第一个
+		if _, ok := meta[key]; !ok {
+			meta[key] = value
+		}
+	})
+
+	md := &models.Metadata{JSONLD: extractJSONLD(doc)}
+	for key, value := range meta {
+		switch {
+		case strings.HasPrefix(key, "og:") || strings.HasPrefix(key, "article:"):
+			if md.OpenGraph == nil {
+				md.OpenGraph = make(map[string]string)
+			}
+			md.OpenGraph[key] = value
+		case strings.HasPrefix(key, "twitter:"):
+			if md.Twitter == nil {
+				md.Twitter = make(map[string]string)
+			}
+			md.Twitter[key] = value
+		}
+	}
+
+	var article models.ArticleData
+	if md.JSONLD != nil && len(md.JSONLD.Articles) > 0 {
+		article = md.JSONLD.Articles[0]
+	}
+	md.Description = firstNonEmpty(meta["description"], meta["og:description"], meta["twitter:description"], article.Description)
+	md.Author = firstNonEmpty(article.Author, meta["author"], meta["article:author"], meta["twitter:creator"])
+	md.Published = normalizeDate(firstNonEmpty(article.DatePublished, meta["article:published_time"],
+		meta["datepublished"], meta["pubdate"], meta["publishdate"], meta["date"], meta["dc.date"], meta["dcterms.created"]))
+	md.Modified = normalizeDate(firstNonEmpty(article.DateModified, meta["article:modified_time"],
+		meta["og:updated_time"], meta["datemodified"], meta["last-modified"], meta["dcterms.modified"]))
+	md.SiteName = firstNonEmpty(meta["og:site_name"], article.Publisher, meta["application-name"])
+	md.Language = firstNonEmpty(doc.Find("html").AttrOr("lang", ""), meta["content-language"], meta["og:locale"])
+	md.Image = resolveURL(base, firstNonEmpty(meta["og:image"], meta["og:image:url"], meta["twitter:image"], meta["twitter:image:src"]))
+
+	if href, ok := doc.Find("link[rel~=canonical][href]").First().Attr("href"); ok {
+		md.CanonicalURL = resolveURL(base, href)
+	}
+	if md.CanonicalURL == "" {
+		md.CanonicalURL = resolveURL(base, meta["og:url"])
+	}
+
+	if keywords := meta["keywords"]; keywords != "" {
+		for _, k := range strings.FieldsFunc(keywords, func(r rune) bool { return r == ',' || r == '，' || r == ';' }) {
+			if k = strings.TrimSpace(k); k != "" {
+				md.Keywords = append(md.Keywords, k)
+			}
+		}
+	} else {
+		md.Keywords = tags
+	}
+
+	if md.Description == "" && md.Author == "" && md.Published == "" && md.Modified == "" && md.SiteName == "" &&
+		md.Language == "" && md.CanonicalURL == "" && md.Image == "" && len(md.Keywords) == 0 &&
+		md.OpenGraph == nil && md.Twitter == nil && md.JSONLD == nil {
+		return nil
+	}
+	return md
+}
+
+// extractJSONLD 解析页面中所有 JSON-LD 脚本，收集文章、商品、菜谱与常见问题，都没有时返回 nil。
+// 无法解析的脚本被忽略。
+func extractJSONLD(doc *goquery.Document) *models.StructuredData {
+	data := &models.StructuredData{}
+	doc.Find(`script[type="application/ld+json"]`).Each(func(_ int, s *goquery.Selection) {
+		text := strings.TrimSpace(s.Text())
+		text = strings.TrimSuffix(strings.TrimPrefix(text, "<![CDATA["), "]]>")
+		text = strings.TrimSuffix(strings.TrimSpace(text), ";")
+		var value any
+		if err := json.Unmarshal([]byte(text), &value); err != nil {
+			return
+		}
+		for _, node := range ldNodes(value) {
+			for _, t := range ldTypes(node) {
+				switch {
+				case strings.HasSuffix(t, "Article") || strings.HasSuffix(t, "Posting"):
+					data.Articles = append(data.Articles, ldArticle(t, node))
+				case t == "Product":
+					data.Products = append(data.Products, ldProduct(node))
+				case t == "Recipe":
+					data.Recipes = append(data.Recipes, ldRecipe(node))
+				case t == "FAQPage":
+					data.FAQ = append(data.FAQ, ldFAQ(node)...)
+				default:
+					continue
+				}
+				break
+			}
+		}
+	})
+	if len(data.Articles) == 0 && len(data.Products) == 0 && len(data.Recipes) == 0 && len(data.FAQ) == 0 {
+		return nil
+	}
+	return data
+}
+
+// ldNodes 展开顶层数组与 @graph，返回其中的所有对象
+func ldNodes(value any) []map[string]any {
+	switch v := value.(type) {
+	case []any:
+		var nodes []map[string]any
+		for _, item := range v {
+			nodes = append(nodes, ldNodes(item)...)
+		}
+		return nodes
+	case map[string]any:
+		nodes := []map[string]any{v}
+		if graph, ok := v["@graph"]; ok {
+			nodes = append(nodes, ldNodes(graph)...)
+		}
+		return nodes
+	}
+	return nil
+}
+
+// ldTypes 返回对象的 @type，去掉 schema.org 前缀
+func ldTypes(node map[string]any) []string {
+	var types []string
+	switch v := node["@type"].(type) {
+	case string:
+		types = []string{v}
+	case []any:
+		for _, t := range v {
+			if s, ok := t.(string); ok {
+				types = append(types, s)
+			}
+		}
+	}
+	for i, t := range types {
+		types[i] = schemaName(t)
+	}
+	return types
+}
+
+// schemaName 去掉 schema.org 的URL前缀，如 https://schema.org/InStock 返回 InStock
+func schemaName(s string) string {
+	s = strings.TrimSpace(s)
+	for _, prefix := range []string{"https://schema.org/", "http://schema.org/", "schema:"} {
+		s = strings.TrimPrefix(s, prefix)
+	}
+	return s
+}
+
+// ldText 把属性值转换为文本：对象取 name、text、@value 或 url，数组的各项以逗号连接
+func ldText(value any) string {
+	switch v := value.(type) {
+	case string:
+		return collapseWhitespace(v)
+	case float64:
+		return fmt.Sprint(v)
+	case map[string]any:
+		for _, key := range []string{"name", "text", "@value", "url"} {
+			if s := ldText(v[key]); s != "" {
+				return s
+			}
+		}
+	case []any:
+		var parts []string
+		for _, item := range v {
+			if s := ldText(item); s != "" {
+				parts = append(parts, s)
+			}
+		}
+		return strings.Join(parts, ", ")
+	}
+	return ""
+}
+
+// ldFirst 返回第一个非空属性的文本
+func ldFirst(node map[string]any, keys ...string) string {
+	for _, key := range keys {
+		if s := ldText(node[key]); s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// ldObject 返回属性中的对象，属性为数组时取第一个对象
+func ldObject(value any) map[string]any {
+	switch v := value.(type) {
+	case map[string]any:
+		return v
+	case []any:
+		for _, item := range v {
+			if m, ok := item.(map[string]any); ok {
+				return m
+			}
+		}
+	}
+	return nil
+}
+
+func ldArticle(t string, node map[string]any) models.ArticleData {
+	return models.ArticleData{
+		Type:          t,
+		Headline:      ldFirst(node, "headline", "name"),
+		Description:   ldFirst(node, "description"),
+		Author:        ldFirst(node, "author", "creator"),
+		Publisher:     ldFirst(node, "publisher"),
+		DatePublished: normalizeDate(ldFirst(node, "datePublished", "dateCreated")),
+		DateModified:  normalizeDate(ldFirst(node, "dateModified")),
+		Section:       ldFirst(node, "articleSection"),
+	}
+}
+
+func ldProduct(node map[string]any) models.ProductData {
+	product := models.ProductData{
+		Name:        ldFirst(node, "name"),
+		Description: ldFirst(node, "description"),
+		Brand:       ldFirst(node, "brand"),
+		SKU:         ldFirst(node, "sku", "mpn", "gtin13"),
+	}
+	if offer := ldObject(node["offers"]); offer != nil {
+		product.Price = ldFirst(offer, "price", "lowPrice")
+		product.Currency = ldFirst(offer, "priceCurrency")
+		product.Availability = schemaName(ldFirst(offer, "availability"))
+	}
+	if rating := ldObject(node["aggregateRating"]); rating != nil {
+		product.Rating = ldFirst(rating, "ratingValue")
+		product.ReviewCount = ldFirst(rating, "reviewCount", "ratingCount")
+	}
+	return product
+}
+
+func ldRecipe(node map[string]any) models.RecipeData {
+	recipe := models.RecipeData{
+		Name:         ldFirst(node, "name"),
+		Description:  ldFirst(node, "description"),
+		Author:       ldFirst(node, "author"),
+		PrepTime:     ldFirst(node, "prepTime"),
+		CookTime:     ldFirst(node, "cookTime"),
+		TotalTime:    ldFirst(node, "totalTime"),
+		Yield:        ldFirst(node, "recipeYield"),
+		Instructions: ldSteps(node["recipeInstructions"]),
+	}
+	ingredients, _ := node["recipeIngredient"].([]any)
+	if ingredients == nil {
+		ingredients, _ = node["ingredients"].([]any)
+	}
+	for _, item := range ingredients {
+		if s := ldText(item); s != "" {
+			recipe.Ingredients = append(recipe.Ingredients, s)
+		}
+	}
+	return recipe
+}
+
+// ldSteps 展开菜谱步骤：字符串、HowToStep 数组或包含 itemListElement 的 HowToSection
+func ldSteps(value any) []string {
+	switch v := value.(type) {
+	case string:
+		if s := htmlToText(v); s != "" {
+			return []string{s}
+		}
+	case []any:
+		var steps []string
+		for _, item := range v {
+			steps = append(steps, ldSteps(item)...)
+		}
+		return steps
+	case map[string]any:
+		if items, ok := v["itemListElement"]; ok {
+			return ldSteps(items)
+		}
+		if text, ok := v["text"].(string); ok {
+			return ldSteps(text)
+		}
+		return ldSteps(v["name"])
+	}
+	return nil
+}
+
+func ldFAQ(node map[string]any) []models.FAQItem {
+	var items []models.FAQItem
+	entities, ok := node["mainEntity"].([]any)
+	if !ok {
+		entities = []any{node["mainEntity"]}
+	}
+	for _, entity := range entities {
+		question, ok := entity.(map[string]any)
+		if !ok {
+			continue
+		}
+		item := models.FAQItem{Question: ldFirst(question, "name", "text")}
+		if answer := ldObject(question["acceptedAnswer"]); answer != nil {
+			if text, ok := answer["text"].(string); ok {
+				item.Answer = htmlToText(text)
+			}
+		}
+		if item.Question != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// normalizeDate 能识别的日期转换为 RFC 3339，否则返回原文
+func normalizeDate(value string) string {
+	if t, ok := parseDate(value); ok {
+		return t.Format(time.RFC3339)
+	}
+	return strings.TrimSpace(value)
+}
+
+// resolveURL 把相对链接解析为绝对URL，无法解析时返回原文
+func resolveURL(base *neturl.URL, href string) string {
+	href = strings.TrimSpace(href)
+	if href == "" {
+		return ""
+	}
+	ref, err := neturl.Parse(href)
+	if err != nil {
+		return href
+	}
+	return base.ResolveReference(ref).String()
+}
+
+// firstNonEmpty 返回第一个非空字符串
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
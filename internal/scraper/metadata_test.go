@@ -0,0 +1,88 @@
+package scraper
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/eust-w/urlreader/config"
+	"github.com/eust-w/urlreader/internal/models"
+)
+
+func TestScrapeMetadata(t *testing.T) {
+	const page = `<html lang="en-GB"><head>
+<title>Launch day</title>
+<meta name="description" content="We shipped the thing.">
+<meta property="og:site_name" content="Eng Blog">
+<meta property="og:image" content="/img/cover.png">
+<meta property="og:title" content="Launch day (OG)">
+<meta property="article:published_time" content="2026-10-01T08:00:00Z">
+<meta property="article:tag" content="release">
+<meta property="article:tag" content="go">
+<meta name="twitter:card" content="summary_large_image">
+<meta name="twitter:creator" content="@eng">
+<link rel="canonical" href="/posts/launch">
+<script type="application/ld+json">{"@context":"https://schema.org","@graph":[
+ {"@type":"BlogPosting","headline":"Launch day","author":[{"@type":"Person","name":"Ada"},{"@type":"Person","name":"Lin"}],
+  "publisher":{"@type":"Organization","name":"Eng Inc"},"datePublished":"2026-10-01T10:00:00+02:00","dateModified":"2026-10-03"},
+ {"@type":"FAQPage","mainEntity":[{"@type":"Question","name":"Is it free?","acceptedAnswer":{"@type":"Answer","text":"<p>Yes, <b>forever</b>.</p>"}}]}
+]}</script>
+<script type="application/ld+json">{"@type":"Product","name":"Widget","brand":{"@type":"Brand","name":"Acme"},
+ "offers":[{"@type":"Offer","price":"19.99","priceCurrency":"USD","availability":"https://schema.org/InStock"}],
+ "aggregateRating":{"ratingValue":4.5,"reviewCount":120}}</script>
+<script type="application/ld+json">{ not json</script>
+</head><body><article><p>Today we shipped the thing we have been working on for a year.</p></article></body></html>`
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, page)
+	}))
+	defer srv.Close()
+
+	content, err := NewScraper(&config.Config{}).ScrapeURL(srv.URL + "/posts/launch?utm=x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	md := content.Metadata
+	if md == nil {
+		t.Fatal("metadata = nil")
+	}
+
+	checks := map[string][2]string{
+		"description": {md.Description, "We shipped the thing."},
+		"author":      {md.Author, "Ada, Lin"},
+		"published":   {md.Published, "2026-10-01T10:00:00+02:00"},
+		"modified":    {md.Modified, "2026-10-03T00:00:00Z"},
+		"site_name":   {md.SiteName, "Eng Blog"},
+		"language":    {md.Language, "en-GB"},
+		"canonical":   {md.CanonicalURL, srv.URL + "/posts/launch"},
+		"image":       {md.Image, srv.URL + "/img/cover.png"},
+		"og:title":    {md.OpenGraph["og:title"], "Launch day (OG)"},
+		"twitter":     {md.Twitter["twitter:card"], "summary_large_image"},
+	}
+	for name, c := range checks {
+		if c[0] != c[1] {
+			t.Errorf("%s = %q, want %q", name, c[0], c[1])
+		}
+	}
+	if !reflect.DeepEqual(md.Keywords, []string{"release", "go"}) {
+		t.Errorf("keywords = %v", md.Keywords)
+	}
+
+	ld := md.JSONLD
+	if ld == nil || len(ld.Articles) != 1 || len(ld.Products) != 1 || len(ld.FAQ) != 1 {
+		t.Fatalf("json-ld = %+v", ld)
+	}
+	if a := ld.Articles[0]; a.Type != "BlogPosting" || a.Publisher != "Eng Inc" {
+		t.Errorf("article = %+v", a)
+	}
+	want := models.ProductData{Name: "Widget", Brand: "Acme", Price: "19.99", Currency: "USD", Availability: "InStock", Rating: "4.5", ReviewCount: "120"}
+	if ld.Products[0] != want {
+		t.Errorf("product = %+v", ld.Products[0])
+	}
+	if ld.FAQ[0] != (models.FAQItem{Question: "Is it free?", Answer: "Yes, forever."}) {
+		t.Errorf("faq = %+v", ld.FAQ[0])
+	}
+}
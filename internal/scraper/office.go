@@ -0,0 +1,388 @@
+package scraper
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/eust-w/urlreader/internal/models"
+)
+
+// officePartMaxBytes Office 文档中单个 XML 部件解压后的最大字节数
+const officePartMaxBytes = 50 << 20
+
+var (
+	// slidePart 匹配 PPTX 中的幻灯片部件
+	slidePart = regexp.MustCompile(`^ppt/slides/slide(\d+)\.xml$`)
+	// headingStyle 匹配 Word 内置标题样式的名称，如 "heading 1"、"Title"
+	headingStyle = regexp.MustCompile(`(?i)^(?:heading\s*(\d)|title)$`)
+)
+
+// parseOffice 解析 DOCX 或 PPTX 文档，按压缩包内容区分类型而不依赖扩展名
+func parseOffice(data []byte) (*document, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("解析Office文档失败: %w", err)
+	}
+	parts := make(map[string]*zip.File, len(archive.File))
+	for _, f := range archive.File {
+		parts[f.Name] = f
+	}
+
+	var doc *document
+	switch {
+	case parts["word/document.xml"] != nil:
+		doc, err = parseDOCX(parts)
+	case parts["ppt/presentation.xml"] != nil:
+		doc, err = parsePPTX(parts)
+	default:
+		return nil, errors.New("不支持的压缩文件，仅支持 DOCX 与 PPTX")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("解析Office文档失败: %w", err)
+	}
+	title, metadata := officeProperties(parts)
+	if doc.title == "" {
+		doc.title = title
+	}
+	doc.metadata = metadata
+	return doc, nil
+}
+
+// openPart 打开压缩包中的一个部件，读取量不超过 officePartMaxBytes
+func openPart(f *zip.File) (*xml.Decoder, func() error, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, nil, err
+	}
+	return xml.NewDecoder(io.LimitReader(rc, officePartMaxBytes)), rc.Close, nil
+}
+
+// officeProperties 读取 docProps/core.xml 中的标题、作者与创建、修改时间，没有作者与时间时元数据为 nil
+func officeProperties(parts map[string]*zip.File) (string, *models.Metadata) {
+	f := parts["docProps/core.xml"]
+	if f == nil {
+		return "", nil
+	}
+	dec, closer, err := openPart(f)
+	if err != nil {
+		return "", nil
+	}
+	defer closer()
+	var props struct {
+		Title    string `xml:"title"`
+		Creator  string `xml:"creator"`
+		Created  string `xml:"created"`
+		Modified string `xml:"modified"`
+	}
+	if err := dec.Decode(&props); err != nil {
+		return "", nil
+	}
+	return strings.TrimSpace(props.Title), documentMetadata(props.Creator, normalizeDate(props.Created), normalizeDate(props.Modified))
+}
+
+// attrValue 返回元素中指定本地名称的属性值，忽略命名空间
+func attrValue(el xml.StartElement, name string) string {
+	for _, a := range el.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// tableBuilder 收集 Office 表格的行与单元格，嵌套表格的文本并入外层单元格
+type tableBuilder struct {
+	depth int
+	rows  [][]string
+	row   []string
+	cell  []string
+}
+
+// docxHeadingLevels 读取 word/styles.xml，返回样式ID对应的标题级别。
+// 中文版 Word 的样式ID是 "1"、"2" 等，因此按样式名称与大纲级别判断，而不是按ID。
+func docxHeadingLevels(parts map[string]*zip.File) map[string]int {
+	levels := make(map[string]int)
+	f := parts["word/styles.xml"]
+	if f == nil {
+		return levels
+	}
+	dec, closer, err := openPart(f)
+	if err != nil {
+		return levels
+	}
+	defer closer()
+
+	var id string
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return levels
+		}
+		el, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch el.Name.Local {
+		case "style":
+			id = attrValue(el, "styleId")
+		case "name":
+			if m := headingStyle.FindStringSubmatch(attrValue(el, "val")); m != nil && id != "" {
+				levels[id] = 1
+				if m[1] != "" {
+					levels[id], _ = strconv.Atoi(m[1])
+				}
+			}
+		case "outlineLvl":
+			if n, err := strconv.Atoi(attrValue(el, "val")); err == nil && id != "" && levels[id] == 0 && n < 9 {
+				levels[id] = n + 1
+			}
+		}
+	}
+}
+
+// parseDOCX 按文档顺序提取 Word 文档的标题、段落、列表与表格
+func parseDOCX(parts map[string]*zip.File) (*document, error) {
+	levels := docxHeadingLevels(parts)
+	dec, closer, err := openPart(parts["word/document.xml"])
+	if err != nil {
+		return nil, err
+	}
+	defer closer()
+
+	doc := &document{}
+	var table tableBuilder
+	var text strings.Builder
+	var inText, isList bool
+	var style string
+	var outline, listLevel int
+
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch el := tok.(type) {
+		case xml.StartElement:
+			switch el.Name.Local {
+			case "p":
+				text.Reset()
+				style, outline, listLevel, isList = "", 0, 0, false
+			case "pStyle":
+				style = attrValue(el, "val")
+			case "outlineLvl":
+				if n, err := strconv.Atoi(attrValue(el, "val")); err == nil && n < 9 {
+					outline = n + 1
+				}
+			case "numPr":
+				isList = true
+			case "ilvl":
+				listLevel, _ = strconv.Atoi(attrValue(el, "val"))
+			case "t":
+				inText = true
+			case "tab":
+				text.WriteString("\t")
+			case "br", "cr":
+				text.WriteString("\n")
+			case "tbl":
+				table.depth++
+				if table.depth == 1 {
+					table.rows = nil
+				}
+			case "tr":
+				if table.depth == 1 {
+					table.row = nil
+				}
+			case "tc":
+				if table.depth == 1 {
+					table.cell = nil
+				}
+			}
+		case xml.CharData:
+			if inText {
+				text.Write(el)
+			}
+		case xml.EndElement:
+			switch el.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				paragraph := strings.TrimSpace(text.String())
+				level := levels[style]
+				if outline > 0 {
+					level = outline
+				}
+				switch {
+				case paragraph == "":
+				case table.depth > 0:
+					table.cell = append(table.cell, paragraph)
+				case level > 0:
+					doc.heading(level, paragraph)
+				case isList:
+					doc.listItem(listLevel, paragraph)
+				default:
+					doc.paragraph(paragraph)
+				}
+			case "tc":
+				if table.depth == 1 {
+					table.row = append(table.row, strings.Join(table.cell, " "))
+				}
+			case "tr":
+				if table.depth == 1 && len(table.row) > 0 {
+					table.rows = append(table.rows, table.row)
+				}
+			case "tbl":
+				table.depth--
+				if table.depth == 0 {
+					doc.table(table.rows)
+				}
+			}
+		}
+	}
+	return doc, nil
+}
+
+// parsePPTX 按幻灯片顺序提取演示文稿的文本：每张幻灯片以标题占位符作为二级标题，
+// 正文占位符中的段落作为列表项，其余文本框作为段落，表格保留为表格。
+func parsePPTX(parts map[string]*zip.File) (*document, error) {
+	type slide struct {
+		num  int
+		file *zip.File
+	}
+	var slides []slide
+	for name, f := range parts {
+		if m := slidePart.FindStringSubmatch(name); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			slides = append(slides, slide{num: n, file: f})
+		}
+	}
+	sort.Slice(slides, func(i, j int) bool { return slides[i].num < slides[j].num })
+
+	doc := &document{}
+	for i, s := range slides {
+		title, body, err := parseSlide(s.file)
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 张幻灯片: %w", i+1, err)
+		}
+		heading := fmt.Sprintf("第 %d 张幻灯片", i+1)
+		if title != "" {
+			heading += "：" + title
+			if doc.title == "" {
+				doc.title = title
+			}
+		}
+		doc.heading(2, heading)
+		doc.blocks = append(doc.blocks, body.blocks...)
+	}
+	return doc, nil
+}
+
+// parseSlide 解析一张幻灯片，返回标题与正文块
+func parseSlide(f *zip.File) (string, *document, error) {
+	dec, closer, err := openPart(f)
+	if err != nil {
+		return "", nil, err
+	}
+	defer closer()
+
+	body := &document{}
+	var title []string
+	var table tableBuilder
+	var text strings.Builder
+	var inText bool
+	// placeholder 当前形状的占位符类型，非占位符为空；正文占位符没有类型时记为 "body"
+	var placeholder string
+	var level int
+
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", nil, err
+		}
+
+		switch el := tok.(type) {
+		case xml.StartElement:
+			switch el.Name.Local {
+			case "sp":
+				placeholder = ""
+			case "ph":
+				placeholder = attrValue(el, "type")
+				if placeholder == "" || placeholder == "obj" {
+					placeholder = "body"
+				}
+			case "p":
+				text.Reset()
+				level = 0
+			case "pPr":
+				level, _ = strconv.Atoi(attrValue(el, "lvl"))
+			case "t":
+				inText = true
+			case "br":
+				text.WriteString("\n")
+			case "tbl":
+				table.depth++
+				if table.depth == 1 {
+					table.rows = nil
+				}
+			case "tr":
+				if table.depth == 1 {
+					table.row = nil
+				}
+			case "tc":
+				if table.depth == 1 {
+					table.cell = nil
+				}
+			}
+		case xml.CharData:
+			if inText {
+				text.Write(el)
+			}
+		case xml.EndElement:
+			switch el.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				paragraph := strings.TrimSpace(text.String())
+				switch {
+				case paragraph == "":
+				case table.depth > 0:
+					table.cell = append(table.cell, paragraph)
+				case placeholder == "title" || placeholder == "ctrTitle":
+					title = append(title, paragraph)
+				case placeholder == "body":
+					body.listItem(level, paragraph)
+				default:
+					body.paragraph(paragraph)
+				}
+			case "tc":
+				if table.depth == 1 {
+					table.row = append(table.row, strings.Join(table.cell, " "))
+				}
+			case "tr":
+				if table.depth == 1 && len(table.row) > 0 {
+					table.rows = append(table.rows, table.row)
+				}
+			case "tbl":
+				table.depth--
+				if table.depth == 0 {
+					body.table(table.rows)
+				}
+			}
+		}
+	}
+	return collapseWhitespace(strings.Join(title, " ")), body, nil
+}
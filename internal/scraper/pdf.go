@@ -0,0 +1,158 @@
+package scraper
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/eust-w/urlreader/internal/logger"
+	"github.com/ledongthuc/pdf"
+)
+
+// pdfMagic PDF 文件的起始字节
+const pdfMagic = "%PDF-"
+
+// pdfGlyph 页面上的一个字形
+type pdfGlyph struct {
+	x, y, w, size float64
+	s             string
+}
+
+// pdfLine 页面上同一基线的一行文字
+type pdfLine struct {
+	y, size float64
+	glyphs  []pdfGlyph
+}
+
+// parsePDF 按阅读顺序提取每一页的文本，每页以页码作为二级标题，便于切分与引用时定位。
+// 标题取文档信息中的 Title。
+func parsePDF(data []byte) (doc *document, err error) {
+	// pdf 库遇到损坏的文件会 panic
+	defer func() {
+		if r := recover(); r != nil {
+			doc, err = nil, fmt.Errorf("解析PDF失败: %v", r)
+		}
+	}()
+
+	reader, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("解析PDF失败: %w", err)
+	}
+
+	info := reader.Trailer().Key("Info")
+	doc = &document{
+		title:    strings.TrimSpace(info.Key("Title").Text()),
+		metadata: documentMetadata(info.Key("Author").Text(), pdfDate(info.Key("CreationDate").Text()), pdfDate(info.Key("ModDate").Text())),
+	}
+	for i := 1; i <= reader.NumPage(); i++ {
+		paragraphs := pdfPageText(reader.Page(i), i)
+		if len(paragraphs) == 0 {
+			continue
+		}
+		doc.heading(2, fmt.Sprintf("第 %d 页", i))
+		for _, p := range paragraphs {
+			doc.paragraph(p)
+		}
+	}
+	return doc, nil
+}
+
+// pdfDate 把 PDF 日期（如 D:20240102150405+08'00'）转换为 RFC 3339，无法识别时返回原文
+func pdfDate(value string) string {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "D:")
+	if len(value) < 4 {
+		return value
+	}
+	// 省略的时间部分按 0 补齐，时区写法 +08'00' 转换为 +0800
+	digits := len(value)
+	for i, c := range value {
+		if c < '0' || c > '9' {
+			digits = i
+			break
+		}
+	}
+	stamp := value[:digits] + "0101000000"[max(digits-4, 0):]
+	if digits > 14 {
+		stamp = value[:14]
+	}
+	zone := strings.ReplaceAll(value[digits:], "'", "")
+	if zone == "" || zone == "Z" {
+		zone = "+0000"
+	}
+	if len(zone) == 3 {
+		zone += "00"
+	}
+	t, err := time.Parse("20060102150405-0700", stamp+zone)
+	if err != nil {
+		return value
+	}
+	return t.Format(time.RFC3339)
+}
+
+// pdfPageText 提取单页的段落：字形按基线分行，行从上到下、行内从左到右排列，行距明显变大处分段
+func pdfPageText(page pdf.Page, num int) (paragraphs []string) {
+	if page.V.IsNull() {
+		return nil
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			logger.GetLogger().Warnw("提取PDF页面失败，跳过该页", "page", num, "error", r)
+			paragraphs = nil
+		}
+	}()
+
+	var lines []*pdfLine
+	for _, t := range page.Content().Text {
+		if t.S == "\n" || t.S == "\r" {
+			continue
+		}
+		g := pdfGlyph{x: t.X, y: t.Y, w: t.W, size: math.Max(t.FontSize, 1), s: t.S}
+		var line *pdfLine
+		for i := len(lines) - 1; i >= 0; i-- {
+			if math.Abs(lines[i].y-g.y) < math.Min(lines[i].size, g.size)/2 {
+				line = lines[i]
+				break
+			}
+		}
+		if line == nil {
+			line = &pdfLine{y: g.y, size: g.size}
+			lines = append(lines, line)
+		}
+		line.glyphs = append(line.glyphs, g)
+	}
+	sort.SliceStable(lines, func(i, j int) bool { return lines[i].y > lines[j].y })
+
+	var current []string
+	for i, line := range lines {
+		if i > 0 && lines[i-1].y-line.y > 1.8*math.Max(line.size, lines[i-1].size) && len(current) > 0 {
+			paragraphs = append(paragraphs, strings.Join(current, " "))
+			current = nil
+		}
+		if s := line.text(); s != "" {
+			current = append(current, s)
+		}
+	}
+	if len(current) > 0 {
+		paragraphs = append(paragraphs, strings.Join(current, " "))
+	}
+	return paragraphs
+}
+
+// text 拼接一行的字形，字形间距超过字号的 0.2 倍时补一个空格
+func (l *pdfLine) text() string {
+	sort.SliceStable(l.glyphs, func(i, j int) bool { return l.glyphs[i].x < l.glyphs[j].x })
+	var b strings.Builder
+	var prev *pdfGlyph
+	for i := range l.glyphs {
+		g := &l.glyphs[i]
+		if prev != nil && g.x-(prev.x+prev.w) > 0.2*g.size && !strings.HasSuffix(b.String(), " ") {
+			b.WriteString(" ")
+		}
+		b.WriteString(g.s)
+		prev = g
+	}
+	return collapseWhitespace(b.String())
+}
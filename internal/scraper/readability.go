@@ -0,0 +1,404 @@
+package scraper
+
+import (
+	"math"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// 参考 Mozilla Readability 的启发式规则
+var (
+	// unlikelyCandidates 类名/ID命中时视为导航、页脚、广告等模板内容
+	unlikelyCandidates = regexp.MustCompile(`(?i)-ad-|ai2html|banner|breadcrumbs|combx|comment|community|cover-wrap|disqus|extra|footer|gdpr|header|legends|menu|related|remark|replies|rss|shoutbox|sidebar|skyscraper|social|sponsor|supplemental|ad-break|agegate|pagination|pager|popup|yom-remote|cookie|consent|newsletter|subscribe|share|nav`)
+	// maybeCandidate 同时命中时不移除
+	maybeCandidate = regexp.MustCompile(`(?i)and|article|body|column|content|main|shadow`)
+	positiveWeight = regexp.MustCompile(`(?i)article|body|content|entry|hentry|h-entry|main|page|pagination|post|text|blog|story`)
+	negativeWeight = regexp.MustCompile(`(?i)-ad-|hidden|^hid$| hid$| hid |^hid |banner|combx|comment|com-|contact|foot|footer|footnote|gdpr|masthead|media|meta|outbrain|promo|related|scroll|share|shoutbox|sidebar|skyscraper|sponsor|shopping|tags|tool|widget|cookie|nav`)
+	whitespaceRun  = regexp.MustCompile(`\s+`)
+)
+
+// boilerplateTags 这些标签的内容永远不会是正文
+const boilerplateTags = "script, style, noscript, template, iframe, svg, canvas, form, button, input, select, textarea, nav, footer, aside, dialog"
+
+// blockTags 正文渲染时作为独立文本块处理的标签
+var blockTags = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true, "dd": true, "details": true,
+	"div": true, "dl": true, "dt": true, "fieldset": true, "figcaption": true, "figure": true,
+	"footer": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"header": true, "hr": true, "li": true, "main": true, "ol": true, "p": true, "pre": true,
+	"section": true, "summary": true, "table": true, "ul": true,
+}
+
+// minParagraphRunes 参与打分的最短段落长度
+const minParagraphRunes = 25
+
+// findMainContent 返回清理后的正文节点（副本），按文档顺序排列；找不到时返回nil
+func findMainContent(doc *goquery.Selection) []*html.Node {
+	body := doc.Find("body").First()
+	if body.Length() == 0 {
+		body = doc
+	}
+	body = body.Clone()
+
+	removeBoilerplate(body)
+
+	scores := scoreCandidates(body)
+	if len(scores) == 0 {
+		return nil
+	}
+
+	var top *html.Node
+	topScore := math.Inf(-1)
+	for node, score := range scores {
+		final := score * (1 - linkDensity(node))
+		scores[node] = final
+		if final > topScore || (final == topScore && top != nil && textLength(node) > textLength(top)) {
+			top = node
+			topScore = final
+		}
+	}
+	if top == nil {
+		return nil
+	}
+
+	return collectSiblings(top, topScore, scores)
+}
+
+// renderText 把节点渲染为纯文本块
+func renderText(nodes []*html.Node) []string {
+	var parts []string
+	seen := make(map[string]bool)
+	for _, n := range nodes {
+		renderBlocks(n, &parts, seen)
+	}
+	return parts
+}
+
+// removeBoilerplate 移除脚本、导航、隐藏元素以及类名/ID疑似模板内容的元素
+func removeBoilerplate(root *goquery.Selection) {
+	root.Find(boilerplateTags).Remove()
+
+	var unlikely []*html.Node
+	root.Find("*").Each(func(_ int, s *goquery.Selection) {
+		node := s.Get(0)
+		switch node.Data {
+		case "html", "body", "article", "main", "table", "tbody", "tr", "td", "th", "a", "code", "pre":
+			return
+		}
+		if isHidden(s) {
+			unlikely = append(unlikely, node)
+			return
+		}
+		if role, _ := s.Attr("role"); role == "navigation" || role == "banner" || role == "contentinfo" || role == "complementary" || role == "dialog" {
+			unlikely = append(unlikely, node)
+			return
+		}
+		match := classAndID(s)
+		if match != "" && unlikelyCandidates.MatchString(match) && !maybeCandidate.MatchString(match) {
+			unlikely = append(unlikely, node)
+		}
+	})
+	for _, n := range unlikely {
+		if n.Parent != nil {
+			n.Parent.RemoveChild(n)
+		}
+	}
+}
+
+// isHidden 判断元素是否对读者不可见
+func isHidden(s *goquery.Selection) bool {
+	if _, ok := s.Attr("hidden"); ok {
+		return true
+	}
+	if v, _ := s.Attr("aria-hidden"); v == "true" {
+		return true
+	}
+	style, _ := s.Attr("style")
+	style = strings.ReplaceAll(strings.ToLower(style), " ", "")
+	return strings.Contains(style, "display:none") || strings.Contains(style, "visibility:hidden")
+}
+
+// classAndID 返回元素 class 与 id 拼接后的字符串
+func classAndID(s *goquery.Selection) string {
+	class, _ := s.Attr("class")
+	id, _ := s.Attr("id")
+	return strings.TrimSpace(class + " " + id)
+}
+
+// scoreCandidates 为段落的祖先节点累计内容分
+func scoreCandidates(root *goquery.Selection) map[*html.Node]float64 {
+	scores := make(map[*html.Node]float64)
+
+	root.Find("p, pre, td, blockquote, div, section").Each(func(_ int, s *goquery.Selection) {
+		node := s.Get(0)
+		// 仅把不含块级子元素的 div/section 当作段落
+		if (node.Data == "div" || node.Data == "section") && hasBlockChild(node) {
+			return
+		}
+
+		text := nodeText(node)
+		length := utf8.RuneCountInString(text)
+		if length < minParagraphRunes {
+			return
+		}
+
+		score := 1.0
+		score += float64(strings.Count(text, ",") + strings.Count(text, "，") + strings.Count(text, "、"))
+		score += math.Min(float64(length/100), 3)
+
+		for level, ancestor := 0, node.Parent; ancestor != nil && level < 3; level, ancestor = level+1, ancestor.Parent {
+			if ancestor.Type != html.ElementNode || ancestor.Data == "html" {
+				break
+			}
+			if _, ok := scores[ancestor]; !ok {
+				scores[ancestor] = initialScore(ancestor)
+			}
+			divider := 1.0
+			if level == 1 {
+				divider = 2
+			} else if level > 1 {
+				divider = float64(level * 3)
+			}
+			scores[ancestor] += score / divider
+		}
+	})
+
+	return scores
+}
+
+// initialScore 根据标签与类名给候选节点一个初始分
+func initialScore(n *html.Node) float64 {
+	score := 0.0
+	switch n.Data {
+	case "article", "main":
+		score += 10
+	case "div":
+		score += 5
+	case "pre", "td", "blockquote":
+		score += 3
+	case "address", "ol", "ul", "dl", "dd", "dt", "li", "form":
+		score -= 3
+	case "h1", "h2", "h3", "h4", "h5", "h6", "th":
+		score -= 5
+	}
+
+	s := goquery.NewDocumentFromNode(n).Selection
+	class, _ := s.Attr("class")
+	id, _ := s.Attr("id")
+	for _, v := range []string{class, id} {
+		if v == "" {
+			continue
+		}
+		if negativeWeight.MatchString(v) {
+			score -= 25
+		}
+		if positiveWeight.MatchString(v) {
+			score += 25
+		}
+	}
+	return score
+}
+
+// collectSiblings 收集得分足够高的兄弟节点，避免正文被拆分在多个容器中时丢失内容
+func collectSiblings(top *html.Node, topScore float64, scores map[*html.Node]float64) []*html.Node {
+	if top.Parent == nil {
+		return []*html.Node{top}
+	}
+
+	threshold := math.Max(10, topScore*0.2)
+	var nodes []*html.Node
+	for sib := top.Parent.FirstChild; sib != nil; sib = sib.NextSibling {
+		if sib.Type != html.ElementNode {
+			continue
+		}
+		if sib == top {
+			nodes = append(nodes, sib)
+			continue
+		}
+		if score, ok := scores[sib]; ok && score >= threshold {
+			nodes = append(nodes, sib)
+			continue
+		}
+		if sib.Data == "p" {
+			text := nodeText(sib)
+			length := utf8.RuneCountInString(text)
+			density := linkDensity(sib)
+			if (length > 80 && density < 0.25) ||
+				(length > 0 && density == 0 && (strings.Contains(text, ". ") || strings.Contains(text, "。"))) {
+				nodes = append(nodes, sib)
+			}
+		}
+	}
+	return nodes
+}
+
+// renderBlocks 按文档顺序把节点渲染为文本块，相同文本只保留第一次出现
+func renderBlocks(n *html.Node, parts *[]string, seen map[string]bool) {
+	add := func(text string) {
+		text = strings.TrimSpace(text)
+		if text == "" {
+			return
+		}
+		key := strings.ToLower(text)
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		*parts = append(*parts, text)
+	}
+
+	if n.Type == html.TextNode {
+		add(collapseWhitespace(n.Data))
+		return
+	}
+	if n.Type != html.ElementNode {
+		return
+	}
+
+	switch n.Data {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		if text := nodeText(n); text != "" {
+			add("[" + n.Data + "] " + text)
+		}
+		return
+	case "p", "blockquote", "figcaption", "dt", "dd", "address", "summary":
+		add(nodeText(n))
+		return
+	case "pre":
+		add(rawText(n))
+		return
+	case "li":
+		// 列表项自身文本不包含嵌套列表，嵌套列表单独渲染
+		var own strings.Builder
+		var nested []*html.Node
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.ElementNode && (c.Data == "ul" || c.Data == "ol") {
+				nested = append(nested, c)
+				continue
+			}
+			own.WriteString(" ")
+			own.WriteString(nodeText(c))
+		}
+		if text := collapseWhitespace(own.String()); text != "" {
+			add("- " + text)
+		}
+		for _, c := range nested {
+			renderBlocks(c, parts, seen)
+		}
+		return
+	case "table":
+		rows := tableRows(n)
+		if len(rows) > 0 {
+			*parts = append(*parts, "[表格]")
+			for _, row := range rows {
+				*parts = append(*parts, strings.Join(row, " | "))
+			}
+		}
+		return
+	case "img", "br", "hr":
+		return
+	}
+
+	// 容器元素：连续的行内内容合并为一个段落，遇到块级子元素时单独渲染
+	var inline strings.Builder
+	flush := func() {
+		add(collapseWhitespace(inline.String()))
+		inline.Reset()
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && blockTags[c.Data] {
+			flush()
+			renderBlocks(c, parts, seen)
+			continue
+		}
+		inline.WriteString(" ")
+		inline.WriteString(nodeText(c))
+	}
+	flush()
+}
+
+// tableRows 提取表格的非空行
+func tableRows(table *html.Node) [][]string {
+	var rows [][]string
+	goquery.NewDocumentFromNode(table).Find("tr").Each(func(_ int, tr *goquery.Selection) {
+		var cells []string
+		tr.Find("td, th").Each(func(_ int, cell *goquery.Selection) {
+			if text := nodeText(cell.Get(0)); text != "" {
+				cells = append(cells, text)
+			}
+		})
+		if len(cells) > 0 {
+			rows = append(rows, cells)
+		}
+	})
+	return rows
+}
+
+// hasBlockChild 判断节点是否包含块级子元素
+func hasBlockChild(n *html.Node) bool {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && blockTags[c.Data] {
+			return true
+		}
+	}
+	return false
+}
+
+// linkDensity 计算链接文本占节点全部文本的比例
+func linkDensity(n *html.Node) float64 {
+	total := textLength(n)
+	if total == 0 {
+		return 0
+	}
+	linkLen := 0
+	goquery.NewDocumentFromNode(n).Find("a").Each(func(_ int, a *goquery.Selection) {
+		linkLen += textLength(a.Get(0))
+	})
+	return float64(linkLen) / float64(total)
+}
+
+// textLength 返回节点规整后文本的字符数
+func textLength(n *html.Node) int {
+	return utf8.RuneCountInString(nodeText(n))
+}
+
+// nodeText 返回节点内所有文本，连续空白合并为一个空格
+func nodeText(n *html.Node) string {
+	return collapseWhitespace(rawText(n))
+}
+
+// rawText 返回节点内所有文本，保留原始空白
+func rawText(n *html.Node) string {
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			b.WriteString(n.Data)
+		case html.ElementNode:
+			if n.Data == "br" {
+				b.WriteString("\n")
+			}
+			if n.Data == "script" || n.Data == "style" {
+				return
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+		if n.Type == html.ElementNode && blockTags[n.Data] {
+			b.WriteString(" ")
+		}
+	}
+	walk(n)
+	return strings.TrimSpace(b.String())
+}
+
+// collapseWhitespace 合并连续空白并去除首尾空白
+func collapseWhitespace(s string) string {
+	return strings.TrimSpace(whitespaceRun.ReplaceAllString(s, " "))
+}
@@ -0,0 +1,89 @@
+package scraper
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// mainText 对HTML执行正文识别并渲染为文本块
+func mainText(t *testing.T, page string) []string {
+	t.Helper()
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return renderText(findMainContent(doc.Selection))
+}
+
+func TestFindMainContent(t *testing.T) {
+	tests := []struct {
+		name, page string
+		want       []string
+	}{
+		{
+			name: "boilerplate",
+			page: `<html><body>
+<div id="cookie-banner"><p>We use cookies to improve your experience, please accept them all.</p></div>
+<nav><a href="/">Home</a><a href="/blog">Blog</a><p>Navigation text that should never appear.</p></nav>
+<header class="site-header"><p>Site header with a tagline that is long enough.</p></header>
+<div id="content">
+<h1>Article title</h1>
+<p>First paragraph of the article, with enough text, commas, and words to score.</p>
+<p>Second paragraph of the article, which continues the story in some detail.</p>
+</div>
+<div class="sidebar"><p>Sidebar text about other articles, popular posts, and tags.</p></div>
+<aside><p>Aside text with related links that is long enough to be a paragraph.</p></aside>
+<footer><p>Footer text, copyright notice, and contact details for the site.</p></footer>
+</body></html>`,
+			want: []string{
+				"[h1] Article title",
+				"First paragraph of the article, with enough text, commas, and words to score.",
+				"Second paragraph of the article, which continues the story in some detail.",
+			},
+		},
+		{
+			name: "document order",
+			page: `<html><body><div class="post">
+<p>Alpha paragraph comes first, and it has a reasonable amount of text.</p>
+<ul><li>Beta item</li><li>Gamma item<ul><li>Delta nested item</li></ul></li></ul>
+<pre>epsilon := "code block"</pre>
+<p>Zeta paragraph comes last, and it also has a reasonable amount of text.</p>
+</div></body></html>`,
+			want: []string{
+				"Alpha paragraph comes first, and it has a reasonable amount of text.",
+				"- Beta item",
+				"- Gamma item",
+				"- Delta nested item",
+				`epsilon := "code block"`,
+				"Zeta paragraph comes last, and it also has a reasonable amount of text.",
+			},
+		},
+		{
+			name: "nested article",
+			page: `<html><body><main><article><section>
+<article><p>Nested paragraph inside two articles, with commas, words, and more words.</p>
+<div><p>Deeply nested paragraph that sits inside a div, an article, and a section.</p></div></article>
+<p>Outer paragraph after the inner article, with its own text, commas, words, and a few more words.</p>
+</section></article></main></body></html>`,
+			want: []string{
+				"Nested paragraph inside two articles, with commas, words, and more words.",
+				"Deeply nested paragraph that sits inside a div, an article, and a section.",
+				"Outer paragraph after the inner article, with its own text, commas, words, and a few more words.",
+			},
+		},
+	}
+	for _, tt := range tests {
+		if got := mainText(t, tt.page); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s:\ngot  %q\nwant %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFindMainContentEmpty(t *testing.T) {
+	if got := mainText(t, `<html><body><nav><p>Only navigation text lives on this page, nothing else.</p></nav></body></html>`); got != nil {
+		t.Errorf("got %q, want nil", got)
+	}
+}
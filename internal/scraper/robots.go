@@ -0,0 +1,148 @@
+package scraper
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"sync"
+	"time"
+
+	"github.com/eust-w/urlreader/internal/logger"
+	"github.com/temoto/robotstxt"
+)
+
+// ErrRobotsDisallowed 表示 robots.txt 禁止以配置的UA抓取起始页面
+var ErrRobotsDisallowed = errors.New("robots.txt 禁止抓取该页面")
+
+// robotsTTL robots.txt 缓存时间
+const robotsTTL = time.Hour
+
+// robotsMaxBytes robots.txt 最多读取的字节数，超出部分忽略
+const robotsMaxBytes = 512 << 10
+
+// robotsCacheSize 最多缓存的站点数，超出时淘汰最久未使用的站点
+const robotsCacheSize = 1000
+
+// robotsEntry 缓存的一个站点的 robots.txt
+type robotsEntry struct {
+	origin    string
+	data      *robotstxt.RobotsData
+	fetchedAt time.Time
+}
+
+// robotsCache 按站点缓存 robots.txt 的LRU缓存，条目在 robotsTTL 后过期，可并发使用
+type robotsCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List
+	entries  map[string]*list.Element
+}
+
+// newRobotsCache 创建最多缓存 capacity 个站点的 robots.txt 缓存
+func newRobotsCache(capacity int) *robotsCache {
+	return &robotsCache{
+		capacity: max(capacity, 1),
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// get 返回未过期的缓存并标记为最近使用，过期的条目直接删除
+func (c *robotsCache) get(origin string) (*robotstxt.RobotsData, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[origin]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*robotsEntry)
+	if time.Since(entry.fetchedAt) >= robotsTTL {
+		c.order.Remove(el)
+		delete(c.entries, origin)
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return entry.data, true
+}
+
+// put 保存站点的 robots.txt，超出容量时淘汰最久未使用的站点
+func (c *robotsCache) put(origin string, data *robotstxt.RobotsData) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry := &robotsEntry{origin: origin, data: data, fetchedAt: time.Now()}
+	if el, ok := c.entries[origin]; ok {
+		el.Value = entry
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[origin] = c.order.PushFront(entry)
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*robotsEntry).origin)
+	}
+}
+
+// robots 返回目标站点的 robots.txt 规则，获取失败时视为没有限制
+func (s *Scraper) robots(ctx context.Context, target *neturl.URL) *robotstxt.RobotsData {
+	origin := target.Scheme + "://" + target.Host
+	if data, ok := s.robotsCache.get(origin); ok {
+		return data
+	}
+	data := s.fetchRobots(ctx, origin)
+	s.robotsCache.put(origin, data)
+	return data
+}
+
+// fetchRobots 下载并解析站点的 robots.txt。
+// 4xx 视为没有限制，5xx 视为全部禁止；网络错误或解析失败时视为没有限制。
+func (s *Scraper) fetchRobots(ctx context.Context, origin string) *robotstxt.RobotsData {
+	log := logger.GetLogger()
+	allowAll, _ := robotstxt.FromStatusAndBytes(http.StatusNotFound, nil)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, origin+"/robots.txt", nil)
+	if err != nil {
+		return allowAll
+	}
+	req.Header.Set("User-Agent", s.userAgent)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		log.Warnw("获取 robots.txt 失败，按无限制处理", "origin", origin, "error", err)
+		return allowAll
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, robotsMaxBytes))
+	if err != nil {
+		log.Warnw("读取 robots.txt 失败，按无限制处理", "origin", origin, "error", err)
+		return allowAll
+	}
+	data, err := robotstxt.FromStatusAndBytes(resp.StatusCode, body)
+	if err != nil {
+		log.Warnw("解析 robots.txt 失败，按无限制处理", "origin", origin, "status", resp.StatusCode, "error", err)
+		return allowAll
+	}
+	return data
+}
+
+// robotsAllowed 判断 robots.txt 是否允许以配置的UA抓取该URL，同时返回该UA的抓取间隔
+func (s *Scraper) robotsAllowed(ctx context.Context, target *neturl.URL) (bool, time.Duration) {
+	if !s.respectRobots {
+		return true, 0
+	}
+	data := s.robots(ctx, target)
+	group := data.FindGroup(s.robotsAgent)
+
+	path := target.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if target.RawQuery != "" {
+		path += "?" + target.RawQuery
+	}
+	return data.TestAgent(path, s.robotsAgent), group.CrawlDelay
+}
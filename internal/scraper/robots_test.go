@@ -0,0 +1,38 @@
+package scraper
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/temoto/robotstxt"
+)
+
+func TestRobotsCache(t *testing.T) {
+	data, err := robotstxt.FromStatusAndBytes(http.StatusOK, []byte("User-agent: *\nDisallow: /private"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache := newRobotsCache(2)
+	cache.put("https://a.example", data)
+	cache.put("https://b.example", data)
+	if _, ok := cache.get("https://a.example"); !ok {
+		t.Fatal("entry a missing")
+	}
+	cache.put("https://c.example", data)
+	if _, ok := cache.get("https://b.example"); ok {
+		t.Error("least recently used site not evicted")
+	}
+	if len(cache.entries) != 2 || cache.order.Len() != 2 {
+		t.Errorf("cache holds %d entries, want 2", len(cache.entries))
+	}
+
+	// 过期的条目在读取时删除
+	cache.entries["https://a.example"].Value.(*robotsEntry).fetchedAt = time.Now().Add(-robotsTTL)
+	if _, ok := cache.get("https://a.example"); ok {
+		t.Error("expired entry returned")
+	}
+	if _, ok := cache.entries["https://a.example"]; ok {
+		t.Error("expired entry not removed")
+	}
+}
@@ -0,0 +1,357 @@
+package scraper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	neturl "net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+	"github.com/eust-w/urlreader/internal/logger"
+	"github.com/eust-w/urlreader/internal/models"
+	"golang.org/x/net/html"
+)
+
+// ruleCheckInterval 两次检查规则文件是否修改的最小间隔
+const ruleCheckInterval = 2 * time.Second
+
+// ErrInvalidRule 表示提取规则的选择器或正则表达式无效
+var ErrInvalidRule = errors.New("无效的提取规则")
+
+// extractionRule 编译后的站点提取规则
+type extractionRule struct {
+	models.ExtractionRule
+	paths   []*regexp.Regexp
+	title   cascadia.Selector
+	include cascadia.Selector
+	exclude cascadia.Selector
+	waitFor cascadia.Selector
+	strip   []*regexp.Regexp
+}
+
+// compileRule 校验并编译提取规则。domain 只在规则文件中必填，试运行的规则可以省略。
+func compileRule(rule models.ExtractionRule) (*extractionRule, error) {
+	rule.Domain = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(rule.Domain)), ".")
+	r := &extractionRule{ExtractionRule: rule}
+	var err error
+	if r.paths, err = compileGlobs(rule.Paths); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidRule, err)
+	}
+	for _, s := range []struct {
+		field     string
+		selectors []string
+		compiled  *cascadia.Selector
+	}{
+		{"title", []string{rule.Title}, &r.title},
+		{"include", rule.Include, &r.include},
+		{"exclude", rule.Exclude, &r.exclude},
+		{"wait_for", []string{rule.WaitFor}, &r.waitFor},
+	} {
+		if *s.compiled, err = compileSelectors(s.selectors); err != nil {
+			return nil, fmt.Errorf("%w: %s: %w", ErrInvalidRule, s.field, err)
+		}
+	}
+	for _, pattern := range rule.Strip {
+		re, err := regexp.Compile("(?m)" + pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%w: strip %q: %w", ErrInvalidRule, pattern, err)
+		}
+		r.strip = append(r.strip, re)
+	}
+	return r, nil
+}
+
+// compileSelectors 把多个选择器合并编译为一个，全部为空时返回 nil
+func compileSelectors(selectors []string) (cascadia.Selector, error) {
+	var parts []string
+	for _, s := range selectors {
+		if s = strings.TrimSpace(s); s != "" {
+			if _, err := cascadia.Compile(s); err != nil {
+				return nil, fmt.Errorf("无效的选择器 %q: %w", s, err)
+			}
+			parts = append(parts, s)
+		}
+	}
+	if len(parts) == 0 {
+		return nil, nil
+	}
+	return cascadia.Compile(strings.Join(parts, ", "))
+}
+
+// matches 判断规则是否适用于URL
+func (r *extractionRule) matches(u *neturl.URL) bool {
+	return matchHost(r.Domain, u.Hostname()) && allowedByGlobs(u, r.paths, nil)
+}
+
+// matchHost 判断主机名是否匹配模式，"*.example.com" 匹配所有子域名
+func matchHost(pattern, host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	return pattern == host || strings.HasPrefix(pattern, "*.") && strings.HasSuffix(host, pattern[1:])
+}
+
+// extract 按规则提取标题与正文，规则不适用于页面时返回原因。
+// 没有 include 时移除 exclude 元素后对整个页面使用通用提取。
+func (r *extractionRule) extract(s *Scraper, page *fetchedPage, format string) (string, string, error) {
+	doc := page.doc.Selection
+	if r.waitFor != nil && doc.FindMatcher(r.waitFor).Length() == 0 {
+		return "", "", fmt.Errorf("页面中没有匹配 wait_for %q 的元素", r.WaitFor)
+	}
+
+	var title string
+	if r.title != nil {
+		title = collapseWhitespace(doc.FindMatcher(r.title).First().Text())
+	}
+
+	var content string
+	if r.include == nil {
+		root := doc.Clone()
+		if r.exclude != nil {
+			root.FindMatcher(r.exclude).Remove()
+		}
+		content = s.extract(root, page.base, format)
+	} else {
+		nodes := r.includeNodes(doc)
+		if len(nodes) == 0 {
+			return "", "", errors.New("页面中没有匹配 include 的元素")
+		}
+		content = renderNodes(nodes, page.base, format)
+	}
+
+	if content = r.stripText(content); content == "" {
+		return "", "", errors.New("按规则提取的正文为空")
+	}
+	return r.stripText(title), content, nil
+}
+
+// includeNodes 返回 include 匹配元素移除 exclude 与非内容元素后的副本，嵌套的匹配只保留最外层
+func (r *extractionRule) includeNodes(doc *goquery.Selection) []*html.Node {
+	matched := doc.FindMatcher(r.include)
+	outer := matched.FilterFunction(func(_ int, el *goquery.Selection) bool {
+		return el.ParentsMatcher(r.include).Length() == 0
+	}).Clone()
+	if r.exclude != nil {
+		outer.FindMatcher(r.exclude).Remove()
+	}
+	outer.Find("script, style, noscript, template, iframe, svg, canvas").Remove()
+	return outer.Nodes
+}
+
+// renderNodes 按输出格式渲染一组节点
+func renderNodes(nodes []*html.Node, base *neturl.URL, format string) string {
+	switch format {
+	case FormatMarkdown:
+		return newMarkdownRenderer(base).render(nodes)
+	case FormatHTML:
+		return renderHTML(nodes, base)
+	default:
+		return strings.Join(renderText(nodes), "\n\n")
+	}
+}
+
+// stripText 删除 strip 匹配的内容并整理空行
+func (r *extractionRule) stripText(text string) string {
+	if len(r.strip) == 0 {
+		return text
+	}
+	for _, re := range r.strip {
+		text = re.ReplaceAllString(text, "")
+	}
+	return strings.TrimSpace(blankLines.ReplaceAllString(text, "\n\n"))
+}
+
+// ruleSet 从规则文件加载的站点提取规则。抓取时检查文件是否修改，修改后自动重新加载，无需重启服务。
+type ruleSet struct {
+	path string
+
+	mu      sync.Mutex
+	rules   []*extractionRule
+	modTime time.Time
+	size    int64
+	checked time.Time
+	// loaded 当前规则对应的文件版本（修改时间与大小），作为缓存键的一部分
+	loaded string
+	// missing 规则文件不存在，避免每次检查都记录错误
+	missing bool
+}
+
+// newRuleSet 加载规则文件，path 为空时返回 nil
+func newRuleSet(path string) *ruleSet {
+	if path == "" {
+		return nil
+	}
+	rs := &ruleSet{path: path}
+	rs.mu.Lock()
+	rs.reload()
+	rs.mu.Unlock()
+	return rs
+}
+
+// current 按需重新加载规则文件，返回当前的规则与版本
+func (rs *ruleSet) current() ([]*extractionRule, string) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if time.Since(rs.checked) >= ruleCheckInterval {
+		rs.reload()
+	}
+	return rs.rules, rs.loaded
+}
+
+// version 返回当前规则的版本，规则文件修改并重新加载后改变；未配置规则文件时返回空字符串
+func (rs *ruleSet) version() string {
+	if rs == nil {
+		return ""
+	}
+	_, version := rs.current()
+	return version
+}
+
+// match 返回第一个适用于URL的规则，没有时返回 nil
+func (rs *ruleSet) match(u *neturl.URL) *extractionRule {
+	if rs == nil {
+		return nil
+	}
+	rules, _ := rs.current()
+	for _, r := range rules {
+		if r.matches(u) {
+			return r
+		}
+	}
+	return nil
+}
+
+// reload 文件的修改时间或大小变化时重新加载，文件无法解析时保留原有规则。调用方需持有 mu。
+func (rs *ruleSet) reload() {
+	log := logger.GetLogger()
+	rs.checked = time.Now()
+
+	info, err := os.Stat(rs.path)
+	if err != nil {
+		if !rs.missing {
+			log.Errorw("读取提取规则文件失败", "path", rs.path, "error", err)
+			rs.missing = true
+		}
+		return
+	}
+	rs.missing = false
+	if info.ModTime().Equal(rs.modTime) && info.Size() == rs.size {
+		return
+	}
+	rs.modTime, rs.size = info.ModTime(), info.Size()
+
+	data, err := os.ReadFile(rs.path)
+	if err != nil {
+		log.Errorw("读取提取规则文件失败", "path", rs.path, "error", err)
+		return
+	}
+	var entries []models.ExtractionRule
+	if err := json.Unmarshal(data, &entries); err != nil {
+		log.Errorw("解析提取规则文件失败，继续使用原有规则", "path", rs.path, "error", err)
+		return
+	}
+
+	var rules []*extractionRule
+	for _, entry := range entries {
+		rule, err := compileRule(entry)
+		if err == nil && rule.Domain == "" {
+			err = fmt.Errorf("%w: 缺少 domain", ErrInvalidRule)
+		}
+		if err != nil {
+			log.Warnw("忽略无效的提取规则", "domain", entry.Domain, "error", err)
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	rs.rules = rules
+	rs.loaded = fmt.Sprintf("%d-%d", info.ModTime().UnixNano(), info.Size())
+	log.Infow("已加载站点提取规则", "path", rs.path, "count", len(rules))
+}
+
+// extractHTML 提取网页的标题与正文。rule 非 nil 时先按规则提取，规则不适用于该页面时
+// 使用通用提取并返回原因。
+func (s *Scraper) extractHTML(page *fetchedPage, metadata *models.Metadata, format string, rule *extractionRule) (string, string, error) {
+	title := strings.TrimSpace(page.doc.Find("title").First().Text())
+	if title == "" && metadata != nil {
+		title = metadata.OpenGraph["og:title"]
+	}
+	if rule != nil {
+		ruleTitle, content, err := rule.extract(s, page, format)
+		if err == nil {
+			logger.GetLogger().Infow("按站点规则提取正文", "url", page.finalURL.String(), "domain", rule.Domain)
+			if ruleTitle == "" {
+				ruleTitle = rule.stripText(title)
+			}
+			return ruleTitle, content, nil
+		}
+		logger.GetLogger().Infow("站点规则不适用，使用通用提取", "url", page.finalURL.String(), "domain", rule.Domain, "reason", err)
+		return title, s.extract(page.doc.Selection, page.base, format), err
+	}
+	return title, s.extract(page.doc.Selection, page.base, format), nil
+}
+
+// RuleTestResult 试运行提取规则的结果
+type RuleTestResult struct {
+	Title    string
+	Content  string
+	URL      string
+	FinalURL string
+	Format   string
+	// Rule 使用的规则，没有匹配的规则时为 nil
+	Rule *models.ExtractionRule
+	// Applied 是否按规则提取；为 false 时内容来自通用提取，原因见 Reason
+	Applied bool
+	Reason  string
+}
+
+// TestRule 抓取URL并按规则提取，不读写缓存。rule 为 nil 时使用规则文件中匹配该URL的规则；
+// 给定的规则不检查 domain 与 paths 是否匹配该URL。规则无效时返回 ErrInvalidRule。
+func (s *Scraper) TestRule(ctx context.Context, url string, rule *models.ExtractionRule, format string) (*RuleTestResult, error) {
+	format, err := normalizeFormat(format)
+	if err != nil {
+		return nil, err
+	}
+	var compiled *extractionRule
+	if rule != nil {
+		if compiled, err = compileRule(*rule); err != nil {
+			return nil, err
+		}
+	}
+	if url, err = normalizeURL(url); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := s.withBudget(ctx)
+	defer cancel()
+	page, err := s.fetch(ctx, url, nil)
+	if err != nil {
+		return nil, s.budgetError(ctx, err)
+	}
+
+	result := &RuleTestResult{URL: url, FinalURL: page.finalURL.String(), Format: format}
+	if compiled == nil {
+		compiled = s.rules.match(page.finalURL)
+	}
+	if compiled != nil {
+		result.Rule = &compiled.ExtractionRule
+	}
+	if page.document != nil {
+		result.Title, result.Content = page.document.title, page.document.render(format)
+		if compiled != nil {
+			result.Reason = "提取规则只适用于HTML网页"
+		}
+		return result, nil
+	}
+
+	result.Title, result.Content, err = s.extractHTML(page, extractMetadata(page.doc, page.base), format, compiled)
+	result.Applied = compiled != nil && err == nil
+	if err != nil {
+		result.Reason = err.Error()
+	}
+	return result, nil
+}
@@ -0,0 +1,166 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eust-w/urlreader/config"
+	"github.com/eust-w/urlreader/internal/models"
+)
+
+const rulesPage = `<html><head><title>Release notes - Confluence</title></head><body>
+<nav><a href="/">Home</a><a href="/spaces">Spaces</a></nav>
+<h1 id="title-text">Release notes</h1>
+<div id="main-content">
+<div class="page-metadata">Created by Ada</div>
+<p>Version 2.0 adds offline mode.</p>
+<p>Last edited by Bob on Monday</p>
+</div>
+<div class="sidebar"><p>Sidebar text that only the second rule keeps.</p></div>
+</body></html>`
+
+func TestScrapeRules(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/login" {
+			fmt.Fprint(w, "<html><head><title>Log in</title></head><body><p>Please log in to continue reading this page.</p></body></html>")
+			return
+		}
+		fmt.Fprint(w, rulesPage)
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "rules.json")
+	writeRules := func(content string, modTime time.Time) {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeRules(`[
+		{"domain": "other.example.com", "include": ["body"]},
+		{"domain": "127.0.0.1", "paths": ["/display/**", "/login"], "title": "#title-text", "include": ["#main-content"],
+		 "exclude": [".page-metadata"], "wait_for": "#main-content", "strip": ["^Last edited by.*$"]}
+	]`, time.Now().Add(-time.Hour))
+
+	s := NewScraper(&config.Config{ScraperRulesFile: path})
+	content, err := s.ScrapeURL(srv.URL + "/display/notes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content.Title != "Release notes" || content.Content != "Version 2.0 adds offline mode." {
+		t.Errorf("rule: title = %q, content = %q", content.Title, content.Content)
+	}
+
+	// 不在 paths 中的页面与缺少 wait_for 元素的页面使用通用提取
+	for _, p := range []string{"/other", "/login"} {
+		content, err := s.ScrapeURL(srv.URL + p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(content.Content, "offline mode") == (p == "/login") || content.Title == "Release notes" {
+			t.Errorf("%s: title = %q, content = %q", p, content.Title, content.Content)
+		}
+	}
+
+	// 修改后的规则文件在下一次抓取时生效，无法解析的文件不影响已加载的规则
+	writeRules(`[{"domain": "127.0.0.1", "include": [".sidebar"], "strip": [" - Confluence$"]}]`, time.Now())
+	s.rules.checked = time.Time{}
+	if content, err := s.ScrapeURL(srv.URL + "/display/notes"); err != nil || content.Title != "Release notes" ||
+		content.Content != "Sidebar text that only the second rule keeps." {
+		t.Errorf("reloaded: content = %+v, err = %v", content, err)
+	}
+	writeRules(`[{"domain": `, time.Now().Add(time.Minute))
+	s.rules.checked = time.Time{}
+	if content, err := s.ScrapeURL(srv.URL + "/display/notes"); err != nil || !strings.HasPrefix(content.Content, "Sidebar") {
+		t.Errorf("broken file: content = %+v, err = %v", content, err)
+	}
+}
+
+func TestTestRule(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, rulesPage)
+	}))
+	defer srv.Close()
+	s := NewScraper(&config.Config{})
+	ctx := context.Background()
+
+	if _, err := s.TestRule(ctx, srv.URL, &models.ExtractionRule{Domain: "x", Include: []string{"div["}}, ""); !errors.Is(err, ErrInvalidRule) {
+		t.Errorf("invalid selector: err = %v, want ErrInvalidRule", err)
+	}
+
+	result, err := s.TestRule(ctx, srv.URL, &models.ExtractionRule{Domain: "wiki.example.com", Include: []string{"#main-content p"}}, FormatMarkdown)
+	if err != nil || !result.Applied || result.Rule == nil || result.Content != "Version 2.0 adds offline mode.\n\nLast edited by Bob on Monday" {
+		t.Errorf("inline rule: result = %+v, err = %v", result, err)
+	}
+
+	// 试运行的规则可以省略 domain
+	result, err = s.TestRule(ctx, srv.URL, &models.ExtractionRule{Include: []string{"#main-content p"}}, FormatMarkdown)
+	if err != nil || !result.Applied || !strings.Contains(result.Content, "offline mode") {
+		t.Errorf("rule without domain: result = %+v, err = %v", result, err)
+	}
+
+	result, err = s.TestRule(ctx, srv.URL, &models.ExtractionRule{Domain: "wiki.example.com", Include: []string{"article"}}, "")
+	if err != nil || result.Applied || result.Reason == "" || !strings.Contains(result.Content, "offline mode") {
+		t.Errorf("unmatched include: result = %+v, err = %v", result, err)
+	}
+
+	if result, err := s.TestRule(ctx, srv.URL, nil, ""); err != nil || result.Rule != nil || result.Applied {
+		t.Errorf("no rules: result = %+v, err = %v", result, err)
+	}
+}
+
+func TestScrapeRulesCache(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, rulesPage)
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "rules.json")
+	writeRules := func(include string, modTime time.Time) {
+		if err := os.WriteFile(path, []byte(`[{"domain": "127.0.0.1", "include": ["`+include+`"]}]`), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeRules("#main-content p", time.Now().Add(-time.Hour))
+	s := NewScraper(&config.Config{ScraperRulesFile: path, ScrapeCacheBackend: "memory", ScrapeCacheSize: 10, ScrapeCacheTTL: 60})
+
+	if content, err := s.ScrapeURL(srv.URL); err != nil || content.Cache != CacheMiss || !strings.HasPrefix(content.Content, "Version 2.0") {
+		t.Fatalf("first: content = %+v, err = %v", content, err)
+	}
+	if content, err := s.ScrapeURL(srv.URL); err != nil || content.Cache != CacheHit {
+		t.Fatalf("second: content = %+v, err = %v", content, err)
+	}
+
+	// 规则修改后，有效期内与过期后重新验证都不再使用按旧规则提取的内容
+	writeRules(".sidebar", time.Now())
+	s.rules.checked = time.Time{}
+	s.cacheTTL = 0
+	content, err := s.ScrapeURL(srv.URL)
+	if err != nil || content.Cache != CacheMiss || content.Content != "Sidebar text that only the second rule keeps." {
+		t.Errorf("after reload: content = %+v, err = %v", content, err)
+	}
+	if content, err := s.ScrapeURL(srv.URL); err != nil || content.Cache != CacheRevalidated || !strings.HasPrefix(content.Content, "Sidebar") {
+		t.Errorf("revalidated: content = %+v, err = %v", content, err)
+	}
+}
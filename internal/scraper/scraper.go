@@ -1,158 +1,293 @@
 package scraper
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"net/http"
+	neturl "net/url"
 	"strings"
 	"time"
 
-	"github.com/gocolly/colly/v2"
+	"github.com/PuerkitoBio/goquery"
+	"github.com/eust-w/urlreader/config"
 	"github.com/eust-w/urlreader/internal/logger"
+	"github.com/eust-w/urlreader/internal/models"
+	"golang.org/x/net/html"
 )
 
-// Scraper 定义网页抓取器
+// 正文提取模式
+const (
+	// ExtractReadability 按文本密度与链接密度识别正文，失败时回退到选择器模式
+	ExtractReadability = "readability"
+	// ExtractSelector 按固定选择器收集页面文本
+	ExtractSelector = "selector"
+)
+
+// 输出格式
+const (
+	FormatText     = "text"
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+)
+
+// defaultUserAgent 抓取时使用的浏览器UA
+const defaultUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36"
+
+// Scraper 定义网页抓取器。
+// Scraper 除 robots.txt 缓存外只保存只读配置，每次抓取的状态都在调用内部创建，可被多个请求并发使用。
 type Scraper struct {
-	collector *colly.Collector
+	client      *http.Client
+	userAgent   string
+	extractMode string
+	// robotsAgent 匹配 robots.txt 规则的爬虫名称
+	robotsAgent   string
+	respectRobots bool
+	robotsCache   *robotsCache
+	maxBodyBytes  int64
+	maxRedirects  int
+	// allowedTypes 允许抓取的媒体类型（小写），为空时不限制
+	allowedTypes []string
+	// timeBudget 一次请求中所有抓取的总时间上限
+	timeBudget time.Duration
+	// cache 单页面抓取结果的缓存，未启用时为 nil
+	cache    Cache
+	cacheTTL time.Duration
+	// rules 站点提取规则，未配置规则文件时为 nil
+	rules *ruleSet
 }
 
 // NewScraper 创建一个新的网页抓取器
-func NewScraper() *Scraper {
+func NewScraper(cfg *config.Config) *Scraper {
 	log := logger.GetLogger()
-	c := colly.NewCollector(
-		colly.UserAgent("Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36"),
-		colly.MaxDepth(1),
-		colly.AllowURLRevisit(), // 允许重复访问同一URL
-	)
-
-	c.SetRequestTimeout(30 * time.Second)
-	log.Infow("Scraper 初始化完成")
 
-	return &Scraper{
-		collector: c,
+	extractMode := strings.ToLower(cfg.ScraperExtractMode)
+	if extractMode != ExtractSelector {
+		extractMode = ExtractReadability
+	}
+	userAgent := cfg.ScraperUserAgent
+	if userAgent == "" {
+		userAgent = defaultUserAgent
+	}
+	s := &Scraper{
+		client:        &http.Client{Timeout: 30 * time.Second},
+		userAgent:     userAgent,
+		extractMode:   extractMode,
+		robotsAgent:   cfg.RobotsUserAgent,
+		respectRobots: cfg.RespectRobots,
+		robotsCache:   newRobotsCache(robotsCacheSize),
+		maxBodyBytes:  defaultMaxBodyBytes,
+		maxRedirects:  defaultMaxRedirects,
+		timeBudget:    defaultTimeBudget,
+	}
+	if cfg.ScraperMaxBodyBytes > 0 {
+		s.maxBodyBytes = int64(cfg.ScraperMaxBodyBytes)
+	}
+	if cfg.ScraperMaxRedirects > 0 {
+		s.maxRedirects = cfg.ScraperMaxRedirects
+	}
+	if cfg.ScraperTimeBudget > 0 {
+		s.timeBudget = time.Duration(cfg.ScraperTimeBudget) * time.Second
+	}
+	for _, t := range cfg.ScraperAllowedTypes {
+		s.allowedTypes = append(s.allowedTypes, strings.ToLower(strings.TrimSpace(t)))
+	}
+	cache, err := newCache(strings.ToLower(cfg.ScrapeCacheBackend), cfg.ScrapeCachePath, cfg.ScrapeCacheSize)
+	if err != nil {
+		log.Errorw("初始化抓取缓存失败，不使用缓存", "backend", cfg.ScrapeCacheBackend, "error", err)
+	}
+	s.cache = cache
+	s.cacheTTL = time.Duration(cfg.ScrapeCacheTTL) * time.Second
+	s.rules = newRuleSet(cfg.ScraperRulesFile)
+	s.client.CheckRedirect = s.checkRedirect
+	if cfg.SSRFProtection {
+		s.client.Transport = newAddressGuard(cfg.SSRFAllowlist).transport()
 	}
+	log.Infow("Scraper 初始化完成", "extract_mode", extractMode, "respect_robots", cfg.RespectRobots,
+		"ssrf_protection", cfg.SSRFProtection, "ssrf_allowlist", cfg.SSRFAllowlist,
+		"max_body_bytes", s.maxBodyBytes, "max_redirects", s.maxRedirects, "allowed_types", s.allowedTypes, "time_budget", s.timeBudget,
+		"cache", cfg.ScrapeCacheBackend, "cache_ttl", s.cacheTTL, "rules_file", cfg.ScraperRulesFile)
+	return s
 }
 
 // ScrapedContent 存储抓取的网页内容
 type ScrapedContent struct {
-	Title   string `json:"title"`
-	Content string `json:"content"`
-	URL     string `json:"url"`
+	Title    string `json:"title"`
+	Content  string `json:"content"`
+	URL      string `json:"url"`
+	FinalURL string `json:"final_url"`
+	Format   string `json:"format"`
+	// Metadata 页面的描述、作者、日期、OpenGraph 与 JSON-LD 等元数据，没有时为 nil
+	Metadata *models.Metadata `json:"metadata,omitempty"`
+	// Encoding 响应正文的原始编码，如 utf-8、gbk、big5；PDF 等二进制文档为空
+	Encoding string `json:"encoding,omitempty"`
+	// Cache 缓存状态：hit、revalidated 或 miss，未启用缓存时为空
+	Cache string `json:"cache,omitempty"`
 }
 
-// ScrapeURL 抓取指定URL的内容
-func (s *Scraper) ScrapeURL(url string) (*ScrapedContent, error) {
-	log := logger.GetLogger()
-	log.Infow("开始抓取URL", "url", url)
-	if url == "" {
-		log.Errorw("URL不能为空")
-		return nil, errors.New("URL不能为空")
+// ScrapeOptions 控制单次抓取的行为
+type ScrapeOptions struct {
+	// Format 输出格式：text（默认）、markdown 或 html
+	Format string
+	// Cache 缓存模式：默认、bypass、prefer 或 only，见 CacheDefault 等常量
+	Cache string
+}
+
+// extract 按配置的模式和输出格式从页面中提取正文
+func (s *Scraper) extract(page *goquery.Selection, base *neturl.URL, format string) string {
+	var nodes []*html.Node
+	if s.extractMode == ExtractReadability {
+		nodes = findMainContent(page)
+		if format == FormatText {
+			if parts := renderText(nodes); len(parts) > 0 {
+				return strings.Join(parts, "\n\n")
+			}
+			nodes = nil
+		}
+		if len(nodes) == 0 {
+			logger.GetLogger().Infow("未识别到正文节点，回退到选择器模式")
+		}
 	}
 
-	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
-		url = "https://" + url
-		log.Infow("自动补全URL为https", "url", url)
+	switch format {
+	case FormatMarkdown:
+		if len(nodes) == 0 {
+			nodes = cleanBody(page)
+		}
+		return newMarkdownRenderer(base).render(nodes)
+	case FormatHTML:
+		if len(nodes) == 0 {
+			nodes = cleanBody(page)
+		}
+		return renderHTML(nodes, base)
+	default:
+		return strings.Join(extractBySelectors(page.Find("body")), "\n\n")
 	}
+}
 
-	content := &ScrapedContent{
-		URL: url,
-	}
-
-	// 提取标题
-	s.collector.OnHTML("title", func(e *colly.HTMLElement) {
-		content.Title = e.Text
-		log.Infow("抓取到网页标题", "title", e.Text)
-	})
-
-	// 提取正文内容
-	var textParts []string
-
-	// 提取主要文本内容
-	s.collector.OnHTML("body", func(e *colly.HTMLElement) {
-		log.Infow("抓取到网页body")
-		// 提取段落
-		e.ForEach("p", func(_ int, el *colly.HTMLElement) {
-			text := strings.TrimSpace(el.Text)
-			if text != "" {
-				textParts = append(textParts, text)
-			}
-		})
+// cleanBody 返回去除脚本、样式等非内容元素后的 body 副本
+func cleanBody(page *goquery.Selection) []*html.Node {
+	body := page.Find("body").First()
+	if body.Length() == 0 {
+		body = page
+	}
+	body = body.Clone()
+	body.Find("script, style, noscript, template, iframe, svg, canvas").Remove()
+	return body.Nodes
+}
 
-		// 提取标题
-		e.ForEach("h1, h2, h3, h4, h5, h6", func(_ int, el *colly.HTMLElement) {
-			text := strings.TrimSpace(el.Text)
-			if text != "" {
-				textParts = append(textParts, fmt.Sprintf("[%s] %s", el.Name, text))
-			}
-		})
+// ScrapeURL 以默认选项抓取指定URL的内容
+func (s *Scraper) ScrapeURL(url string) (*ScrapedContent, error) {
+	return s.Scrape(url, ScrapeOptions{})
+}
 
-		// 提取列表
-		e.ForEach("li", func(_ int, el *colly.HTMLElement) {
-			text := strings.TrimSpace(el.Text)
-			if text != "" {
-				textParts = append(textParts, "- "+text)
-			}
-		})
-
-		// 提取表格内容
-		e.ForEach("table", func(_ int, el *colly.HTMLElement) {
-			textParts = append(textParts, "[表格]")
-			el.ForEach("tr", func(_ int, row *colly.HTMLElement) {
-				var rowTexts []string
-				row.ForEach("td, th", func(_ int, cell *colly.HTMLElement) {
-					text := strings.TrimSpace(cell.Text)
-					if text != "" {
-						rowTexts = append(rowTexts, text)
-					}
-				})
-				if len(rowTexts) > 0 {
-					textParts = append(textParts, strings.Join(rowTexts, " | "))
-				}
-			})
-		})
-
-		// 提取文章内容
-		e.ForEach("article", func(_ int, el *colly.HTMLElement) {
-			text := strings.TrimSpace(el.Text)
-			if text != "" {
-				textParts = append(textParts, text)
-			}
-		})
+// Scrape 按给定选项抓取指定URL的内容
+func (s *Scraper) Scrape(url string, opts ScrapeOptions) (*ScrapedContent, error) {
+	return s.ScrapeContext(context.Background(), url, opts)
+}
 
-		// 提取div内容（可能包含主要内容）
-		e.ForEach("div.content, div.main, div.article, div#content, div#main, div#article", func(_ int, el *colly.HTMLElement) {
-			text := strings.TrimSpace(el.Text)
-			if text != "" {
-				textParts = append(textParts, text)
-			}
-		})
-	})
+// ScrapeContext 与 Scrape 相同，ctx 取消时中止抓取
+func (s *Scraper) ScrapeContext(ctx context.Context, url string, opts ScrapeOptions) (*ScrapedContent, error) {
+	format, err := normalizeFormat(opts.Format)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := s.withBudget(ctx)
+	defer cancel()
+	content, err := s.scrapeCached(ctx, url, format, opts.Cache)
+	return content, s.budgetError(ctx, err)
+}
 
-	// 错误处理
-	var scrapeErr error
-	s.collector.OnError(func(r *colly.Response, err error) {
-		scrapeErr = fmt.Errorf("抓取错误 %s: %w", r.Request.URL, err)
-	})
+// ErrorCode 返回抓取错误对应的机器可读错误码，普通的抓取失败返回空字符串
+func ErrorCode(err error) string {
+	for _, c := range []struct {
+		err  error
+		code string
+	}{
+		{ErrBlockedAddress, "blocked_address"},
+		{ErrRobotsDisallowed, "robots_disallowed"},
+		{ErrNotFeed, "not_feed"},
+		{ErrTooLarge, "response_too_large"},
+		{ErrTooManyRedirects, "too_many_redirects"},
+		{ErrUnsupportedType, "unsupported_content_type"},
+		{ErrTimeBudget, "time_budget_exceeded"},
+		{ErrNotCached, "not_cached"},
+		{ErrInvalidRule, "invalid_rule"},
+	} {
+		if errors.Is(err, c.err) {
+			return c.code
+		}
+	}
+	return ""
+}
 
-	// 访问URL
-	err := s.collector.Visit(url)
+// normalizeURL 校验URL非空，没有协议时补全为 https
+func normalizeURL(url string) (string, error) {
+	if url == "" {
+		logger.GetLogger().Errorw("URL不能为空")
+		return "", errors.New("URL不能为空")
+	}
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		url = "https://" + url
+		logger.GetLogger().Infow("自动补全URL为https", "url", url)
+	}
+	return url, nil
+}
+
+// normalizeFormat 校验输出格式，空值表示纯文本
+func normalizeFormat(format string) (string, error) {
+	format = strings.ToLower(format)
+	switch format {
+	case "":
+		return FormatText, nil
+	case FormatText, FormatMarkdown, FormatHTML:
+		return format, nil
+	default:
+		return "", fmt.Errorf("不支持的输出格式: %s", format)
+	}
+}
+
+// scrape 抓取并提取单个页面，同时返回解析后的页面供抓取链接使用
+func (s *Scraper) scrape(ctx context.Context, url, format string) (*ScrapedContent, *fetchedPage, error) {
+	return s.scrapeConditional(ctx, url, format, nil)
+}
+
+// scrapeConditional 与 scrape 相同，conditional 为重新验证缓存的条件请求头，
+// 服务端返回 304 时返回 errNotModified
+func (s *Scraper) scrapeConditional(ctx context.Context, url, format string, conditional http.Header) (*ScrapedContent, *fetchedPage, error) {
+	log := logger.GetLogger()
+	log.Infow("开始抓取URL", "url", url)
+	url, err := normalizeURL(url)
 	if err != nil {
-		return nil, fmt.Errorf("访问URL失败: %w", err)
+		return nil, nil, err
 	}
 
-	// 等待抓取完成
-	s.collector.Wait()
+	content := &ScrapedContent{
+		URL:    url,
+		Format: format,
+	}
 
-	if scrapeErr != nil {
-		return nil, scrapeErr
+	page, err := s.fetch(ctx, url, conditional)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	// 合并所有文本部分
-	content.Content = strings.Join(textParts, "\n\n")
+	content.FinalURL = page.finalURL.String()
+	content.Encoding = page.encoding
+	if page.document != nil {
+		content.Title = page.document.title
+		content.Content = page.document.render(format)
+		content.Metadata = page.document.metadata
+	} else {
+		content.Metadata = extractMetadata(page.doc, page.base)
+		content.Title, content.Content, _ = s.extractHTML(page, content.Metadata, format, s.rules.match(page.finalURL))
+	}
+	log.Infow("抓取到网页标题", "title", content.Title)
 
 	// 如果内容为空，返回错误
 	if content.Content == "" {
-		return nil, errors.New("无法提取网页内容")
+		return nil, page, errors.New("无法提取网页内容")
 	}
 
-	return content, nil
+	return content, page, nil
 }
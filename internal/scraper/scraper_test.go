@@ -0,0 +1,69 @@
+package scraper
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/eust-w/urlreader/config"
+)
+
+// TestScrapeURLConcurrent 并发抓取不同页面，验证各次抓取的结果互不串扰。
+// 建议使用 go test -race 运行。
+func TestScrapeURLConcurrent(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/page/")
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprintf(w, `<html><head><title>Page %s</title></head><body>
+<nav><a href="/">Home</a></nav>
+<article>
+<h1>Heading %s</h1>
+<p>This is the body of page %s, written long enough to be picked up as main content.</p>
+<p>Second paragraph of page %s, with some commas, to make the scoring stable.</p>
+</article>
+</body></html>`, id, id, id, id)
+	}))
+	defer srv.Close()
+
+	s := NewScraper(&config.Config{})
+
+	const workers = 64
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id := fmt.Sprintf("%03d", i)
+			content, err := s.ScrapeURL(srv.URL + "/page/" + id)
+			if err != nil {
+				errs <- fmt.Errorf("page %s: %w", id, err)
+				return
+			}
+			if content.Title != "Page "+id {
+				errs <- fmt.Errorf("page %s: got title %q", id, content.Title)
+				return
+			}
+			if got := strings.Count(content.Content, "page "+id); got != 2 {
+				errs <- fmt.Errorf("page %s: expected 2 paragraphs of its own, got %d in %q", id, got, content.Content)
+				return
+			}
+			for j := 0; j < workers; j++ {
+				other := fmt.Sprintf("%03d", j)
+				if other != id && strings.Contains(content.Content, "page "+other) {
+					errs <- fmt.Errorf("page %s: content leaked from page %s", id, other)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,73 @@
+package scraper
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// extractBySelectors 按固定选择器依次收集段落、标题、列表、表格和常见正文容器的文本。
+// 作为正文识别失败时的兜底策略，结果可能包含重复内容和页面模板文本。
+func extractBySelectors(body *goquery.Selection) []string {
+	var textParts []string
+
+	// 提取段落
+	body.Find("p").Each(func(_ int, el *goquery.Selection) {
+		text := strings.TrimSpace(el.Text())
+		if text != "" {
+			textParts = append(textParts, text)
+		}
+	})
+
+	// 提取标题
+	body.Find("h1, h2, h3, h4, h5, h6").Each(func(_ int, el *goquery.Selection) {
+		text := strings.TrimSpace(el.Text())
+		if text != "" {
+			textParts = append(textParts, fmt.Sprintf("[%s] %s", goquery.NodeName(el), text))
+		}
+	})
+
+	// 提取列表
+	body.Find("li").Each(func(_ int, el *goquery.Selection) {
+		text := strings.TrimSpace(el.Text())
+		if text != "" {
+			textParts = append(textParts, "- "+text)
+		}
+	})
+
+	// 提取表格内容
+	body.Find("table").Each(func(_ int, el *goquery.Selection) {
+		textParts = append(textParts, "[表格]")
+		el.Find("tr").Each(func(_ int, row *goquery.Selection) {
+			var rowTexts []string
+			row.Find("td, th").Each(func(_ int, cell *goquery.Selection) {
+				text := strings.TrimSpace(cell.Text())
+				if text != "" {
+					rowTexts = append(rowTexts, text)
+				}
+			})
+			if len(rowTexts) > 0 {
+				textParts = append(textParts, strings.Join(rowTexts, " | "))
+			}
+		})
+	})
+
+	// 提取文章内容
+	body.Find("article").Each(func(_ int, el *goquery.Selection) {
+		text := strings.TrimSpace(el.Text())
+		if text != "" {
+			textParts = append(textParts, text)
+		}
+	})
+
+	// 提取div内容（可能包含主要内容）
+	body.Find("div.content, div.main, div.article, div#content, div#main, div#article").Each(func(_ int, el *goquery.Selection) {
+		text := strings.TrimSpace(el.Text())
+		if text != "" {
+			textParts = append(textParts, text)
+		}
+	})
+
+	return textParts
+}
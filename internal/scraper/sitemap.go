@@ -0,0 +1,123 @@
+package scraper
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"strings"
+
+	"github.com/eust-w/urlreader/internal/logger"
+)
+
+const (
+	// sitemapMaxBytes 单个 sitemap 解压后的最大字节数（协议规定的上限）
+	sitemapMaxBytes = 50 << 20
+	// sitemapMaxFiles 一次发现最多读取的 sitemap 文件数，包括索引文件
+	sitemapMaxFiles = 20
+	// sitemapMaxURLs 一次发现最多收集的页面数
+	sitemapMaxURLs = 5000
+)
+
+// sitemapDocument 同时描述 urlset 与 sitemapindex 两种 sitemap 文件
+type sitemapDocument struct {
+	XMLName xml.Name
+	URLs    []struct {
+		Loc string `xml:"loc"`
+	} `xml:"url"`
+	Sitemaps []struct {
+		Loc string `xml:"loc"`
+	} `xml:"sitemap"`
+}
+
+// discoverSitemap 从站点的 sitemap 中发现页面。
+// sitemap 地址优先取 robots.txt 中的 Sitemap 声明，没有声明时使用 /sitemap.xml；
+// sitemap 索引文件会被展开，gzip 压缩的文件会自动解压。
+func (s *Scraper) discoverSitemap(ctx context.Context, site *neturl.URL) []*neturl.URL {
+	log := logger.GetLogger()
+
+	queue := append([]string(nil), s.robots(ctx, site).Sitemaps...)
+	if len(queue) == 0 {
+		queue = []string{site.Scheme + "://" + site.Host + "/sitemap.xml"}
+	}
+
+	var pages []*neturl.URL
+	visited := make(map[string]bool)
+	for len(queue) > 0 && len(visited) < sitemapMaxFiles && len(pages) < sitemapMaxURLs {
+		if ctx.Err() != nil {
+			break
+		}
+		loc := queue[0]
+		queue = queue[1:]
+		if visited[loc] {
+			continue
+		}
+		visited[loc] = true
+
+		doc, err := s.fetchSitemap(ctx, loc)
+		if err != nil {
+			log.Warnw("读取 sitemap 失败", "sitemap", loc, "error", err)
+			continue
+		}
+		for _, entry := range doc.Sitemaps {
+			queue = append(queue, strings.TrimSpace(entry.Loc))
+		}
+		for _, entry := range doc.URLs {
+			u, err := neturl.Parse(strings.TrimSpace(entry.Loc))
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+				continue
+			}
+			pages = append(pages, u)
+			if len(pages) >= sitemapMaxURLs {
+				break
+			}
+		}
+	}
+
+	log.Infow("sitemap 发现完成", "site", site.Host, "sitemaps", len(visited), "pages", len(pages))
+	return pages
+}
+
+// fetchSitemap 下载并解析一个 sitemap 文件
+func (s *Scraper) fetchSitemap(ctx context.Context, loc string) (*sitemapDocument, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, loc, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", s.userAgent)
+	req.Header.Set("Accept", "application/xml,text/xml;q=0.9,*/*;q=0.8")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("状态码: %d", resp.StatusCode)
+	}
+
+	// 按内容判断是否为gzip，服务端常把 .xml.gz 标为 application/octet-stream
+	var body io.Reader = bufio.NewReader(resp.Body)
+	if magic, _ := body.(*bufio.Reader).Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, fmt.Errorf("解压失败: %w", err)
+		}
+		defer gz.Close()
+		body = gz
+	}
+
+	var doc sitemapDocument
+	if err := xml.NewDecoder(io.LimitReader(body, sitemapMaxBytes)).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("解析XML失败: %w", err)
+	}
+	if name := doc.XMLName.Local; name != "urlset" && name != "sitemapindex" {
+		return nil, fmt.Errorf("不是 sitemap 文件: <%s>", name)
+	}
+	return &doc, nil
+}
@@ -0,0 +1,170 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/eust-w/urlreader/internal/logger"
+)
+
+// ErrBlockedAddress 表示目标主机解析到回环、内网、链路本地或其他保留地址
+var ErrBlockedAddress = errors.New("禁止访问内网或保留地址")
+
+// blockedNetworks net.IP 的分类方法没有覆盖的保留网段，
+// 包括运营商级 NAT（部分云厂商的元数据服务位于其中）、基准测试与 NAT64 等网段
+var blockedNetworks = parseCIDRs(
+	"0.0.0.0/8", "100.64.0.0/10", "192.0.0.0/24", "192.0.2.0/24", "198.18.0.0/15",
+	"198.51.100.0/24", "203.0.113.0/24", "240.0.0.0/4",
+	"64:ff9b::/96", "64:ff9b:1::/48", "100::/64", "2001:db8::/32",
+)
+
+// addressGuard 在建立连接时检查目标地址。连接直接使用检查过的IP，
+// 因此跳转后的地址与 DNS 重绑定（检查后再次解析得到内网地址）都无法绕过。
+type addressGuard struct {
+	dialer *net.Dialer
+	// hosts 允许访问的主机名，以 "*." 开头的项匹配所有子域名
+	hosts []string
+	// networks 允许访问的IP网段
+	networks []*net.IPNet
+}
+
+// newAddressGuard 按白名单创建地址检查器，白名单项可以是主机名、IP 或 CIDR
+func newAddressGuard(allowlist []string) *addressGuard {
+	g := &addressGuard{dialer: &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}}
+	for _, item := range allowlist {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if _, network, err := net.ParseCIDR(item); err == nil {
+			g.networks = append(g.networks, network)
+		} else if ip := net.ParseIP(item); ip != nil {
+			g.networks = append(g.networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)})
+		} else if item != "" {
+			g.hosts = append(g.hosts, strings.TrimSuffix(item, "."))
+		}
+	}
+	return g
+}
+
+// transport 返回使用地址检查的 HTTP Transport。代理会替我们解析目标主机而绕过检查，因此不使用代理。
+func (g *addressGuard) transport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = nil
+	t.DialContext = g.dialContext
+	return t
+}
+
+// dialContext 解析主机名并逐个检查地址，只连接允许访问的地址
+func (g *addressGuard) dialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if g.allowedHost(host) {
+		return g.dialer.DialContext(ctx, network, addr)
+	}
+
+	ips, err := net.DefaultResolver.LookupIP(ctx, "ip", host)
+	if err != nil {
+		return nil, err
+	}
+	var lastErr error
+	for _, ip := range ips {
+		if !g.allowedIP(ip) {
+			logger.GetLogger().Warnw("拒绝访问内网或保留地址", "host", host, "ip", ip.String())
+			lastErr = fmt.Errorf("%w: %s (%s)", ErrBlockedAddress, host, ip)
+			continue
+		}
+		conn, err := g.dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("无法解析主机: %s", host)
+	}
+	return nil, lastErr
+}
+
+// allowedHost 判断主机名是否在白名单中
+func (g *addressGuard) allowedHost(host string) bool {
+	for _, h := range g.hosts {
+		if matchHost(h, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowedIP 判断地址是否为公网地址或在白名单网段中。
+// 6to4 与 Teredo 地址会被路由到内嵌的IPv4地址，因此内嵌地址也必须允许访问。
+func (g *addressGuard) allowedIP(ip net.IP) bool {
+	for _, network := range g.networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	for _, v4 := range embeddedIPv4(ip) {
+		if !g.allowedIP(v4) {
+			return false
+		}
+	}
+	return !isReservedIP(ip)
+}
+
+// 内嵌IPv4地址的IPv6网段
+var (
+	// sixToFourNetwork 6to4（RFC 3056），第3到第6字节为IPv4地址
+	sixToFourNetwork = parseCIDRs("2002::/16")[0]
+	// teredoNetwork Teredo（RFC 4380），第5到第8字节为服务器地址，最后4字节为按位取反的客户端地址
+	teredoNetwork = parseCIDRs("2001::/32")[0]
+)
+
+// embeddedIPv4 返回 6to4 与 Teredo 地址中内嵌的IPv4地址，其他地址返回 nil
+func embeddedIPv4(ip net.IP) []net.IP {
+	if ip.To4() != nil {
+		return nil
+	}
+	ip = ip.To16()
+	switch {
+	case sixToFourNetwork.Contains(ip):
+		return []net.IP{net.IPv4(ip[2], ip[3], ip[4], ip[5])}
+	case teredoNetwork.Contains(ip):
+		return []net.IP{
+			net.IPv4(ip[4], ip[5], ip[6], ip[7]),
+			net.IPv4(^ip[12], ^ip[13], ^ip[14], ^ip[15]),
+		}
+	}
+	return nil
+}
+
+// isReservedIP 判断是否为回环、内网、链路本地（含 169.254.169.254 元数据地址）、组播或其他保留地址
+func isReservedIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || ip.Equal(net.IPv4bcast) {
+		return true
+	}
+	for _, network := range blockedNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCIDRs 解析固定的网段列表
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, len(cidrs))
+	for i, c := range cidrs {
+		_, network, err := net.ParseCIDR(c)
+		if err != nil {
+			panic(err)
+		}
+		networks[i] = network
+	}
+	return networks
+}
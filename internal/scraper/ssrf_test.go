@@ -0,0 +1,93 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/eust-w/urlreader/config"
+)
+
+func TestIsReservedIP(t *testing.T) {
+	reserved := []string{
+		"127.0.0.1", "10.0.0.8", "172.16.5.4", "192.168.1.1", "169.254.169.254", "100.100.100.200",
+		"0.0.0.0", "::1", "fe80::1", "fd00:ec2::254", "::ffff:127.0.0.1", "64:ff9b::a9fe:a9fe",
+	}
+	for _, s := range reserved {
+		if !isReservedIP(net.ParseIP(s)) {
+			t.Errorf("%s should be reserved", s)
+		}
+	}
+	for _, s := range []string{"8.8.8.8", "93.184.216.34", "2606:4700::1111"} {
+		if isReservedIP(net.ParseIP(s)) {
+			t.Errorf("%s should be public", s)
+		}
+	}
+}
+
+func TestAllowedIPEmbeddedIPv4(t *testing.T) {
+	guard := newAddressGuard(nil)
+	blocked := []string{
+		"2002:7f00:1::",               // 6to4 127.0.0.1
+		"2002:a9fe:a9fe::1",           // 6to4 169.254.169.254
+		"2001:0:a00:1::808:808",       // Teredo 服务器 10.0.0.1
+		"2001:0:808:808::80ff:fefe",   // Teredo 客户端 127.0.1.1
+		"2001:0:4136:e378::3f57:fefe", // Teredo 客户端 192.168.1.1
+	}
+	for _, s := range blocked {
+		if guard.allowedIP(net.ParseIP(s)) {
+			t.Errorf("%s should be blocked", s)
+		}
+	}
+	// 内嵌公网地址的 6to4 与 Teredo 地址可以访问
+	for _, s := range []string{"2002:808:808::1", "2001:0:808:808::f7f7:f7f7"} {
+		if !guard.allowedIP(net.ParseIP(s)) {
+			t.Errorf("%s should be allowed", s)
+		}
+	}
+	// 白名单中的IPv4网段同样适用于内嵌地址
+	if !newAddressGuard([]string{"10.0.0.0/8"}).allowedIP(net.ParseIP("2002:a00:1::")) {
+		t.Error("6to4 address embedding an allowlisted network should be allowed")
+	}
+}
+
+func TestScrapeBlocksPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			// 跳转到回环地址的IP形式，白名单中的主机名不能放行
+			http.Redirect(w, r, "http://"+strings.Replace(r.Host, "localhost", "127.0.0.1", 1)+"/page", http.StatusFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, "<html><head><title>Internal</title></head><body><p>Internal page content.</p></body></html>")
+	}))
+	defer srv.Close()
+	port := srv.URL[strings.LastIndex(srv.URL, ":")+1:]
+	ctx := context.Background()
+
+	blocked := NewScraper(&config.Config{SSRFProtection: true})
+	if _, err := blocked.ScrapeURL(srv.URL + "/page"); !errors.Is(err, ErrBlockedAddress) {
+		t.Errorf("loopback scrape err = %v, want ErrBlockedAddress", err)
+	}
+	if _, err := blocked.Crawl(ctx, srv.URL+"/page", CrawlOptions{}); !errors.Is(err, ErrBlockedAddress) {
+		t.Errorf("loopback crawl err = %v, want ErrBlockedAddress", err)
+	}
+
+	byHost := NewScraper(&config.Config{SSRFProtection: true, SSRFAllowlist: []string{"localhost"}})
+	if content, err := byHost.ScrapeURL("http://localhost:" + port + "/page"); err != nil || content.Title != "Internal" {
+		t.Errorf("allowlisted host: content = %+v, err = %v", content, err)
+	}
+	if _, err := byHost.ScrapeURL("http://localhost:" + port + "/redirect"); !errors.Is(err, ErrBlockedAddress) {
+		t.Errorf("redirect err = %v, want ErrBlockedAddress", err)
+	}
+
+	byNetwork := NewScraper(&config.Config{SSRFProtection: true, SSRFAllowlist: []string{"127.0.0.0/8"}})
+	if _, err := byNetwork.ScrapeURL(srv.URL + "/page"); err != nil {
+		t.Errorf("allowlisted network err = %v", err)
+	}
+}
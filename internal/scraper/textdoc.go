@@ -0,0 +1,314 @@
+package scraper
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// csvMaxRows CSV 最多渲染的行数（含表头），超出部分只报告总行数
+const csvMaxRows = 1000
+
+var (
+	// markdownHeading 匹配 ATX 风格的 Markdown 标题
+	markdownHeading = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	// markdownFence 匹配围栏代码块的起止行
+	markdownFence = regexp.MustCompile("^\\s*(```+|~~~+)")
+	// blankLines 匹配分隔段落的空行
+	blankLines = regexp.MustCompile(`\n\s*\n`)
+)
+
+// parseCSV 把 CSV/TSV 渲染为表格，分隔符按第一行中出现最多的候选字符判断
+func parseCSV(data []byte) (*document, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comma = sniffDelimiter(data)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.TrimLeadingSpace = true
+
+	var rows [][]string
+	total := 0
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("解析CSV失败: %w", err)
+		}
+		total++
+		if len(rows) < csvMaxRows {
+			rows = append(rows, record)
+		}
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("CSV 文件为空")
+	}
+
+	doc := &document{}
+	doc.table(rows)
+	if total > len(rows) {
+		doc.paragraph(fmt.Sprintf("（仅显示前 %d 行，共 %d 行）", len(rows), total))
+	}
+	return doc, nil
+}
+
+// sniffDelimiter 根据第一行判断 CSV 的分隔符，默认为逗号
+func sniffDelimiter(data []byte) rune {
+	line, _, _ := bufio.NewReader(bytes.NewReader(data)).ReadLine()
+	best, count := ',', 0
+	for _, c := range []rune{',', '\t', ';', '|'} {
+		if n := bytes.Count(line, []byte(string(c))); n > count {
+			best, count = c, n
+		}
+	}
+	return best
+}
+
+// jsonField 保持键顺序的 JSON 对象字段
+type jsonField struct {
+	key   string
+	value any
+}
+
+// parseJSON 把 JSON 渲染为大纲：对象字段与数组元素作为嵌套列表项，
+// 元素全是只含标量字段的对象的数组渲染为表格。
+func parseJSON(data []byte) (*document, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	value, err := decodeOrderedJSON(dec)
+	if err != nil {
+		return nil, fmt.Errorf("解析JSON失败: %w", err)
+	}
+
+	doc := &document{}
+	if isJSONScalar(value) {
+		doc.paragraph(jsonScalar(value))
+	} else {
+		renderJSON(doc, "", value, 0)
+	}
+	return doc, nil
+}
+
+// decodeOrderedJSON 解码一个 JSON 值，对象解码为按原顺序排列的 []jsonField
+func decodeOrderedJSON(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		return tok, nil
+	}
+
+	switch delim {
+	case '{':
+		fields := []jsonField{}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeOrderedJSON(dec)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, jsonField{key: fmt.Sprint(key), value: value})
+		}
+		_, err = dec.Token()
+		return fields, err
+	case '[':
+		items := []any{}
+		for dec.More() {
+			value, err := decodeOrderedJSON(dec)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, value)
+		}
+		_, err = dec.Token()
+		return items, err
+	}
+	return nil, fmt.Errorf("意外的分隔符 %v", delim)
+}
+
+// renderJSON 把 JSON 值渲染为列表项，key 为空表示数组元素或顶层值
+func renderJSON(doc *document, key string, value any, depth int) {
+	label := func(s string) string {
+		if key == "" {
+			return s
+		}
+		if s == "" {
+			return key + ":"
+		}
+		return key + ": " + s
+	}
+
+	switch v := value.(type) {
+	case []jsonField:
+		if key != "" {
+			doc.listItem(depth, label(""))
+			depth++
+		}
+		if len(v) == 0 {
+			doc.listItem(depth, "{}")
+		}
+		for _, f := range v {
+			renderJSON(doc, f.key, f.value, depth)
+		}
+	case []any:
+		if rows := jsonTable(v); rows != nil {
+			if key != "" {
+				doc.listItem(depth, label(""))
+			}
+			doc.table(rows)
+			return
+		}
+		if key != "" {
+			doc.listItem(depth, label(""))
+			depth++
+		}
+		if len(v) == 0 {
+			doc.listItem(depth, "[]")
+		}
+		for i, item := range v {
+			if isJSONScalar(item) {
+				doc.listItem(depth, jsonScalar(item))
+				continue
+			}
+			renderJSON(doc, fmt.Sprintf("[%d]", i+1), item, depth)
+		}
+	default:
+		doc.listItem(depth, label(jsonScalar(v)))
+	}
+}
+
+// jsonTable 数组元素全是只含标量字段的对象时返回表格行，列按字段首次出现的顺序排列
+func jsonTable(items []any) [][]string {
+	if len(items) == 0 {
+		return nil
+	}
+	var columns []string
+	index := make(map[string]int)
+	for _, item := range items {
+		fields, ok := item.([]jsonField)
+		if !ok || len(fields) == 0 {
+			return nil
+		}
+		for _, f := range fields {
+			if !isJSONScalar(f.value) {
+				return nil
+			}
+			if _, ok := index[f.key]; !ok {
+				index[f.key] = len(columns)
+				columns = append(columns, f.key)
+			}
+		}
+	}
+
+	rows := [][]string{columns}
+	for _, item := range items {
+		row := make([]string, len(columns))
+		for _, f := range item.([]jsonField) {
+			row[index[f.key]] = jsonScalar(f.value)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// isJSONScalar 判断是否为字符串、数字、布尔值或 null
+func isJSONScalar(value any) bool {
+	switch value.(type) {
+	case []jsonField, []any:
+		return false
+	}
+	return true
+}
+
+// jsonScalar 把标量渲染为文本
+func jsonScalar(value any) string {
+	if value == nil {
+		return "null"
+	}
+	return fmt.Sprint(value)
+}
+
+// parseMarkdown 识别 Markdown 的标题与围栏代码块，其余内容按空行分段并保留原文。
+// 第一个一级标题作为文档标题，开头的 YAML front matter 被跳过。
+func parseMarkdown(data []byte) (*document, error) {
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	if len(lines) > 0 && strings.TrimSpace(lines[0]) == "---" {
+		for i := 1; i < len(lines); i++ {
+			if t := strings.TrimSpace(lines[i]); t == "---" || t == "..." {
+				lines = lines[i+1:]
+				break
+			}
+		}
+	}
+
+	doc := &document{}
+	var paragraph, code []string
+	fence := ""
+	flush := func() {
+		doc.paragraph(strings.Join(paragraph, "\n"))
+		paragraph = nil
+	}
+	for _, line := range lines {
+		if fence != "" {
+			if m := markdownFence.FindStringSubmatch(line); m != nil && strings.HasPrefix(m[1], fence) {
+				doc.code(strings.Join(code, "\n"))
+				code, fence = nil, ""
+				continue
+			}
+			code = append(code, line)
+			continue
+		}
+		if m := markdownFence.FindStringSubmatch(line); m != nil {
+			flush()
+			fence = m[1]
+			continue
+		}
+		if m := markdownHeading.FindStringSubmatch(line); m != nil {
+			flush()
+			if len(m[1]) == 1 && doc.title == "" {
+				doc.title = m[2]
+			}
+			doc.heading(len(m[1]), m[2])
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			flush()
+			continue
+		}
+		paragraph = append(paragraph, line)
+	}
+	flush()
+	if fence != "" {
+		doc.code(strings.Join(code, "\n"))
+	}
+	return doc, nil
+}
+
+// parsePlainText 按空行分段，段内换行保留；内容实际是 JSON 时按 JSON 渲染
+func parsePlainText(data []byte) (*document, error) {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid(trimmed) {
+		return parseJSON(trimmed)
+	}
+
+	doc := &document{}
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	for _, p := range blankLines.Split(text, -1) {
+		doc.paragraph(strings.TrimRight(p, " \t"))
+	}
+	if len(doc.blocks) == 0 {
+		return nil, errors.New("文本文件为空")
+	}
+	return doc, nil
+}
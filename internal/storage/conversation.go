@@ -1,23 +1,91 @@
 package storage
 
 import (
+	"fmt"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/eust-w/urlreader/internal/llm"
+	"github.com/eust-w/urlreader/internal/models"
+	"github.com/eust-w/urlreader/internal/rag"
 )
 
 // Conversation 表示一个对话会话
 type Conversation struct {
-	ID        string        `json:"id"`
-	URL       string        `json:"url"`
-	Content   string        `json:"content"`
-	Messages  []llm.Message `json:"messages"`
-	CreatedAt time.Time     `json:"created_at"`
-	UpdatedAt time.Time     `json:"updated_at"`
+	ID string `json:"id"`
+	// Sources 会话引用的网页，按添加顺序排列
+	Sources  []Source      `json:"sources"`
+	Messages []llm.Message `json:"messages"`
+	// Chunks 所有来源切分后的片段及其向量，片段编号在会话内唯一
+	Chunks    []rag.Chunk `json:"chunks,omitempty"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+
+	// 下一个来源与片段的编号，移除来源后编号不会复用，历史回答中的引用始终指向原来的片段
+	nextSourceID int
+	nextChunkID  int
+}
+
+// Source 表示会话引用的一个网页
+type Source struct {
+	// ID 来源编号，从1开始，移除来源后不会复用
+	ID      int    `json:"id"`
+	URL     string `json:"url"`
+	Title   string `json:"title"`
+	Content string `json:"content"`
+	// Metadata 抓取时提取的网页元数据，没有时为 nil
+	Metadata *models.Metadata `json:"metadata,omitempty"`
+	AddedAt  time.Time        `json:"added_at"`
+}
+
+// Store 定义对话会话存储需要实现的方法
+// 读取方法的 bool 表示会话是否存在，error 表示存储本身出错（如数据库被锁定或损坏）。
+type Store interface {
+	Get(id string) (*Conversation, bool, error)
+	// Create 以第一个来源及其片段创建会话
+	Create(id string, source Source, chunks []rag.Chunk) *Conversation
+	// AddSource 向会话添加来源，为来源和片段分配新的编号并返回保存后的来源
+	AddSource(id string, source Source, chunks []rag.Chunk) (*Source, bool)
+	// RemoveSource 移除来源及其片段
+	RemoveSource(id string, sourceID int) bool
+	AddMessage(id string, message llm.Message) bool
+	GetMessages(id string) ([]llm.Message, bool, error)
+	Delete(id string) bool
+	ListIDs() []string
+	CleanupOldConversations(maxAge time.Duration) int
+}
+
+// numberChunks 把片段归入指定来源，并从 firstID 开始重新编号
+func numberChunks(chunks []rag.Chunk, sourceID, firstID int) []rag.Chunk {
+	numbered := make([]rag.Chunk, len(chunks))
+	for i, c := range chunks {
+		c.ID = firstID + i
+		c.Source = sourceID
+		numbered[i] = c
+	}
+	return numbered
 }
 
-// ConversationStore 管理对话会话
+// 存储后端名称
+const (
+	BackendMemory = "memory"
+	BackendSQLite = "sqlite"
+)
+
+// NewStore 根据后端名称创建对话存储，默认使用内存存储
+func NewStore(backend, path string) (Store, error) {
+	switch strings.ToLower(backend) {
+	case "", BackendMemory:
+		return NewConversationStore(), nil
+	case BackendSQLite:
+		return NewSQLiteStore(path)
+	default:
+		return nil, fmt.Errorf("不支持的存储后端: %s", backend)
+	}
+}
+
+// ConversationStore 在进程内存中管理对话会话，重启后数据丢失
 type ConversationStore struct {
 	conversations map[string]*Conversation
 	mu            sync.RWMutex
@@ -41,32 +109,97 @@ func NewConversationStore() *ConversationStore {
 	}
 }
 
-// Get 获取指定ID的对话
-func (s *ConversationStore) Get(id string) (*Conversation, bool) {
+// Get 获取指定ID的对话，返回副本以避免并发修改
+func (s *ConversationStore) Get(id string) (*Conversation, bool, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	conv, exists := s.conversations[id]
-	return conv, exists
+	if !exists {
+		return nil, false, nil
+	}
+
+	copied := *conv
+	copied.Sources = append([]Source(nil), conv.Sources...)
+	copied.Messages = append([]llm.Message(nil), conv.Messages...)
+	copied.Chunks = append([]rag.Chunk(nil), conv.Chunks...)
+	return &copied, true, nil
 }
 
 // Create 创建一个新的对话
-func (s *ConversationStore) Create(id, url, content string) *Conversation {
+func (s *ConversationStore) Create(id string, source Source, chunks []rag.Chunk) *Conversation {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	now := time.Now()
+	source.ID = 1
+	source.AddedAt = now
 	conv := &Conversation{
-		ID:        id,
-		URL:       url,
-		Content:   content,
-		Messages:  []llm.Message{},
-		CreatedAt: now,
-		UpdatedAt: now,
+		ID:           id,
+		Sources:      []Source{source},
+		Messages:     []llm.Message{},
+		Chunks:       numberChunks(chunks, source.ID, 1),
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		nextSourceID: source.ID + 1,
+		nextChunkID:  len(chunks) + 1,
 	}
 
 	s.conversations[id] = conv
-	return conv
+	copied := *conv
+	return &copied
+}
+
+// AddSource 向会话添加来源
+func (s *ConversationStore) AddSource(id string, source Source, chunks []rag.Chunk) (*Source, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	conv, exists := s.conversations[id]
+	if !exists {
+		return nil, false
+	}
+
+	source.ID = conv.nextSourceID
+	source.AddedAt = time.Now()
+	conv.Sources = append(conv.Sources, source)
+	conv.Chunks = append(conv.Chunks, numberChunks(chunks, source.ID, conv.nextChunkID)...)
+	conv.nextSourceID++
+	conv.nextChunkID += len(chunks)
+	conv.UpdatedAt = source.AddedAt
+	return &source, true
+}
+
+// RemoveSource 移除来源及其片段
+func (s *ConversationStore) RemoveSource(id string, sourceID int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	conv, exists := s.conversations[id]
+	if !exists {
+		return false
+	}
+
+	var sources []Source
+	for _, src := range conv.Sources {
+		if src.ID != sourceID {
+			sources = append(sources, src)
+		}
+	}
+	if len(sources) == len(conv.Sources) {
+		return false
+	}
+	var chunks []rag.Chunk
+	for _, c := range conv.Chunks {
+		if c.Source != sourceID {
+			chunks = append(chunks, c)
+		}
+	}
+
+	conv.Sources = sources
+	conv.Chunks = chunks
+	conv.UpdatedAt = time.Now()
+	return true
 }
 
 // AddMessage 向对话添加一条消息
@@ -85,20 +218,20 @@ func (s *ConversationStore) AddMessage(id string, message llm.Message) bool {
 }
 
 // GetMessages 获取对话的所有消息
-func (s *ConversationStore) GetMessages(id string) ([]llm.Message, bool) {
+func (s *ConversationStore) GetMessages(id string) ([]llm.Message, bool, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	conv, exists := s.conversations[id]
 	if !exists {
-		return nil, false
+		return nil, false, nil
 	}
 
 	// 返回消息的副本以避免并发修改
 	messages := make([]llm.Message, len(conv.Messages))
 	copy(messages, conv.Messages)
 
-	return messages, true
+	return messages, true, nil
 }
 
 // Delete 删除指定ID的对话
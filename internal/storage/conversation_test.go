@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/eust-w/urlreader/internal/rag"
+)
+
+// chunkIDs 返回片段的编号与所属来源
+func chunkIDs(chunks []rag.Chunk) [][2]int {
+	ids := make([][2]int, len(chunks))
+	for i, c := range chunks {
+		ids[i] = [2]int{c.ID, c.Source}
+	}
+	return ids
+}
+
+// sourceIDs 返回会话中来源的编号
+func sourceIDs(sources []Source) []int {
+	ids := make([]int, len(sources))
+	for i, s := range sources {
+		ids[i] = s.ID
+	}
+	return ids
+}
+
+// testSources 检查来源的添加与移除。reopen 非 nil 时在中途重新打开存储，检查编号在重启后延续。
+func testSources(t *testing.T, store Store, reopen func() Store) {
+	t.Helper()
+	chunks := func(texts ...string) []rag.Chunk {
+		var result []rag.Chunk
+		for _, text := range texts {
+			// 调用方传入的编号会被存储重新分配
+			result = append(result, rag.Chunk{ID: 99, Source: 99, Text: text})
+		}
+		return result
+	}
+
+	store.Create("c", Source{URL: "https://example.com/a", Title: "A"}, chunks("a1", "a2"))
+	added, ok := store.AddSource("c", Source{ID: 7, URL: "https://example.com/b", Title: "B"}, chunks("b1", "b2", "b3"))
+	if !ok || added.ID != 2 || added.AddedAt.IsZero() {
+		t.Fatalf("AddSource = %+v, %v", added, ok)
+	}
+	if _, ok := store.AddSource("missing", Source{URL: "https://example.com/x"}, nil); ok {
+		t.Error("AddSource to a missing conversation succeeded")
+	}
+
+	conv, _, _ := store.Get("c")
+	if ids := sourceIDs(conv.Sources); !reflect.DeepEqual(ids, []int{1, 2}) {
+		t.Errorf("sources = %v", ids)
+	}
+	if ids := chunkIDs(conv.Chunks); !reflect.DeepEqual(ids, [][2]int{{1, 1}, {2, 1}, {3, 2}, {4, 2}, {5, 2}}) {
+		t.Errorf("chunks = %v", ids)
+	}
+
+	// 移除来源同时移除其片段，剩余片段保留原编号，历史回答中的引用仍然有效
+	if !store.RemoveSource("c", 1) {
+		t.Fatal("RemoveSource(1) failed")
+	}
+	if store.RemoveSource("c", 1) || store.RemoveSource("c", 42) || store.RemoveSource("missing", 2) {
+		t.Error("removing a missing source succeeded")
+	}
+	if reopen != nil {
+		store = reopen()
+	}
+	conv, _, _ = store.Get("c")
+	if ids := sourceIDs(conv.Sources); !reflect.DeepEqual(ids, []int{2}) || conv.Sources[0].Title != "B" {
+		t.Errorf("after remove: sources = %+v", conv.Sources)
+	}
+	if ids := chunkIDs(conv.Chunks); !reflect.DeepEqual(ids, [][2]int{{3, 2}, {4, 2}, {5, 2}}) || conv.Chunks[0].Text != "b1" {
+		t.Errorf("after remove: chunks = %v", ids)
+	}
+
+	// 来源与片段编号不复用
+	added, ok = store.AddSource("c", Source{URL: "https://example.com/a", Title: "A again"}, chunks("a1"))
+	if !ok || added.ID != 3 {
+		t.Fatalf("AddSource after remove = %+v, %v", added, ok)
+	}
+	conv, _, _ = store.Get("c")
+	if ids := chunkIDs(conv.Chunks); !reflect.DeepEqual(ids, [][2]int{{3, 2}, {4, 2}, {5, 2}, {6, 3}}) {
+		t.Errorf("after re-adding: chunks = %v", ids)
+	}
+
+	// 存储层允许移除最后一个来源，至少保留一个来源由接口层检查
+	if !store.RemoveSource("c", 2) || !store.RemoveSource("c", 3) {
+		t.Error("removing the remaining sources failed")
+	}
+	if conv, ok, err := store.Get("c"); err != nil || !ok || len(conv.Sources) != 0 || len(conv.Chunks) != 0 {
+		t.Errorf("after removing all sources: %+v, %v", conv, ok)
+	}
+}
+
+func TestMemoryStoreSources(t *testing.T) {
+	testSources(t, NewConversationStore(), nil)
+}
+
+func TestSQLiteStoreSources(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "urlreader.db")
+	store, err := NewSQLiteStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	testSources(t, store, func() Store {
+		store.Close()
+		if store, err = NewSQLiteStore(path); err != nil {
+			t.Fatal(err)
+		}
+		return store
+	})
+}
@@ -0,0 +1,555 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/eust-w/urlreader/internal/llm"
+	"github.com/eust-w/urlreader/internal/logger"
+	"github.com/eust-w/urlreader/internal/rag"
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteStore 基于SQLite文件持久化对话会话，多个实例可共享同一个数据库文件
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS conversations (
+	id             TEXT PRIMARY KEY,
+	url            TEXT NOT NULL,
+	content        TEXT NOT NULL,
+	created_at     INTEGER NOT NULL,
+	updated_at     INTEGER NOT NULL,
+	next_source_id INTEGER NOT NULL DEFAULT 2,
+	next_chunk_id  INTEGER NOT NULL DEFAULT 0
+);
+CREATE TABLE IF NOT EXISTS sources (
+	conversation_id TEXT NOT NULL,
+	source_id       INTEGER NOT NULL,
+	url             TEXT NOT NULL,
+	title           TEXT NOT NULL,
+	content         TEXT NOT NULL,
+	added_at        INTEGER NOT NULL,
+	metadata        TEXT NOT NULL DEFAULT '',
+	PRIMARY KEY (conversation_id, source_id)
+);
+CREATE TABLE IF NOT EXISTS messages (
+	seq             INTEGER PRIMARY KEY AUTOINCREMENT,
+	conversation_id TEXT NOT NULL,
+	role            TEXT NOT NULL,
+	content         TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS chunks (
+	conversation_id TEXT NOT NULL,
+	chunk_id        INTEGER NOT NULL,
+	source_id       INTEGER NOT NULL DEFAULT 1,
+	heading         TEXT NOT NULL,
+	content         TEXT NOT NULL,
+	vector          BLOB,
+	PRIMARY KEY (conversation_id, chunk_id)
+);
+CREATE INDEX IF NOT EXISTS idx_messages_conversation ON messages(conversation_id, seq);
+CREATE INDEX IF NOT EXISTS idx_conversations_updated ON conversations(updated_at);
+`
+
+// sqliteMigrations 旧版本数据库缺少的列。
+// 早期会话只有 conversations 表中的 url/content，读取时视为编号为1的来源。
+var sqliteMigrations = []struct {
+	table, column, definition string
+}{
+	{"conversations", "next_source_id", "INTEGER NOT NULL DEFAULT 2"},
+	{"conversations", "next_chunk_id", "INTEGER NOT NULL DEFAULT 0"},
+	{"chunks", "source_id", "INTEGER NOT NULL DEFAULT 1"},
+	{"sources", "metadata", "TEXT NOT NULL DEFAULT ''"},
+}
+
+// migrate 为旧版本数据库补充缺少的列
+func migrate(db *sql.DB) error {
+	for _, m := range sqliteMigrations {
+		var n int
+		if err := db.QueryRow(`SELECT COUNT(1) FROM pragma_table_info(?) WHERE name = ?`, m.table, m.column).Scan(&n); err != nil {
+			return err
+		}
+		if n > 0 {
+			continue
+		}
+		if _, err := db.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, m.table, m.column, m.definition)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NewSQLiteStore 打开（必要时创建）指定路径的SQLite数据库
+func NewSQLiteStore(path string) (*SQLiteStore, error) {
+	if path == "" {
+		return nil, fmt.Errorf("SQLite数据库路径未配置")
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("创建数据库目录失败: %w", err)
+		}
+	}
+
+	// WAL模式和忙等待超时让多个进程可以安全地共享同一个数据库文件
+	dsn := fmt.Sprintf("file:%s?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)", path)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("打开数据库失败: %w", err)
+	}
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("初始化数据库失败: %w", err)
+	}
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("升级数据库失败: %w", err)
+	}
+
+	logger.GetLogger().Infow("SQLite 会话存储初始化完成", "path", path)
+	return &SQLiteStore{db: db}, nil
+}
+
+// Close 关闭数据库连接
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// ListIDs 返回所有会话ID
+func (s *SQLiteStore) ListIDs() []string {
+	rows, err := s.db.Query(`SELECT id FROM conversations ORDER BY created_at`)
+	if err != nil {
+		logger.GetLogger().Errorw("查询会话ID失败", "error", err)
+		return []string{}
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			logger.GetLogger().Errorw("读取会话ID失败", "error", err)
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Get 获取指定ID的对话
+func (s *SQLiteStore) Get(id string) (*Conversation, bool, error) {
+	var conv Conversation
+	var url, content string
+	var createdAt, updatedAt int64
+	err := s.db.QueryRow(`SELECT id, url, content, created_at, updated_at FROM conversations WHERE id = ?`, id).
+		Scan(&conv.ID, &url, &content, &createdAt, &updatedAt)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("查询会话失败: %w", err)
+	}
+	conv.CreatedAt = time.Unix(0, createdAt)
+	conv.UpdatedAt = time.Unix(0, updatedAt)
+
+	messages, ok, err := s.GetMessages(id)
+	if !ok || err != nil {
+		return nil, false, err
+	}
+	conv.Messages = messages
+
+	sources, err := s.getSources(id)
+	if err != nil {
+		return nil, false, fmt.Errorf("查询会话来源失败: %w", err)
+	}
+	// 只有旧版本数据在 conversations 表中保存了内容，新会话的 url 列只是第一个来源的记录
+	if len(sources) == 0 && content != "" {
+		sources = []Source{{ID: 1, URL: url, Content: content, AddedAt: conv.CreatedAt}}
+	}
+	conv.Sources = sources
+
+	chunks, err := s.getChunks(id)
+	if err != nil {
+		return nil, false, fmt.Errorf("查询会话片段失败: %w", err)
+	}
+	conv.Chunks = chunks
+	return &conv, true, nil
+}
+
+// getSources 按编号顺序读取对话的来源
+func (s *SQLiteStore) getSources(id string) ([]Source, error) {
+	rows, err := s.db.Query(`SELECT source_id, url, title, content, added_at, metadata FROM sources WHERE conversation_id = ? ORDER BY source_id`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sources []Source
+	for rows.Next() {
+		var src Source
+		var addedAt int64
+		var metadata string
+		if err := rows.Scan(&src.ID, &src.URL, &src.Title, &src.Content, &addedAt, &metadata); err != nil {
+			return nil, err
+		}
+		src.AddedAt = time.Unix(0, addedAt)
+		if metadata != "" {
+			if err := json.Unmarshal([]byte(metadata), &src.Metadata); err != nil {
+				return nil, err
+			}
+		}
+		sources = append(sources, src)
+	}
+	return sources, rows.Err()
+}
+
+// getChunks 按编号顺序读取对话的片段
+func (s *SQLiteStore) getChunks(id string) ([]rag.Chunk, error) {
+	rows, err := s.db.Query(`SELECT chunk_id, source_id, heading, content, vector FROM chunks WHERE conversation_id = ? ORDER BY chunk_id`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var chunks []rag.Chunk
+	for rows.Next() {
+		var chunk rag.Chunk
+		var heading string
+		var vector []byte
+		if err := rows.Scan(&chunk.ID, &chunk.Source, &heading, &chunk.Text, &vector); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(heading), &chunk.Heading); err != nil {
+			return nil, err
+		}
+		chunk.Vector = decodeVector(vector)
+		chunks = append(chunks, chunk)
+	}
+	return chunks, rows.Err()
+}
+
+// insertSource 在事务中保存来源及其片段
+func insertSource(tx *sql.Tx, id string, source Source, chunks []rag.Chunk) error {
+	// 没有元数据时保存空字符串，与旧版本数据库补充的列一致
+	var metadata []byte
+	if source.Metadata != nil {
+		metadata, _ = json.Marshal(source.Metadata)
+	}
+	if _, err := tx.Exec(`INSERT INTO sources (conversation_id, source_id, url, title, content, added_at, metadata) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		id, source.ID, source.URL, source.Title, source.Content, source.AddedAt.UnixNano(), string(metadata)); err != nil {
+		return fmt.Errorf("保存来源失败: %w", err)
+	}
+	for _, chunk := range chunks {
+		heading, _ := json.Marshal(chunk.Heading)
+		if _, err := tx.Exec(`INSERT INTO chunks (conversation_id, chunk_id, source_id, heading, content, vector) VALUES (?, ?, ?, ?, ?, ?)`,
+			id, chunk.ID, chunk.Source, string(heading), chunk.Text, encodeVector(chunk.Vector)); err != nil {
+			return fmt.Errorf("保存片段失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// encodeVector 把向量编码为小端序float32字节
+func encodeVector(vec []float32) []byte {
+	if len(vec) == 0 {
+		return nil
+	}
+	buf := make([]byte, 4*len(vec))
+	for i, v := range vec {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(v))
+	}
+	return buf
+}
+
+// decodeVector 解码 encodeVector 生成的字节
+func decodeVector(buf []byte) []float32 {
+	if len(buf) == 0 {
+		return nil
+	}
+	vec := make([]float32, len(buf)/4)
+	for i := range vec {
+		vec[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[4*i:]))
+	}
+	return vec
+}
+
+// Create 创建一个新的对话
+func (s *SQLiteStore) Create(id string, source Source, chunks []rag.Chunk) *Conversation {
+	log := logger.GetLogger()
+	now := time.Now()
+	source.ID = 1
+	source.AddedAt = now
+	conv := &Conversation{
+		ID:        id,
+		Sources:   []Source{source},
+		Messages:  []llm.Message{},
+		Chunks:    numberChunks(chunks, source.ID, 1),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		log.Errorw("开启事务失败", "error", err)
+		return conv
+	}
+	defer tx.Rollback()
+
+	// conversations 表中的 url 保留第一个来源，content 仅供旧版本数据使用
+	if _, err := tx.Exec(`INSERT OR REPLACE INTO conversations (id, url, content, created_at, updated_at, next_source_id, next_chunk_id) VALUES (?, ?, '', ?, ?, ?, ?)`,
+		id, source.URL, now.UnixNano(), now.UnixNano(), source.ID+1, len(chunks)+1); err != nil {
+		log.Errorw("保存会话失败", "id", id, "error", err)
+		return conv
+	}
+	if err := insertSource(tx, id, source, conv.Chunks); err != nil {
+		log.Errorw("保存会话失败", "id", id, "error", err)
+		return conv
+	}
+	if err := tx.Commit(); err != nil {
+		log.Errorw("提交事务失败", "id", id, "error", err)
+	}
+	return conv
+}
+
+// AddSource 向会话添加来源
+func (s *SQLiteStore) AddSource(id string, source Source, chunks []rag.Chunk) (*Source, bool) {
+	log := logger.GetLogger()
+	tx, err := s.db.Begin()
+	if err != nil {
+		log.Errorw("开启事务失败", "error", err)
+		return nil, false
+	}
+	defer tx.Rollback()
+
+	var nextSource, nextChunk int
+	err = tx.QueryRow(`SELECT next_source_id, next_chunk_id FROM conversations WHERE id = ?`, id).Scan(&nextSource, &nextChunk)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Errorw("查询会话失败", "id", id, "error", err)
+		}
+		return nil, false
+	}
+	if nextChunk == 0 {
+		// 旧版本数据没有记录片段编号
+		if err := tx.QueryRow(`SELECT COALESCE(MAX(chunk_id), 0) + 1 FROM chunks WHERE conversation_id = ?`, id).Scan(&nextChunk); err != nil {
+			log.Errorw("查询片段编号失败", "id", id, "error", err)
+			return nil, false
+		}
+	}
+
+	now := time.Now()
+	source.ID = nextSource
+	source.AddedAt = now
+	if err := insertSource(tx, id, source, numberChunks(chunks, source.ID, nextChunk)); err != nil {
+		log.Errorw("添加来源失败", "id", id, "error", err)
+		return nil, false
+	}
+	if _, err := tx.Exec(`UPDATE conversations SET next_source_id = ?, next_chunk_id = ?, updated_at = ? WHERE id = ?`,
+		nextSource+1, nextChunk+len(chunks), now.UnixNano(), id); err != nil {
+		log.Errorw("更新会话失败", "id", id, "error", err)
+		return nil, false
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Errorw("提交事务失败", "id", id, "error", err)
+		return nil, false
+	}
+	return &source, true
+}
+
+// RemoveSource 移除来源及其片段
+func (s *SQLiteStore) RemoveSource(id string, sourceID int) bool {
+	log := logger.GetLogger()
+	tx, err := s.db.Begin()
+	if err != nil {
+		log.Errorw("开启事务失败", "error", err)
+		return false
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`DELETE FROM sources WHERE conversation_id = ? AND source_id = ?`, id, sourceID)
+	if err != nil {
+		log.Errorw("删除来源失败", "id", id, "source_id", sourceID, "error", err)
+		return false
+	}
+	n, _ := res.RowsAffected()
+	if n == 0 && sourceID == 1 {
+		// 旧版本数据的第一个来源保存在 conversations 表中
+		res, err = tx.Exec(`UPDATE conversations SET url = '', content = '' WHERE id = ? AND content != ''`, id)
+		if err != nil {
+			log.Errorw("删除来源失败", "id", id, "source_id", sourceID, "error", err)
+			return false
+		}
+		n, _ = res.RowsAffected()
+	}
+	if n == 0 {
+		return false
+	}
+	if _, err := tx.Exec(`DELETE FROM chunks WHERE conversation_id = ? AND source_id = ?`, id, sourceID); err != nil {
+		log.Errorw("删除片段失败", "id", id, "source_id", sourceID, "error", err)
+		return false
+	}
+	if _, err := tx.Exec(`UPDATE conversations SET updated_at = ? WHERE id = ?`, time.Now().UnixNano(), id); err != nil {
+		log.Errorw("更新会话失败", "id", id, "error", err)
+		return false
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Errorw("提交事务失败", "id", id, "error", err)
+		return false
+	}
+	return true
+}
+
+// AddMessage 向对话添加一条消息
+func (s *SQLiteStore) AddMessage(id string, message llm.Message) bool {
+	log := logger.GetLogger()
+	tx, err := s.db.Begin()
+	if err != nil {
+		log.Errorw("开启事务失败", "error", err)
+		return false
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`UPDATE conversations SET updated_at = ? WHERE id = ?`, time.Now().UnixNano(), id)
+	if err != nil {
+		log.Errorw("更新会话失败", "id", id, "error", err)
+		return false
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false
+	}
+
+	if _, err := tx.Exec(`INSERT INTO messages (conversation_id, role, content) VALUES (?, ?, ?)`,
+		id, message.Role, message.Content); err != nil {
+		log.Errorw("保存消息失败", "id", id, "error", err)
+		return false
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Errorw("提交事务失败", "id", id, "error", err)
+		return false
+	}
+	return true
+}
+
+// GetMessages 获取对话的所有消息。检查会话存在与读取消息在同一个事务中，避免中间被并发删除
+func (s *SQLiteStore) GetMessages(id string) ([]llm.Message, bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, false, fmt.Errorf("开启事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	var exists int
+	if err := tx.QueryRow(`SELECT COUNT(1) FROM conversations WHERE id = ?`, id).Scan(&exists); err != nil {
+		return nil, false, fmt.Errorf("查询会话失败: %w", err)
+	}
+	if exists == 0 {
+		return nil, false, nil
+	}
+
+	rows, err := tx.Query(`SELECT role, content FROM messages WHERE conversation_id = ? ORDER BY seq`, id)
+	if err != nil {
+		return nil, false, fmt.Errorf("查询消息失败: %w", err)
+	}
+	defer rows.Close()
+
+	messages := []llm.Message{}
+	for rows.Next() {
+		var msg llm.Message
+		if err := rows.Scan(&msg.Role, &msg.Content); err != nil {
+			return nil, false, fmt.Errorf("读取消息失败: %w", err)
+		}
+		messages = append(messages, msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, fmt.Errorf("读取消息失败: %w", err)
+	}
+	return messages, true, nil
+}
+
+// Delete 删除指定ID的对话
+func (s *SQLiteStore) Delete(id string) bool {
+	log := logger.GetLogger()
+	tx, err := s.db.Begin()
+	if err != nil {
+		log.Errorw("开启事务失败", "error", err)
+		return false
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`DELETE FROM conversations WHERE id = ?`, id)
+	if err != nil {
+		log.Errorw("删除会话失败", "id", id, "error", err)
+		return false
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false
+	}
+	if _, err := tx.Exec(`DELETE FROM messages WHERE conversation_id = ?`, id); err != nil {
+		log.Errorw("删除消息失败", "id", id, "error", err)
+		return false
+	}
+	if _, err := tx.Exec(`DELETE FROM chunks WHERE conversation_id = ?`, id); err != nil {
+		log.Errorw("删除片段失败", "id", id, "error", err)
+		return false
+	}
+	if _, err := tx.Exec(`DELETE FROM sources WHERE conversation_id = ?`, id); err != nil {
+		log.Errorw("删除来源失败", "id", id, "error", err)
+		return false
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Errorw("提交事务失败", "id", id, "error", err)
+		return false
+	}
+	return true
+}
+
+// CleanupOldConversations 清理超过指定时间的旧对话
+func (s *SQLiteStore) CleanupOldConversations(maxAge time.Duration) int {
+	log := logger.GetLogger()
+	cutoff := time.Now().Add(-maxAge).UnixNano()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		log.Errorw("开启事务失败", "error", err)
+		return 0
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM messages WHERE conversation_id IN (SELECT id FROM conversations WHERE updated_at < ?)`, cutoff); err != nil {
+		log.Errorw("清理过期消息失败", "error", err)
+		return 0
+	}
+	if _, err := tx.Exec(`DELETE FROM chunks WHERE conversation_id IN (SELECT id FROM conversations WHERE updated_at < ?)`, cutoff); err != nil {
+		log.Errorw("清理过期片段失败", "error", err)
+		return 0
+	}
+	if _, err := tx.Exec(`DELETE FROM sources WHERE conversation_id IN (SELECT id FROM conversations WHERE updated_at < ?)`, cutoff); err != nil {
+		log.Errorw("清理过期来源失败", "error", err)
+		return 0
+	}
+	res, err := tx.Exec(`DELETE FROM conversations WHERE updated_at < ?`, cutoff)
+	if err != nil {
+		log.Errorw("清理过期会话失败", "error", err)
+		return 0
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Errorw("提交事务失败", "error", err)
+		return 0
+	}
+	n, _ := res.RowsAffected()
+	return int(n)
+}
@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/eust-w/urlreader/internal/llm"
+	"github.com/eust-w/urlreader/internal/models"
+	"github.com/eust-w/urlreader/internal/rag"
+)
+
+func TestSQLiteStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "urlreader.db")
+	store, err := NewSQLiteStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	source := Source{URL: "https://example.com", Title: "Example", Content: "page content", Metadata: &models.Metadata{Author: "Ada"}}
+	chunks := []rag.Chunk{{Heading: []string{"Intro"}, Text: "first", Vector: []float32{0.5, -1}}, {Text: "second"}}
+	store.Create("a", source, chunks)
+	store.Create("b", source, nil)
+
+	messages := []llm.Message{{Role: "user", Content: "你好"}, {Role: "assistant", Content: "你好！"}}
+	for _, m := range messages {
+		if !store.AddMessage("a", m) {
+			t.Fatalf("AddMessage(%q) failed", m.Content)
+		}
+	}
+	if store.AddMessage("missing", messages[0]) {
+		t.Error("AddMessage to a missing conversation succeeded")
+	}
+	if got, ok, err := store.GetMessages("a"); err != nil || !ok || !reflect.DeepEqual(got, messages) {
+		t.Errorf("GetMessages = %+v, %v, %v", got, ok, err)
+	}
+	if got, ok, err := store.GetMessages("b"); err != nil || !ok || len(got) != 0 {
+		t.Errorf("GetMessages(empty) = %+v, %v, %v", got, ok, err)
+	}
+	if _, ok, err := store.GetMessages("missing"); ok || err != nil {
+		t.Error("GetMessages(missing) succeeded")
+	}
+
+	// 关闭后重新打开，模拟服务重启
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+	store, err = NewSQLiteStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	conv, ok, err := store.Get("a")
+	if err != nil || !ok {
+		t.Fatal("conversation lost after reopening")
+	}
+	if !reflect.DeepEqual(conv.Messages, messages) || len(conv.Sources) != 1 || conv.Sources[0].Title != "Example" ||
+		conv.Sources[0].Metadata == nil || conv.Sources[0].Metadata.Author != "Ada" {
+		t.Errorf("reopened conversation = %+v", conv)
+	}
+	wantChunks := []rag.Chunk{
+		{ID: 1, Source: 1, Heading: []string{"Intro"}, Text: "first", Vector: []float32{0.5, -1}},
+		{ID: 2, Source: 1, Heading: nil, Text: "second"},
+	}
+	if !reflect.DeepEqual(conv.Chunks, wantChunks) {
+		t.Errorf("chunks = %+v, want %+v", conv.Chunks, wantChunks)
+	}
+	if ids := store.ListIDs(); !reflect.DeepEqual(ids, []string{"a", "b"}) {
+		t.Errorf("ListIDs = %v", ids)
+	}
+
+	if !store.Delete("b") || store.Delete("b") {
+		t.Error("Delete(b) should succeed once")
+	}
+	if _, ok, err := store.Get("b"); ok || err != nil {
+		t.Error("deleted conversation still readable")
+	}
+
+	// 只清理超过时限未更新的会话
+	store.Create("old", source, chunks)
+	store.AddMessage("old", messages[0])
+	if _, err := store.db.Exec(`UPDATE conversations SET updated_at = ? WHERE id = 'old'`, time.Now().Add(-48*time.Hour).UnixNano()); err != nil {
+		t.Fatal(err)
+	}
+	if n := store.CleanupOldConversations(24 * time.Hour); n != 1 {
+		t.Errorf("CleanupOldConversations = %d, want 1", n)
+	}
+	if _, ok, err := store.Get("old"); ok || err != nil {
+		t.Error("old conversation not cleaned up")
+	}
+	var leftover int
+	store.db.QueryRow(`SELECT (SELECT COUNT(1) FROM messages WHERE conversation_id = 'old') + (SELECT COUNT(1) FROM chunks WHERE conversation_id = 'old') + (SELECT COUNT(1) FROM sources WHERE conversation_id = 'old')`).Scan(&leftover)
+	if leftover != 0 {
+		t.Errorf("%d rows of the cleaned conversation left", leftover)
+	}
+	if _, ok, err := store.Get("a"); !ok || err != nil {
+		t.Error("recent conversation cleaned up")
+	}
+}
+
+func TestSQLiteStoreErrors(t *testing.T) {
+	store, err := NewSQLiteStore(filepath.Join(t.TempDir(), "urlreader.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Create("a", Source{URL: "https://example.com", Content: "page content"}, nil)
+	store.Close()
+
+	// 数据库不可用时返回错误，而不是当作会话不存在
+	if conv, ok, err := store.Get("a"); err == nil || ok || conv != nil {
+		t.Errorf("Get = %+v, %v, %v", conv, ok, err)
+	}
+	if messages, ok, err := store.GetMessages("a"); err == nil || ok || messages != nil {
+		t.Errorf("GetMessages = %+v, %v, %v", messages, ok, err)
+	}
+}
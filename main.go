@@ -28,12 +28,16 @@ func main() {
 		AllowOrigins:     []string{"*"}, // 可根据需要指定前端域名
 		AllowMethods:     []string{"GET", "POST", "DELETE", "OPTIONS"},
 		AllowHeaders:     []string{"Origin", "Content-Type", "Content-Length", "Accept-Encoding", "X-CSRF-Token", "Authorization"},
+		ExposeHeaders:    []string{"X-Title", "X-URL", "X-Final-URL"},
 		AllowCredentials: true,
 	}))
 
 	// 创建API处理程序
-	handler := api.NewHandler(cfg)
-	log.Infow("API Handler 初始化完成")
+	handler, err := api.NewHandler(cfg)
+	if err != nil {
+		log.Fatalw("API Handler 初始化失败", "error", err)
+	}
+	log.Infow("API Handler 初始化完成", "storage", cfg.StorageBackend)
 
 	// 设置路由
 	handler.SetupRoutes(router)